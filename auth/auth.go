@@ -0,0 +1,200 @@
+// Package auth authenticates requests with static API keys or JWTs and checks
+// the scopes they grant.
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Scopes required by the routes.
+const (
+	ScopeTimeRead    = "time:read"
+	ScopeAlarmsRead  = "alarms:read"
+	ScopeAlarmsWrite = "alarms:write"
+	ScopeMetricsRead = "metrics:read"
+	ScopeAdmin       = "admin"
+)
+
+// Authentication methods.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// clockSkew is the leeway allowed on JWT time claims.
+const clockSkew = 30 * time.Second
+
+// SubjectKey is the Locals key under which the middleware records the
+// authenticated subject, for logging and rate limiting.
+const SubjectKey = "auth_subject"
+
+const principalKey = "auth_principal"
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Subject string
+	Method  string
+	Scopes  []string
+	// allScopes is set for API keys configured without scopes.
+	allScopes bool
+}
+
+// HasScope reports whether p was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	if p.allScopes {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// FromContext returns the principal the middleware authenticated, or nil.
+func FromContext(c *fiber.Ctx) *Principal {
+	p, _ := c.Locals(principalKey).(*Principal)
+	return p
+}
+
+// Options configures an Authenticator.
+type Options struct {
+	// APIKeys are "name:sha256-hex[:scopes]" entries with space-separated
+	// scopes. A key listed without scopes has all of them.
+	APIKeys []string
+	// JWKSFile holds the RSA and HMAC ("oct") keys JWTs are verified with.
+	JWKSFile string
+	// JWTSecret is an HMAC key for tokens without a kid.
+	JWTSecret string
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+}
+
+// Authenticator verifies API keys and JWTs.
+type Authenticator struct {
+	apiKeys map[[sha256.Size]byte]*Principal
+	keys    *keySet
+	parser  *jwt.Parser
+}
+
+// New loads the API keys and JWT keys in opts.
+func New(opts Options) (*Authenticator, error) {
+	a := &Authenticator{apiKeys: make(map[[sha256.Size]byte]*Principal), keys: newKeySet()}
+	for _, entry := range opts.APIKeys {
+		hash, p, err := parseAPIKey(entry)
+		if err != nil {
+			return nil, err
+		}
+		a.apiKeys[hash] = p
+	}
+	if opts.JWKSFile != "" {
+		if err := a.keys.loadFile(opts.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+	if opts.JWTSecret != "" {
+		a.keys.hmac[""] = []byte(opts.JWTSecret)
+	}
+	if len(a.apiKeys) == 0 && a.keys.empty() {
+		return nil, errors.New("no API keys or JWT keys configured")
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(a.keys.methods()),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithExpirationRequired(),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	a.parser = jwt.NewParser(parserOpts...)
+	return a, nil
+}
+
+// parseAPIKey parses a "name:sha256-hex[:scopes]" entry.
+func parseAPIKey(entry string) ([sha256.Size]byte, *Principal, error) {
+	var hash [sha256.Size]byte
+	parts := strings.SplitN(entry, ":", 3)
+	if len(parts) < 2 || parts[0] == "" {
+		return hash, nil, fmt.Errorf("API key %q: want name:sha256-hex[:scopes]", redact(entry))
+	}
+	raw, err := hex.DecodeString(parts[1])
+	if err != nil || len(raw) != sha256.Size {
+		return hash, nil, fmt.Errorf("API key %s: hash must be 64 hex characters of SHA-256", parts[0])
+	}
+	copy(hash[:], raw)
+	p := &Principal{Subject: parts[0], Method: MethodAPIKey, allScopes: true}
+	if len(parts) == 3 {
+		p.Scopes = strings.Fields(parts[2])
+		p.allScopes = len(p.Scopes) == 0
+	}
+	return hash, p, nil
+}
+
+// redact keeps an unparseable entry, which might be a raw key, out of errors.
+func redact(entry string) string {
+	if len(entry) <= 4 {
+		return "****"
+	}
+	return entry[:4] + "****"
+}
+
+// HashAPIKey returns the hex SHA-256 of key, as written in API key entries.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate verifies token, a JWT or an API key.
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	if p, ok := a.apiKeys[sha256.Sum256([]byte(token))]; ok {
+		return p, nil
+	}
+	if strings.Count(token, ".") == 2 && !a.keys.empty() {
+		return a.verifyJWT(token)
+	}
+	return nil, errors.New("unknown API key")
+}
+
+func (a *Authenticator) verifyJWT(raw string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.keys.lookup); err != nil {
+		return nil, err
+	}
+	sub, _ := claims.GetSubject()
+	return &Principal{Subject: sub, Method: MethodJWT, Scopes: scopeClaim(claims)}, nil
+}
+
+// scopeClaim reads the space-separated "scope" claim, falling back to "scp",
+// which some issuers send as a list.
+func scopeClaim(claims jwt.MapClaims) []string {
+	if s, ok := claims["scope"].(string); ok {
+		return strings.Fields(s)
+	}
+	switch scp := claims["scp"].(type) {
+	case string:
+		return strings.Fields(scp)
+	case []any:
+		scopes := make([]string, 0, len(scp))
+		for _, s := range scp {
+			if s, ok := s.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+		return scopes
+	}
+	return nil
+}
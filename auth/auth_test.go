@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestParseAPIKey(t *testing.T) {
+	hash := HashAPIKey("s3cret")
+	_, p, err := parseAPIKey("ci:" + hash + ":time:read alarms:write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "ci" || !p.HasScope(ScopeTimeRead) || !p.HasScope(ScopeAlarmsWrite) || p.HasScope(ScopeAdmin) {
+		t.Errorf("expected ci with time:read and alarms:write, got %+v", p)
+	}
+	if _, p, _ := parseAPIKey("ops:" + hash); !p.HasScope(ScopeAdmin) {
+		t.Errorf("expected a key without scopes to have all of them")
+	}
+	for _, bad := range []string{"s3cret", "ci:" + hash[:10], ":" + hash} {
+		_, _, err := parseAPIKey(bad)
+		if err == nil {
+			t.Errorf("expected error for %q", bad)
+		} else if strings.Contains(err.Error(), "s3cret") {
+			t.Errorf("expected the raw entry to be redacted, got %v", err)
+		}
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	a, err := New(Options{APIKeys: []string{"ci:" + HashAPIKey("s3cret") + ":time:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Authenticate("s3cret")
+	if err != nil || p.Subject != "ci" || p.Method != MethodAPIKey {
+		t.Errorf("expected the ci key, got %+v (%v)", p, err)
+	}
+	if _, err := a.Authenticate("wrong"); err == nil {
+		t.Error("expected an unknown key to be rejected")
+	}
+}
+
+func signHS256(t *testing.T, claims jwt.MapClaims, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuthenticateHMAC(t *testing.T) {
+	a, err := New(Options{JWTSecret: testSecret, Issuer: "https://issuer.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	p, err := a.Authenticate(signHS256(t, jwt.MapClaims{"sub": "alice", "iss": "https://issuer.example.com", "exp": exp, "scope": "time:read alarms:read"}, ""))
+	if err != nil || p.Subject != "alice" || p.Method != MethodJWT || !p.HasScope(ScopeAlarmsRead) || p.HasScope(ScopeAlarmsWrite) {
+		t.Errorf("expected alice with read scopes, got %+v (%v)", p, err)
+	}
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"expired":      {"sub": "alice", "iss": "https://issuer.example.com", "exp": time.Now().Add(-time.Hour).Unix()},
+		"no expiry":    {"sub": "alice", "iss": "https://issuer.example.com"},
+		"wrong issuer": {"sub": "alice", "iss": "https://evil.example.com", "exp": exp},
+	} {
+		if _, err := a.Authenticate(signHS256(t, claims, "")); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "mallory", "iss": "https://issuer.example.com", "exp": exp})
+	s, _ := forged.SignedString([]byte("another-secret-of-at-least-32-bytes!"))
+	if _, err := a.Authenticate(s); err == nil {
+		t.Error("expected a token signed with another key to be rejected")
+	}
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "mallory", "exp": exp}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := a.Authenticate(none); err == nil {
+		t.Error("expected an unsigned token to be rejected")
+	}
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAuthenticateJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	path := writeJWKS(t,
+		map[string]string{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())},
+		map[string]string{"kty": "oct", "kid": "hmac-1", "k": b64([]byte(testSecret))},
+		map[string]string{"kty": "EC", "kid": "ec-1", "crv": "P-256"},
+	)
+	a, err := New(Options{JWKSFile: path, Audience: "gotimedate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{"sub": "svc", "aud": "gotimedate", "exp": time.Now().Add(time.Hour).Unix(), "scp": []string{"alarms:write"}}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "rsa-1"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Authenticate(signed)
+	if err != nil || p.Subject != "svc" || !p.HasScope(ScopeAlarmsWrite) {
+		t.Errorf("expected svc with alarms:write, got %+v (%v)", p, err)
+	}
+
+	if _, err := a.Authenticate(signHS256(t, claims, "hmac-1")); err != nil {
+		t.Errorf("expected the oct key to verify HS256, got %v", err)
+	}
+	if _, err := a.Authenticate(signHS256(t, claims, "rsa-1")); err == nil {
+		t.Error("expected an HS256 token naming the RSA key to be rejected")
+	}
+	if _, err := a.Authenticate(signHS256(t, claims, "")); err != nil {
+		t.Errorf("expected the only oct key to verify a token without kid, got %v", err)
+	}
+	claims["aud"] = "other"
+	if _, err := a.Authenticate(signHS256(t, claims, "hmac-1")); err == nil {
+		t.Error("expected the wrong audience to be rejected")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("expected an error without keys")
+	}
+	if _, err := New(Options{JWKSFile: writeJWKS(t, map[string]string{"kty": "EC"})}); err == nil {
+		t.Error("expected an error for a JWKS without usable keys")
+	}
+	if _, err := New(Options{JWKSFile: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Error("expected an error for a missing JWKS")
+	}
+}
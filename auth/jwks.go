@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keySet holds JWT verification keys by kid. A key stored under the empty kid
+// verifies tokens that carry none.
+type keySet struct {
+	hmac map[string][]byte
+	rsa  map[string]*rsa.PublicKey
+}
+
+func newKeySet() *keySet {
+	return &keySet{hmac: make(map[string][]byte), rsa: make(map[string]*rsa.PublicKey)}
+}
+
+func (s *keySet) empty() bool {
+	return len(s.hmac) == 0 && len(s.rsa) == 0
+}
+
+// methods lists the signing algorithms the keys can verify.
+func (s *keySet) methods() []string {
+	var methods []string
+	if len(s.hmac) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if len(s.rsa) > 0 {
+		methods = append(methods, "RS256", "RS384", "RS512")
+	}
+	return methods
+}
+
+// jwk is the subset of RFC 7517 members used for RSA and oct keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// loadFile adds the signing keys of a JWKS file. Keys of other types, such
+// as EC, and encryption keys are skipped.
+func (s *keySet) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading JWKS: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parsing JWKS %s: %w", path, err)
+	}
+	added := 0
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			pub, err := k.rsaKey()
+			if err != nil {
+				return fmt.Errorf("JWKS %s key %d (%s): %w", path, i, k.Kid, err)
+			}
+			s.rsa[k.Kid] = pub
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) == 0 {
+				return fmt.Errorf("JWKS %s key %d (%s): invalid k", path, i, k.Kid)
+			}
+			s.hmac[k.Kid] = secret
+		default:
+			continue
+		}
+		added++
+	}
+	if added == 0 {
+		return fmt.Errorf("JWKS %s has no RSA or oct signing keys", path)
+	}
+	return nil
+}
+
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid modulus n")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid exponent e")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// lookup is the jwt.Keyfunc: it picks the key named by the token's kid, of
+// the type its algorithm needs. A token without a kid uses the key stored
+// without one, or the only key of that type.
+func (s *keySet) lookup(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return pick(s.hmac, kid)
+	case *jwt.SigningMethodRSA:
+		return pick(s.rsa, kid)
+	}
+	return nil, fmt.Errorf("unsupported signing method %s", t.Method.Alg())
+}
+
+func pick[K any](keys map[string]K, kid string) (any, error) {
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
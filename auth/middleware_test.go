@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func authApp(t *testing.T, a *Authenticator) *fiber.App {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/read", a.Require(ScopeTimeRead), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals(SubjectKey).(string))
+	})
+	app.Get("/write", a.Require(ScopeAlarmsWrite), func(c *fiber.Ctx) error {
+		return c.SendString("written")
+	})
+	app.Get("/stream", a.RequireUpgrade(ScopeTimeRead), func(c *fiber.Ctx) error {
+		return c.SendString(FromContext(c).Subject)
+	})
+	return app
+}
+
+func TestRequire(t *testing.T) {
+	a, err := New(Options{APIKeys: []string{"reader:" + HashAPIKey("read-key") + ":time:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := authApp(t, a)
+
+	tests := []struct {
+		name    string
+		path    string
+		header  [2]string
+		status  int
+		wwwAuth string
+	}{
+		{"missing", "/read", [2]string{}, http.StatusUnauthorized, `Bearer realm="gotimedate"`},
+		{"bearer", "/read", [2]string{"Authorization", "Bearer read-key"}, http.StatusOK, ""},
+		{"lowercase scheme", "/read", [2]string{"Authorization", "bearer read-key"}, http.StatusOK, ""},
+		{"api key header", "/read", [2]string{"X-API-Key", "read-key"}, http.StatusOK, ""},
+		{"invalid", "/read", [2]string{"X-API-Key", "nope"}, http.StatusUnauthorized, `error="invalid_token"`},
+		{"insufficient scope", "/write", [2]string{"X-API-Key", "read-key"}, http.StatusForbidden, `error="insufficient_scope", scope="alarms:write"`},
+		{"query token ignored off streams", "/read?access_token=read-key", [2]string{}, http.StatusUnauthorized, ""},
+		{"query token", "/stream?access_token=read-key", [2]string{}, http.StatusOK, ""},
+		{"subprotocol token", "/stream", [2]string{"Sec-WebSocket-Protocol", "json, bearer.read-key"}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.header[0] != "" {
+				req.Header.Set(tt.header[0], tt.header[1])
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, resp.StatusCode)
+			}
+			if got := resp.Header.Get("WWW-Authenticate"); !strings.Contains(got, tt.wwwAuth) {
+				t.Errorf("expected WWW-Authenticate containing %q, got %q", tt.wwwAuth, got)
+			}
+		})
+	}
+}
+
+func TestRequireNil(t *testing.T) {
+	var a *Authenticator
+	req, _ := http.NewRequest("GET", "/write", nil)
+	resp, err := authApp(t, a).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a nil authenticator to admit requests, got %d", resp.StatusCode)
+	}
+}
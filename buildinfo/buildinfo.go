@@ -0,0 +1,48 @@
+// Package buildinfo holds the version details stamped into the binary at
+// build time:
+//
+//	go build -ldflags "-X gotimedate/buildinfo.Version=1.2.0 \
+//	  -X gotimedate/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X gotimedate/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" .
+//
+// Without ldflags, Commit and Date fall back to the VCS details the Go
+// toolchain records when building from a checkout.
+package buildinfo
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+var (
+	// Version is the release version, or "dev" for local builds.
+	Version = "dev"
+	// Commit is the git commit the binary was built from.
+	Commit = ""
+	// Date is the build time in RFC 3339.
+	Date = ""
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && Commit == "":
+			Commit = s.Value
+			if len(Commit) > 7 {
+				Commit = Commit[:7]
+			}
+		case s.Key == "vcs.time" && Date == "":
+			Date = s.Value
+		}
+	}
+}
+
+// BuildTime parses Date. It reports false when the date is unknown.
+func BuildTime() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, Date)
+	return t, err == nil
+}
@@ -0,0 +1,19 @@
+package buildinfo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildTime(t *testing.T) {
+	defer func(d string) { Date = d }(Date)
+
+	Date = "2024-01-03T14:30:45Z"
+	if got, ok := BuildTime(); !ok || !got.Equal(time.Date(2024, 1, 3, 14, 30, 45, 0, time.UTC)) {
+		t.Errorf("expected 2024-01-03T14:30:45Z, got %v (%v)", got, ok)
+	}
+	Date = ""
+	if _, ok := BuildTime(); ok {
+		t.Error("expected an unknown build time")
+	}
+}
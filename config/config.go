@@ -1,142 +1,223 @@
 package config
 
 import (
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"strings"
-
 	"regexp"
-
-	"github.com/gofiber/fiber/v2/log"
-	"github.com/joho/godotenv"
+	"strings"
+	"time"
 )
 
+// Config is the typed configuration schema. Each setting's cfg tag is its key
+// in a config file; upper-cased it is the environment variable and with dashes
+// the command-line flag. Defaults are written as environment values.
+// Settings tagged reload take effect on a running server when the config is
+// reloaded; the rest need a restart.
 type Config struct {
-	StaticDir string
-
-	Port             string
-	Host             string
-	Prefork          bool
-	DefaultTimezone  string
-	AllowedOrigins   []string
-	AllowedMethods   []string
-	AllowedHeaders   []string
-	AllowCredentials bool
-	MaxAge           int
-	WSPingInterval   int
-	WSPongWait       int
-	WSWriteWait      int
-	LogLevel         string
-	LogFormat        string
-	LogFile          string
-	OriginPatterns   []*regexp.Regexp
+	StaticDir string `cfg:"static_dir" default:"static" help:"Directory index.html is written to, relative to the binary"`
+
+	Port                string               `cfg:"port" default:"8080" help:"Port to listen on"`
+	Host                string               `cfg:"host" default:"localhost" help:"Address to listen on"`
+	Prefork             bool                 `cfg:"prefork" default:"false" help:"Serve from one process per CPU"`
+	ShutdownTimeout     int                  `cfg:"shutdown_timeout" default:"15" help:"Seconds to wait for in-flight requests and close handshakes on SIGTERM"`
+	ShutdownReconnect   int                  `cfg:"shutdown_reconnect_delay" default:"0" help:"Seconds WebSocket and SSE clients are told to wait before reconnecting (0 = no hint)"`
+	DefaultTimezone     string               `cfg:"default_tz" reload:"true" default:"UTC" help:"IANA timezone used when a request names none"`
+	AllowedOrigins      []string             `cfg:"allowed_origins" reload:"true" default:"http://localhost:3000,http://localhost:8080" help:"CORS origins: exact, *, https://*.example.com or http://localhost:*"`
+	AllowedMethods      []string             `cfg:"allowed_methods" default:"GET,POST,PUT,DELETE,OPTIONS" help:"CORS allowed methods"`
+	AllowedHeaders      []string             `cfg:"allowed_headers" default:"Content-Type,Authorization,X-API-Key,X-Requested-With" help:"CORS allowed headers"`
+	AllowCredentials    bool                 `cfg:"allow_credentials" default:"true" help:"CORS allow credentials"`
+	MaxAge              int                  `cfg:"max_age" default:"3600" help:"Seconds browsers may cache a preflight response"`
+	WSPingInterval      int                  `cfg:"ws_ping_interval" default:"30" help:"Seconds between WebSocket pings, below ws_pong_wait"`
+	WSPongWait          int                  `cfg:"ws_pong_wait" default:"60" help:"Seconds to wait for a pong before closing the connection"`
+	WSWriteWait         int                  `cfg:"ws_write_wait" default:"10" help:"Seconds a single WebSocket write may take"`
+	WSSendQueue         int                  `cfg:"ws_send_queue" default:"16" help:"Messages buffered per client before it is dropped as a slow consumer"`
+	WSMinInterval       time.Duration        `cfg:"ws_min_interval" default:"100ms" help:"Shortest update interval; intervals are rounded to a multiple of it, so it must divide one second"`
+	WSMaxInterval       time.Duration        `cfg:"ws_max_interval" default:"1m" help:"Longest update interval"`
+	WSCompression       bool                 `cfg:"ws_compression" default:"false" help:"Compress frames with permessage-deflate when the client offers it"`
+	WSCompressionLevel  int                  `cfg:"ws_compression_level" default:"1" help:"Compression level, 1 (fastest) to 9 (smallest)"`
+	WSMaxConnections    int                  `cfg:"ws_max_connections" reload:"true" default:"10000" help:"Open WebSocket connections before upgrades get 503 (0 = unlimited)"`
+	WSMaxConnsPerIP     int                  `cfg:"ws_max_connections_per_ip" reload:"true" default:"50" help:"Open WebSocket connections per IP before upgrades get 429 (0 = unlimited)"`
+	WSMessageRate       int                  `cfg:"ws_message_rate" reload:"true" default:"10" help:"Client messages per second before the connection is closed with 1008 (0 = unlimited)"`
+	WSMessageBurst      int                  `cfg:"ws_message_burst" reload:"true" default:"20" help:"Client messages allowed in a burst above ws_message_rate"`
+	WSIdleTimeout       int                  `cfg:"ws_idle_timeout" reload:"true" default:"0" help:"Seconds without a client message before the connection is closed (0 = never)"`
+	SSEHeartbeat        int                  `cfg:"sse_heartbeat" default:"15" help:"Seconds between Server-Sent Events heartbeat comments"`
+	MetricsEnabled      bool                 `cfg:"metrics_enabled" default:"true" help:"Serve Prometheus metrics on /metrics"`
+	MetricsPort         string               `cfg:"metrics_port" default:"" help:"Serve /metrics on this port only, off the public listener"`
+	TracingExporter     string               `cfg:"tracing_exporter" default:"none" help:"OpenTelemetry exporter: none, stdout, otlphttp or otlpgrpc"`
+	TracingSampleRatio  float64              `cfg:"tracing_sample_ratio" default:"1" help:"Fraction of new traces sampled; incoming traceparent decisions are kept"`
+	LogLevel            string               `cfg:"log_level" reload:"true" default:"info" help:"debug, info, warn or error"`
+	LogFormat           string               `cfg:"log_format" default:"json" help:"json or text"`
+	LogSampling         map[string]float64   `cfg:"log_sampling" default:"/health=0.01,/livez=0.01,/readyz=0.01,/metrics=0.01" help:"Fraction of successful requests logged per route; failures and unlisted routes are always logged"`
+	LogFile             string               `cfg:"log_file" default:"server.log" help:"Log file, relative to the binary"`
+	LogOutput           string               `cfg:"log_output" default:"both" help:"Where logs go: stdout, file or both"`
+	LogMaxSize          int                  `cfg:"log_max_size" default:"100" help:"Rotate the log file past this many megabytes (0 = no size limit)"`
+	LogMaxAge           time.Duration        `cfg:"log_max_age" default:"24h" help:"Rotate the log file once it has been open this long (0 = never)"`
+	LogMaxBackups       int                  `cfg:"log_max_backups" default:"7" help:"Rotated log files kept (0 = keep all)"`
+	LogCompress         bool                 `cfg:"log_compress" default:"true" help:"Gzip rotated log files"`
+	AuthEnabled         bool                 `cfg:"auth_enabled" default:"false" help:"Require credentials on the API, WebSocket and SSE endpoints; /, /health, the probes and Swagger stay public"`
+	AuthAPIKeys         []string             `cfg:"auth_api_keys" default:"" help:"API keys as name:sha256-hex[:scopes], scopes space-separated; a key without scopes has all of them"`
+	AuthJWKSFile        string               `cfg:"auth_jwks_file" default:"" help:"JWKS file with the RSA and oct keys JWTs are verified against, relative to the binary"`
+	AuthJWTSecret       string               `cfg:"auth_jwt_secret" default:"" secret:"true" help:"HMAC key, at least 32 bytes, for HS256 JWTs without a kid"`
+	AuthJWTIssuer       string               `cfg:"auth_jwt_issuer" default:"" help:"Required iss claim of JWTs, if set"`
+	AuthJWTAudience     string               `cfg:"auth_jwt_audience" default:"" help:"Required aud claim of JWTs, if set"`
+	RateLimitEnabled    bool                 `cfg:"rate_limit_enabled" default:"false" help:"Limit request rates per client on the API, WebSocket upgrades and SSE streams"`
+	RateLimitKey        string               `cfg:"rate_limit_key" reload:"true" default:"credential" help:"What a client is: ip, or credential for the API key or JWT subject with the IP for anonymous requests"`
+	RateLimits          map[string]RateLimit `cfg:"rate_limits" reload:"true" default:"api=20/1s:40,ws=10/1m,sse=10/1m" help:"Token buckets per route group (api, ws, sse) as requests/period[:burst]; unlisted groups are not limited"`
+	AlarmsEnabled       bool                 `cfg:"alarms_enabled" default:"true" help:"Serve the alarm API and schedule alarms; alarms run in a single process, so they are off by default with prefork and cannot be turned on there"`
+	AlarmsFile          string               `cfg:"alarms_file" default:"alarms.json" help:"JSON file the alarms are stored in, relative to the binary; empty keeps them in memory only"`
+	WebhookSecret       string               `cfg:"webhook_secret" default:"" secret:"true" help:"HMAC-SHA256 key for the X-GoTimeDate-Signature webhook header"`
+	WebhookAllowPrivate bool                 `cfg:"webhook_allow_private" default:"false" help:"Let webhooks call loopback, private and link-local addresses, which are refused by default"`
+	WebhookAttempts     int                  `cfg:"webhook_max_attempts" default:"5" help:"Delivery attempts per alarm webhook"`
+	WebhookTimeout      int                  `cfg:"webhook_timeout" default:"10" help:"Seconds before a single webhook attempt times out"`
+
+	// File is the config file the settings were read from, if any.
+	File string
+	// PrintConfig is set by --print-config.
+	PrintConfig bool
+	// explicit records the settings given by a file, the environment or a
+	// flag rather than taken from the defaults.
+	explicit map[string]bool
+
+	OriginPatterns []*regexp.Regexp
 }
 
-const defaultConfigContent = `# Web Server Configuration
-PORT=8080
-HOST=localhost
-
-# Performance
-# Enable prefork for better performance (multiple processes)
-PREFORK=false
-
-# CORS Configuration
-# Examples: 
-# Single: https://app.example.com
-# Wildcard Subdomain: https://*.example.com
-# Wildcard Port: http://localhost:*
-ALLOWED_ORIGINS=http://localhost:3000,http://localhost:8080
-ALLOWED_METHODS=GET,POST,PUT,DELETE,OPTIONS
-ALLOWED_HEADERS=Content-Type,Authorization,X-Requested-With
-ALLOW_CREDENTIALS=true
-MAX_AGE=3600
-
-# WebSocket Configuration
-WS_PING_INTERVAL=30
-WS_PONG_WAIT=60
-WS_WRITE_WAIT=10
-
-# Logging
-# Available LOG_LEVEL: debug, info, warn, error
-LOG_LEVEL=info
-LOG_FORMAT=json
-LOG_FILE=server.log`
-
-func LoadConfig(defaultHTML []byte) *Config {
+// configFiles are looked for next to the binary when neither --config nor
+// CONFIG_FILE names a file.
+var configFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// Load reads the configuration from, in increasing precedence, the defaults,
+// a legacy config.env, a YAML or TOML file, environment variables and the
+// flags in args, then validates it. Every invalid setting is reported in the
+// returned Errors. For -h it prints usage and returns flag.ErrHelp.
+func Load(args []string) (*Config, error) {
 	exePath, err := os.Executable()
 	if err != nil {
-		log.Fatalf("Error getting executable path: %v", err)
+		return nil, fmt.Errorf("getting executable path: %w", err)
+	}
+	baseDir := filepath.Dir(exePath)
+	var envFiles []string
+	if strings.Contains(exePath, "go-build") || strings.Contains(exePath, "Temp") {
+		// When running via 'go run', paths are relative to the project root.
+		baseDir, _ = os.Getwd()
+		envFiles = append(envFiles, ".env")
 	}
+	// Older installs keep their settings in config.env; it is still read but
+	// no longer created.
+	envFiles = append(envFiles, filepath.Join(baseDir, "config.env"))
 
-	exeDir := filepath.Dir(exePath)
-	isDevelopment := strings.Contains(exePath, "go-build") || strings.Contains(exePath, "Temp")
-	if isDevelopment {
-		_ = godotenv.Load(".env")
+	return load(baseDir, args, envFiles...)
+}
+
+// load reads settings from envFiles, the first file to set one winning,
+// below the config file. They are read again on every load so that a reload
+// sees edits to them.
+func load(baseDir string, args []string, envFiles ...string) (*Config, error) {
+	dotenv, err := readEnvFiles(envFiles)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
 	}
 
-	configPath := filepath.Join(exeDir, "config.env")
-	if !isDevelopment {
-		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			log.Infof("Config file %s not found, creating default...", configPath)
-			err := os.WriteFile(configPath, []byte(defaultConfigContent), 0644)
-			if err != nil {
-				log.Fatalf("Error creating default config file: %v", err)
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.StringVar(&cfg.File, "config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "Print the effective config as YAML and exit")
+	flags := registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if cfg.File == "" {
+		for _, name := range configFiles {
+			if path := filepath.Join(baseDir, name); fileExists(path) {
+				cfg.File = path
+				break
 			}
 		}
-		_ = godotenv.Load(configPath)
 	}
 
-	staticDirName := getEnv("STATIC_DIR", "static")
-	var staticDirPath string
-	if isDevelopment {
-		// When running via 'go run', use the project root's static directory
-		cwd, _ := os.Getwd()
-		staticDirPath = filepath.Join(cwd, staticDirName)
-	} else {
-		staticDirPath = filepath.Join(exeDir, staticDirName)
+	var errs Errors
+	for i := len(dotenv) - 1; i >= 0; i-- {
+		errs = append(errs, cfg.loadEnv(dotenv[i].lookup, dotenv[i].path+": ")...)
+	}
+	if cfg.File != "" {
+		errs = append(errs, cfg.loadFile(cfg.File)...)
+	}
+	errs = append(errs, cfg.loadEnv(os.LookupEnv, "")...)
+	errs = append(errs, cfg.loadFlags(flags)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	cfg.normalize(baseDir)
+	cfg.preforkDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.CompileOrigins()
+	return cfg, nil
+}
+
+// normalize lowercases the enumerated settings and resolves relative paths
+// against baseDir.
+func (c *Config) normalize(baseDir string) {
+	for _, s := range []*string{&c.LogLevel, &c.LogFormat, &c.LogOutput, &c.TracingExporter} {
+		*s = strings.ToLower(strings.TrimSpace(*s))
+	}
+	c.StaticDir = resolvePath(baseDir, c.StaticDir)
+	c.LogFile = resolvePath(baseDir, c.LogFile)
+	if c.AlarmsFile != "" {
+		c.AlarmsFile = resolvePath(baseDir, c.AlarmsFile)
+	}
+	if c.AuthJWKSFile != "" {
+		c.AuthJWKSFile = resolvePath(baseDir, c.AuthJWKSFile)
+	}
+}
+
+// preforkDefaults turns off what Prefork cannot run unless it was asked for,
+// in which case Validate rejects it.
+func (c *Config) preforkDefaults() {
+	if c.Prefork && !c.explicit["alarms_enabled"] {
+		c.AlarmsEnabled = false
+	}
+}
+
+func resolvePath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
 	}
+	return filepath.Join(baseDir, path)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
 
-	if _, err := os.Stat(staticDirPath); os.IsNotExist(err) {
-		log.Infof("Static directory %s not found, creating...", staticDirPath)
-		if err := os.MkdirAll(staticDirPath, 0755); err != nil {
-			log.Fatalf("Error creating static directory: %v", err)
+// WriteStaticFiles creates the static directory and writes the embedded index
+// page into it.
+func (c *Config) WriteStaticFiles(defaultHTML []byte) error {
+	if _, err := os.Stat(c.StaticDir); os.IsNotExist(err) {
+		slog.Info("Static directory not found, creating", "path", c.StaticDir)
+		if err := os.MkdirAll(c.StaticDir, 0755); err != nil {
+			return fmt.Errorf("creating static directory: %w", err)
 		}
 	}
 
-	htmlPath := filepath.Join(staticDirPath, "index.html")
-	log.Infof("Ensuring latest index file at %s", htmlPath)
+	htmlPath := filepath.Join(c.StaticDir, "index.html")
+	slog.Info("Ensuring latest index file", "path", htmlPath)
 	if err := os.WriteFile(htmlPath, defaultHTML, 0644); err != nil {
-		log.Fatalf("Error updating index.html: %v", err)
-	}
-
-	cfg := &Config{
-		StaticDir:        staticDirPath,
-		Port:             getEnv("PORT", "8080"),
-		Host:             getEnv("HOST", "localhost"),
-		Prefork:          getEnvBool("PREFORK", false),
-		DefaultTimezone:  getEnv("DEFAULT_TZ", "UTC"),
-		AllowedOrigins:   splitEnv("ALLOWED_ORIGINS", ","),
-		AllowedMethods:   splitEnv("ALLOWED_METHODS", ","),
-		AllowedHeaders:   splitEnv("ALLOWED_HEADERS", ","),
-		AllowCredentials: getEnvBool("ALLOW_CREDENTIALS", true),
-		MaxAge:           getEnvInt("MAX_AGE", 3600),
-		WSPingInterval:   getEnvInt("WS_PING_INTERVAL", 30),
-		WSPongWait:       getEnvInt("WS_PONG_WAIT", 60),
-		WSWriteWait:      getEnvInt("WS_WRITE_WAIT", 10),
-		LogLevel:         strings.ToLower(getEnv("LOG_LEVEL", "info")),
-		LogFormat:        getEnv("LOG_FORMAT", "json"),
-	}
-
-	if isDevelopment {
-		cwd, _ := os.Getwd()
-		cfg.LogFile = filepath.Join(cwd, getEnv("LOG_FILE", "server.log"))
-	} else {
-		cfg.LogFile = filepath.Join(exeDir, getEnv("LOG_FILE", "server.log"))
+		return fmt.Errorf("updating index.html: %w", err)
 	}
-
-	cfg.CompileOrigins()
-	return cfg
+	return nil
 }
 
 func (c *Config) CompileOrigins() {
@@ -169,36 +250,3 @@ func (c *Config) IsOriginAllowed(origin string) bool {
 	}
 	return false
 }
-
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	}
-	return fallback
-}
-
-func splitEnv(key, sep string) []string {
-	val := getEnv(key, "")
-	if val == "" {
-		return []string{}
-	}
-	return strings.Split(val, sep)
-}
-
-func getEnvBool(key string, fallback bool) bool {
-	val := getEnv(key, "")
-	if val == "" {
-		return fallback
-	}
-	return strings.ToLower(val) == "true"
-}
-
-func getEnvInt(key string, fallback int) int {
-	val := getEnv(key, "")
-	if val == "" {
-		return fallback
-	}
-	var res int
-	fmt.Sscanf(val, "%d", &res)
-	return res
-}
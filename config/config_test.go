@@ -1,7 +1,9 @@
 package config
 
 import (
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestIsOriginAllowed(t *testing.T) {
@@ -39,3 +41,38 @@ func TestIsOriginAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSampling(t *testing.T) {
+	got, err := parseSampling("/health=0.01, /metrics=0")
+	if err != nil || len(got) != 2 || got["/health"] != 0.01 || got["/metrics"] != 0 {
+		t.Errorf("expected /health=0.01 and /metrics=0, got %v (%v)", got, err)
+	}
+	for _, bad := range []string{"/worse", "/api/v1/time=abc", "=0.5"} {
+		if _, err := parseSampling(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	got, err := parseRateLimits("api=20/1s:40, ws=10/m, sse=5/500ms")
+	want := map[string]RateLimit{
+		"api": {20, time.Second, 40},
+		"ws":  {10, time.Minute, 10},
+		"sse": {5, 500 * time.Millisecond, 5},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v (%v)", want, got, err)
+	}
+	for _, bad := range []string{"api", "api=20", "api=x/1s", "api=20/soon", "api=20/1s:x", "=1/1s"} {
+		if _, err := parseRateLimits(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+	for _, s := range []string{"20/1s:40", "10/1m", "1/1h", "5/500ms", "3/1m30s"} {
+		limit, err := parseRateLimit(s)
+		if err != nil || limit.String() != s {
+			t.Errorf("expected %q to round-trip, got %q (%v)", s, limit, err)
+		}
+	}
+}
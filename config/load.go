@@ -0,0 +1,405 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Errors lists every problem found while loading or validating a config.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+func (e Errors) Unwrap() []error { return e }
+
+// setting describes one tagged Config field.
+type setting struct {
+	key    string
+	def    string
+	help   string
+	secret bool
+	reload bool
+	index  int
+}
+
+func (s setting) env() string  { return strings.ToUpper(s.key) }
+func (s setting) flag() string { return strings.ReplaceAll(s.key, "_", "-") }
+
+var settings, settingsByKey = func() ([]setting, map[string]setting) {
+	var list []setting
+	byKey := make(map[string]setting)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, ok := f.Tag.Lookup("cfg")
+		if !ok {
+			continue
+		}
+		s := setting{
+			key:    key,
+			def:    f.Tag.Get("default"),
+			help:   f.Tag.Get("help"),
+			secret: f.Tag.Get("secret") == "true",
+			reload: f.Tag.Get("reload") == "true",
+			index:  i,
+		}
+		list = append(list, s)
+		byKey[key] = s
+	}
+	return list, byKey
+}()
+
+// ptr returns a pointer to the field behind s.
+func (c *Config) ptr(s setting) any {
+	return reflect.ValueOf(c).Elem().Field(s.index).Addr().Interface()
+}
+
+func (c *Config) markExplicit(s setting) {
+	if c.explicit == nil {
+		c.explicit = make(map[string]bool)
+	}
+	c.explicit[s.key] = true
+}
+
+func (c *Config) setDefaults() error {
+	for _, s := range settings {
+		if err := setString(c.ptr(s), s.def); err != nil {
+			return fmt.Errorf("default for %s: %w", s.key, err)
+		}
+	}
+	return nil
+}
+
+// loadFile applies a YAML or TOML file, chosen by extension. Unknown keys are
+// errors so that typos do not go unnoticed.
+func (c *Config) loadFile(path string) Errors {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Errors{fmt.Errorf("reading config file: %w", err)}
+	}
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return Errors{fmt.Errorf("%s: unsupported config file type, use .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return Errors{fmt.Errorf("parsing %s: %w", path, err)}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs Errors
+	for _, key := range keys {
+		s, ok := settingsByKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, key))
+			continue
+		}
+		if err := setValue(c.ptr(s), values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+		}
+		c.markExplicit(s)
+	}
+	return errs
+}
+
+// envFile holds the variables set in a dotenv file.
+type envFile struct {
+	path string
+	vars map[string]string
+}
+
+func (f envFile) lookup(key string) (string, bool) {
+	v, ok := f.vars[key]
+	return v, ok
+}
+
+// readEnvFiles reads dotenv files, skipping missing ones. Variables that are
+// not settings are exported to the process environment unless already set,
+// as godotenv.Load does; settings are left to loadEnv so that they do not
+// shadow the config file or outlive an edit.
+func readEnvFiles(paths []string) ([]envFile, error) {
+	var files []envFile
+	for _, path := range paths {
+		vars, err := godotenv.Read(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		for k, v := range vars {
+			if s, ok := settingsByKey[strings.ToLower(k)]; ok && s.env() == k {
+				continue
+			}
+			if _, ok := os.LookupEnv(k); !ok {
+				os.Setenv(k, v)
+			}
+		}
+		files = append(files, envFile{path: path, vars: vars})
+	}
+	return files, nil
+}
+
+// loadEnv applies environment variables from lookup, prefixing errors with
+// source. Empty values leave numbers, booleans and durations unchanged, as
+// the old config.env loader did.
+func (c *Config) loadEnv(lookup func(string) (string, bool), source string) Errors {
+	var errs Errors
+	for _, s := range settings {
+		val, ok := lookup(s.env())
+		if !ok {
+			continue
+		}
+		p := c.ptr(s)
+		if val == "" && !clearable(p) {
+			continue
+		}
+		if err := setString(p, val); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", source, s.env(), err))
+		}
+		c.markExplicit(s)
+	}
+	return errs
+}
+
+// flagValue records a setting's command-line value until the file and
+// environment have been applied.
+type flagValue struct {
+	values map[string]string
+	key    string
+	isBool bool
+}
+
+func (f *flagValue) String() string     { return "" }
+func (f *flagValue) IsBoolFlag() bool   { return f.isBool }
+func (f *flagValue) Set(s string) error { f.values[f.key] = s; return nil }
+
+// registerFlags adds a flag for every setting and returns the values set.
+func registerFlags(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	var zero Config
+	for _, s := range settings {
+		_, isBool := zero.ptr(s).(*bool)
+		fs.Var(&flagValue{values: values, key: s.key, isBool: isBool}, s.flag(), s.help)
+	}
+	return values
+}
+
+func (c *Config) loadFlags(values map[string]string) Errors {
+	var errs Errors
+	for _, s := range settings {
+		val, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		if err := setString(c.ptr(s), val); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", s.flag(), err))
+		}
+		c.markExplicit(s)
+	}
+	return errs
+}
+
+func clearable(p any) bool {
+	switch p.(type) {
+	case *string, *[]string, *map[string]float64, *map[string]RateLimit:
+		return true
+	}
+	return false
+}
+
+// setString parses s the way environment variables and flags are written.
+func setString(p any, s string) error {
+	switch p := p.(type) {
+	case *string:
+		*p = s
+	case *bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", s)
+		}
+		*p = b
+	case *int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		*p = n
+	case *float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		*p = f
+	case *time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 1m", s)
+		}
+		*p = d
+	case *[]string:
+		*p = splitList(s)
+	case *map[string]float64:
+		m, err := parseSampling(s)
+		if err != nil {
+			return err
+		}
+		*p = m
+	case *map[string]RateLimit:
+		m, err := parseRateLimits(s)
+		if err != nil {
+			return err
+		}
+		*p = m
+	default:
+		return fmt.Errorf("unsupported setting type %T", p)
+	}
+	return nil
+}
+
+// setValue applies a decoded YAML or TOML value. Strings are parsed like
+// environment variables, so "30s" and "a,b" work as well as native values.
+func setValue(p any, v any) error {
+	if v == nil {
+		reflect.ValueOf(p).Elem().SetZero()
+		return nil
+	}
+	if s, ok := v.(string); ok {
+		return setString(p, s)
+	}
+	switch p := p.(type) {
+	case *string:
+		if n, ok := number(v); ok {
+			*p = strconv.FormatFloat(n, 'f', -1, 64)
+			return nil
+		}
+	case *bool:
+		if b, ok := v.(bool); ok {
+			*p = b
+			return nil
+		}
+	case *int:
+		if n, ok := number(v); ok && n == float64(int(n)) {
+			*p = int(n)
+			return nil
+		}
+	case *float64:
+		if n, ok := number(v); ok {
+			*p = n
+			return nil
+		}
+	case *[]string:
+		if list, ok := v.([]any); ok {
+			items := make([]string, 0, len(list))
+			for _, item := range list {
+				s, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("expected a list of strings, got %v", item)
+				}
+				items = append(items, strings.TrimSpace(s))
+			}
+			*p = items
+			return nil
+		}
+	case *map[string]float64:
+		if m, ok := v.(map[string]any); ok {
+			rates := make(map[string]float64, len(m))
+			for route, raw := range m {
+				rate, ok := number(raw)
+				if !ok {
+					return fmt.Errorf("rate for %s: expected a number, got %v", route, raw)
+				}
+				rates[route] = rate
+			}
+			*p = rates
+			return nil
+		}
+	case *map[string]RateLimit:
+		if m, ok := v.(map[string]any); ok {
+			limits := make(map[string]RateLimit, len(m))
+			for group, raw := range m {
+				s, ok := raw.(string)
+				if !ok {
+					return fmt.Errorf("limit for %s: expected a string such as 100/1m, got %v", group, raw)
+				}
+				limit, err := parseRateLimit(s)
+				if err != nil {
+					return fmt.Errorf("limit for %s: %w", group, err)
+				}
+				limits[group] = limit
+			}
+			*p = limits
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected value %v (%T)", v, v)
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseSampling parses comma-separated route=rate pairs.
+func parseSampling(s string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, pair := range splitList(s) {
+		route, raw, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(route) == "" {
+			return nil, fmt.Errorf("%q is not a route=rate pair", pair)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("rate in %q is not a number", pair)
+		}
+		rates[strings.TrimSpace(route)] = rate
+	}
+	return rates, nil
+}
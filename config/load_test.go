@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := load(dir, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Port != "8080" || cfg.WSMinInterval != 100*time.Millisecond || !cfg.LogCompress {
+		t.Errorf("expected defaults, got port %s, min interval %s, compress %v", cfg.Port, cfg.WSMinInterval, cfg.LogCompress)
+	}
+	if len(cfg.AllowedOrigins) != 2 || cfg.LogSampling["/health"] != 0.01 {
+		t.Errorf("expected default origins and sampling, got %v and %v", cfg.AllowedOrigins, cfg.LogSampling)
+	}
+	if cfg.LogFile != filepath.Join(dir, "server.log") || cfg.File != "" {
+		t.Errorf("expected log file in %s and no config file, got %s and %q", dir, cfg.LogFile, cfg.File)
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", `
+port: 9090
+default_tz: Europe/Berlin
+allowed_origins:
+  - https://*.example.com
+ws_min_interval: 250ms
+tracing_sample_ratio: 0.5
+log_sampling:
+  /health: 0
+log_file: /var/log/gotimedate.log
+metrics_port:
+rate_limits:
+  api: 100/1m:20
+`)
+	cfg, err := load(dir, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.File != filepath.Join(dir, "config.yaml") {
+		t.Errorf("expected config.yaml to be found, got %q", cfg.File)
+	}
+	if cfg.Port != "9090" || cfg.DefaultTimezone != "Europe/Berlin" || cfg.WSMinInterval != 250*time.Millisecond || cfg.TracingSampleRatio != 0.5 {
+		t.Errorf("expected file values, got %+v", cfg)
+	}
+	if len(cfg.AllowedOrigins) != 1 || !cfg.IsOriginAllowed("https://app.example.com") {
+		t.Errorf("expected compiled wildcard origin, got %v", cfg.AllowedOrigins)
+	}
+	if len(cfg.LogSampling) != 1 || cfg.LogSampling["/health"] != 0 {
+		t.Errorf("expected sampling to replace the default, got %v", cfg.LogSampling)
+	}
+	if cfg.LogFile != "/var/log/gotimedate.log" {
+		t.Errorf("expected absolute log file to be kept, got %s", cfg.LogFile)
+	}
+	if want := (RateLimit{100, time.Minute, 20}); len(cfg.RateLimits) != 1 || cfg.RateLimits["api"] != want {
+		t.Errorf("expected rate limits to replace the default, got %v", cfg.RateLimits)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "settings.toml", `
+port = 9091
+prefork = true
+allowed_methods = ["GET"]
+log_max_age = "1h"
+`)
+	cfg, err := load(dir, []string{"--config", path})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Port != "9091" || !cfg.Prefork || len(cfg.AllowedMethods) != 1 || cfg.LogMaxAge != time.Hour {
+		t.Errorf("expected TOML values, got %+v", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", "port: 9000\nhost: filehost\nlog_level: warn\n")
+	t.Setenv("PORT", "9001")
+	t.Setenv("HOST", "envhost")
+	t.Setenv("WS_PING_INTERVAL", "")
+
+	cfg, err := load(dir, []string{"--port=9002", "--prefork", "--log-level", "DEBUG"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Port != "9002" || cfg.Host != "envhost" || !cfg.Prefork || cfg.LogLevel != "debug" {
+		t.Errorf("expected flag > env > file, got port %s host %s prefork %v level %s", cfg.Port, cfg.Host, cfg.Prefork, cfg.LogLevel)
+	}
+	if cfg.WSPingInterval != 30 {
+		t.Errorf("expected empty env var to keep the default, got %d", cfg.WSPingInterval)
+	}
+}
+
+func TestLoadEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	legacy := writeFile(t, dir, "config.env", "PORT=7000\nHOST=legacyhost\nDEFAULT_TZ=Asia/Tokyo\nGOTIMEDATE_TEST_EXTRA=legacy\n")
+	dev := writeFile(t, dir, ".env", "HOST=devhost\n")
+	writeFile(t, dir, "config.yaml", "port: 9000\n")
+	t.Cleanup(func() { os.Unsetenv("GOTIMEDATE_TEST_EXTRA") })
+
+	cfg, err := load(dir, nil, dev, legacy)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Port != "9000" || cfg.Host != "devhost" || cfg.DefaultTimezone != "Asia/Tokyo" {
+		t.Errorf("expected file > .env > config.env, got port %s host %s tz %s", cfg.Port, cfg.Host, cfg.DefaultTimezone)
+	}
+	if _, ok := os.LookupEnv("PORT"); ok {
+		t.Error("expected settings from env files to stay out of the environment")
+	}
+	if got := os.Getenv("GOTIMEDATE_TEST_EXTRA"); got != "legacy" {
+		t.Errorf("expected other variables to be exported, got %q", got)
+	}
+
+	writeFile(t, dir, "config.env", "DEFAULT_TZ=Europe/Paris\n")
+	cfg, err = load(dir, nil, dev, legacy)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.DefaultTimezone != "Europe/Paris" || cfg.Host != "devhost" {
+		t.Errorf("expected an edited config.env to be read again, got tz %s host %s", cfg.DefaultTimezone, cfg.Host)
+	}
+
+	writeFile(t, dir, "config.env", "WS_SEND_QUEUE=16x\n")
+	if _, err := load(dir, nil, dev, legacy); err == nil || !strings.Contains(err.Error(), legacy+": WS_SEND_QUEUE") {
+		t.Errorf("expected the error to name config.env, got %v", err)
+	}
+}
+
+func TestLoadPreforkAlarms(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := load(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.AlarmsEnabled {
+		t.Error("expected alarms on by default")
+	}
+	cfg, err = load(dir, []string{"--prefork"})
+	if err != nil {
+		t.Fatalf("expected prefork without ALARMS_ENABLED to load, got %v", err)
+	}
+	if cfg.AlarmsEnabled {
+		t.Error("expected prefork to turn alarms off by default")
+	}
+	t.Setenv("ALARMS_ENABLED", "true")
+	if _, err := load(dir, []string{"--prefork"}); err == nil || !strings.Contains(err.Error(), "ALARMS_ENABLED") {
+		t.Errorf("expected alarms asked for under prefork to be rejected, got %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", "prot: 8080\nws_pong_wait: soon\nprefork: 1\n")
+	t.Setenv("WS_SEND_QUEUE", "16x")
+
+	_, err := load(dir, []string{"--max-age", "forever"})
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got %v", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{`unknown setting "prot"`, `ws_pong_wait: "soon" is not an integer`, "prefork: unexpected value 1", `WS_SEND_QUEUE: "16x" is not an integer`, `--max-age: "forever" is not an integer`} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in %q", want, msg)
+		}
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	_, err := load(t.TempDir(), []string{"-h"})
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WEBHOOK_SECRET", "hunter2")
+	cfg, err := load(dir, []string{"--port", "9090"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := cfg.WriteYAML(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	if strings.Contains(text, "hunter2") || !strings.Contains(text, "webhook_secret: REDACTED") {
+		t.Errorf("expected redacted secret, got:\n%s", text)
+	}
+	if !strings.Contains(text, "# Port to listen on\nport: \"9090\"") {
+		t.Errorf("expected commented port, got:\n%s", text)
+	}
+
+	// The printed config loads back to the same settings.
+	path := writeFile(t, dir, "printed.yaml", strings.ReplaceAll(text, redacted, "hunter2"))
+	os.Unsetenv("WEBHOOK_SECRET")
+	reloaded, err := load(dir, []string{"--config", path})
+	if err != nil {
+		t.Fatalf("expected printed config to load, got %v", err)
+	}
+	if reloaded.Port != "9090" || reloaded.WebhookSecret != "hunter2" || reloaded.WSMaxInterval != time.Minute {
+		t.Errorf("expected round trip, got %+v", reloaded)
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secret values in printed configs.
+const redacted = "REDACTED"
+
+// value returns the setting as it is written in a config file, with secrets
+// redacted.
+func (c *Config) value(s setting) any {
+	v := reflect.ValueOf(c.ptr(s)).Elem().Interface()
+	switch v := v.(type) {
+	case time.Duration:
+		return v.String()
+	case map[string]RateLimit:
+		limits := make(map[string]string, len(v))
+		for group, limit := range v {
+			limits[group] = limit.String()
+		}
+		return limits
+	}
+	if s.secret && v != "" {
+		return redacted
+	}
+	return v
+}
+
+// Redacted returns every setting by key, as it is written in a config file,
+// with secrets redacted.
+func (c *Config) Redacted() map[string]any {
+	values := make(map[string]any, len(settings))
+	for _, s := range settings {
+		values[s.key] = c.value(s)
+	}
+	return values
+}
+
+// WriteYAML writes the effective settings as a YAML config file, each with its
+// help text as a comment. Secrets are redacted.
+func (c *Config) WriteYAML(w io.Writer) error {
+	body := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range settings {
+		value := &yaml.Node{}
+		if err := value.Encode(c.value(s)); err != nil {
+			return err
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: s.key, HeadComment: s.help}
+		body.Content = append(body.Content, key, value)
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{body}}
+	if c.File != "" {
+		doc.HeadComment = "Loaded from " + c.File
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
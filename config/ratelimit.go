@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitGroups are the route groups rate_limits can name.
+var RateLimitGroups = []string{"api", "ws", "sse"}
+
+// RateLimit allows Requests per Period with bursts of up to Burst requests.
+// It is written as requests/period[:burst], for example 100/1m:20; the burst
+// defaults to Requests.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+func (r RateLimit) String() string {
+	s := strconv.Itoa(r.Requests) + "/" + formatPeriod(r.Period)
+	if r.Burst != r.Requests {
+		s += ":" + strconv.Itoa(r.Burst)
+	}
+	return s
+}
+
+// formatPeriod writes whole seconds, minutes and hours as 1s, 1m and 1h
+// rather than time.Duration's 1m0s.
+func formatPeriod(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// parseRateLimit parses requests/period[:burst]. A period without a number,
+// as in 10/s, means one of that unit.
+func parseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	fail := fmt.Errorf("%q is not a limit such as 100/1m or 100/1m:20", s)
+	requests, rest, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fail
+	}
+	period, burst, hasBurst := strings.Cut(rest, ":")
+	var r RateLimit
+	var err error
+	if r.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil {
+		return RateLimit{}, fail
+	}
+	period = strings.TrimSpace(period)
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	if r.Period, err = time.ParseDuration(period); err != nil {
+		return RateLimit{}, fail
+	}
+	r.Burst = r.Requests
+	if hasBurst {
+		if r.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil {
+			return RateLimit{}, fail
+		}
+	}
+	return r, nil
+}
+
+// parseRateLimits parses comma-separated group=limit pairs.
+func parseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, pair := range splitList(s) {
+		group, raw, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(group) == "" {
+			return nil, fmt.Errorf("%q is not a group=limit pair", pair)
+		}
+		limit, err := parseRateLimit(raw)
+		if err != nil {
+			return nil, err
+		}
+		limits[strings.TrimSpace(group)] = limit
+	}
+	return limits, nil
+}
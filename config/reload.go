@@ -0,0 +1,143 @@
+package config
+
+import (
+	"context"
+	"gotimedate/models"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reload outcomes.
+const (
+	ReloadApplied   = "applied"
+	ReloadUnchanged = "unchanged"
+	ReloadRejected  = "rejected"
+)
+
+// Reloader holds the config in effect and replaces it when asked to reload.
+// A new config that fails to load or validate is rejected and the current one
+// kept. Otherwise only settings tagged reload are swapped in; other changes
+// are reported as needing a restart.
+type Reloader struct {
+	load func() (*Config, error)
+	now  func() time.Time
+
+	current atomic.Pointer[Config]
+	last    atomic.Pointer[models.ReloadResult]
+
+	mu        sync.Mutex
+	listeners []func(*Config)
+}
+
+// NewReloader starts from cfg and reloads by calling load, usually a closure
+// over Load with the process arguments.
+func NewReloader(cfg *Config, load func() (*Config, error)) *Reloader {
+	r := &Reloader{load: load, now: time.Now}
+	r.current.Store(cfg)
+	return r
+}
+
+// Current returns the config in effect. It must not be modified.
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// LastReload returns the result of the most recent reload, or nil.
+func (r *Reloader) LastReload() *models.ReloadResult {
+	return r.last.Load()
+}
+
+// OnReload registers fn to be called with each newly applied config.
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Reload loads the config again and applies what changed. trigger names what
+// asked for the reload and is recorded in the result.
+func (r *Reloader) Reload(trigger string) models.ReloadResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := models.ReloadResult{Time: r.now(), Trigger: trigger}
+	loaded, err := r.load()
+	if err != nil {
+		result.Status = ReloadRejected
+		result.Error = err.Error()
+		r.last.Store(&result)
+		slog.Error("Config reload rejected, keeping current config", "trigger", trigger, "error", err)
+		return result
+	}
+
+	old := r.current.Load()
+	next := *old
+	next.File = loaded.File
+	for _, s := range settings {
+		field := reflect.ValueOf(loaded).Elem().Field(s.index)
+		if reflect.DeepEqual(field.Interface(), reflect.ValueOf(old).Elem().Field(s.index).Interface()) {
+			continue
+		}
+		if !s.reload {
+			result.RestartRequired = append(result.RestartRequired, s.key)
+			continue
+		}
+		reflect.ValueOf(&next).Elem().Field(s.index).Set(field)
+		result.Changed = append(result.Changed, s.key)
+	}
+
+	result.Status = ReloadUnchanged
+	if len(result.Changed) > 0 {
+		next.CompileOrigins()
+		r.current.Store(&next)
+		for _, fn := range r.listeners {
+			fn(&next)
+		}
+		result.Status = ReloadApplied
+	}
+	r.last.Store(&result)
+	slog.Info("Config reloaded", "trigger", trigger, "status", result.Status, "changed", result.Changed, "restart_required", result.RestartRequired)
+	return result
+}
+
+// Watch reloads whenever the config file's size or modification time changes,
+// checking every interval until ctx ends. Without a config file it does
+// nothing.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	path := r.Current().File
+	if path == "" {
+		return
+	}
+	last := stamp(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if s := stamp(path); s != last {
+			last = s
+			r.Reload("file")
+		}
+	}
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// stamp identifies a version of path; a missing file has the zero stamp.
+func stamp(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
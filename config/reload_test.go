@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", "log_level: info\n")
+	load := func() (*Config, error) { return load(dir, nil) }
+	cfg, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReloader(cfg, load)
+	var applied []*Config
+	r.OnReload(func(c *Config) { applied = append(applied, c) })
+
+	if res := r.Reload("test"); res.Status != ReloadUnchanged || len(applied) != 0 {
+		t.Errorf("expected an unchanged reload, got %+v", res)
+	}
+
+	writeFile(t, dir, "config.yaml", "log_level: debug\nallowed_origins: https://*.example.com\nport: 9999\n")
+	res := r.Reload("test")
+	if res.Status != ReloadApplied || strings.Join(res.Changed, ",") != "allowed_origins,log_level" || strings.Join(res.RestartRequired, ",") != "port" {
+		t.Errorf("expected origins and level applied and port needing a restart, got %+v", res)
+	}
+	current := r.Current()
+	if len(applied) != 1 || applied[0] != current {
+		t.Fatalf("expected listeners to get the new config, got %v", applied)
+	}
+	if current.LogLevel != "debug" || current.Port != "8080" || !current.IsOriginAllowed("https://app.example.com") {
+		t.Errorf("expected reloadable settings swapped and port kept, got level %s port %s origins %v", current.LogLevel, current.Port, current.AllowedOrigins)
+	}
+	if cfg.LogLevel != "info" {
+		t.Errorf("expected the previous config to be left untouched, got %s", cfg.LogLevel)
+	}
+
+	writeFile(t, dir, "config.yaml", "log_level: loud\ndefault_tz: Mars/Olympus\n")
+	res = r.Reload("test")
+	if res.Status != ReloadRejected || !strings.Contains(res.Error, "LOG_LEVEL") || !strings.Contains(res.Error, "DEFAULT_TZ") {
+		t.Errorf("expected the invalid config to be rejected, got %+v", res)
+	}
+	if r.Current() != current || r.LastReload().Status != ReloadRejected {
+		t.Errorf("expected the current config to be kept after a rejected reload")
+	}
+}
+
+func TestReloadWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "default_tz: UTC\n")
+	calls := make(chan struct{}, 10)
+	cfg, err := load(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReloader(cfg, func() (*Config, error) {
+		calls <- struct{}{}
+		return nil, errors.New("not reloaded")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	if len(calls) != 0 {
+		t.Fatalf("expected no reload before the file changes, got %d", len(calls))
+	}
+	writeFile(t, dir, "config.yaml", "default_tz: Europe/Berlin\n")
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	select {
+	case <-calls:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a reload after the file changed")
+	}
+	for deadline := time.Now().Add(time.Second); r.LastReload() == nil && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if res := r.LastReload(); res == nil || res.Trigger != "file" || res.Status != ReloadRejected {
+		t.Errorf("expected a rejected file-triggered reload, got %+v", res)
+	}
+}
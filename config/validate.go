@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// originPattern matches the origins CompileOrigins understands: a scheme and
+// host, optionally with a leading "*." label or a "*" port, and no path.
+var originPattern = regexp.MustCompile(`^https?://(\*\.)?([A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*|\[[0-9A-Fa-f:.]+\])(:(\*|[0-9]{1,5}))?$`)
+
+// Validate checks every setting and returns an Errors naming each invalid one
+// by its environment variable.
+func (c *Config) Validate() error {
+	var errs Errors
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	if !validPort(c.Port) {
+		fail("PORT", "%q is not a port between 1 and 65535", c.Port)
+	}
+	if c.MetricsPort != "" {
+		if !validPort(c.MetricsPort) {
+			fail("METRICS_PORT", "%q is not a port between 1 and 65535", c.MetricsPort)
+		} else if c.MetricsPort == c.Port {
+			fail("METRICS_PORT", "must differ from PORT %s", c.Port)
+		}
+	}
+	if c.ShutdownTimeout < 0 {
+		fail("SHUTDOWN_TIMEOUT", "must not be negative, got %d", c.ShutdownTimeout)
+	}
+	if c.ShutdownReconnect < 0 {
+		fail("SHUTDOWN_RECONNECT_DELAY", "must not be negative, got %d", c.ShutdownReconnect)
+	}
+
+	if _, err := time.LoadLocation(c.DefaultTimezone); err != nil || c.DefaultTimezone == "" {
+		fail("DEFAULT_TZ", "unknown timezone %q", c.DefaultTimezone)
+	}
+
+	for _, origin := range c.AllowedOrigins {
+		if origin != "*" && !validOrigin(origin) {
+			fail("ALLOWED_ORIGINS", "malformed origin %q, want scheme://host[:port] with an optional *. subdomain or :* port", origin)
+		}
+	}
+
+	if c.WSPingInterval <= 0 {
+		fail("WS_PING_INTERVAL", "must be positive, got %d", c.WSPingInterval)
+	}
+	if c.WSPongWait <= 0 {
+		fail("WS_PONG_WAIT", "must be positive, got %d", c.WSPongWait)
+	} else if c.WSPingInterval >= c.WSPongWait {
+		fail("WS_PING_INTERVAL", "must be less than WS_PONG_WAIT (%d), got %d", c.WSPongWait, c.WSPingInterval)
+	}
+	if c.WSWriteWait <= 0 {
+		fail("WS_WRITE_WAIT", "must be positive, got %d", c.WSWriteWait)
+	}
+	if c.WSSendQueue < 1 {
+		fail("WS_SEND_QUEUE", "must be at least 1, got %d", c.WSSendQueue)
+	}
+	if c.WSMinInterval <= 0 || time.Second%c.WSMinInterval != 0 {
+		fail("WS_MIN_INTERVAL", "must be positive and divide 1s evenly, got %s", c.WSMinInterval)
+	} else if c.WSMaxInterval < c.WSMinInterval {
+		fail("WS_MAX_INTERVAL", "must be at least WS_MIN_INTERVAL (%s), got %s", c.WSMinInterval, c.WSMaxInterval)
+	}
+	if c.WSCompressionLevel < 1 || c.WSCompressionLevel > 9 {
+		fail("WS_COMPRESSION_LEVEL", "must be between 1 and 9, got %d", c.WSCompressionLevel)
+	}
+	for _, limit := range []struct {
+		key string
+		n   int
+	}{
+		{"WS_MAX_CONNECTIONS", c.WSMaxConnections},
+		{"WS_MAX_CONNECTIONS_PER_IP", c.WSMaxConnsPerIP},
+		{"WS_MESSAGE_RATE", c.WSMessageRate},
+		{"WS_MESSAGE_BURST", c.WSMessageBurst},
+		{"WS_IDLE_TIMEOUT", c.WSIdleTimeout},
+		{"LOG_MAX_SIZE", c.LogMaxSize},
+		{"LOG_MAX_BACKUPS", c.LogMaxBackups},
+	} {
+		if limit.n < 0 {
+			fail(limit.key, "must not be negative, got %d", limit.n)
+		}
+	}
+	if c.SSEHeartbeat < 1 {
+		fail("SSE_HEARTBEAT", "must be at least 1, got %d", c.SSEHeartbeat)
+	}
+
+	if !oneOf(c.TracingExporter, "none", "stdout", "otlphttp", "otlpgrpc") {
+		fail("TRACING_EXPORTER", "must be none, stdout, otlphttp or otlpgrpc, got %q", c.TracingExporter)
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		fail("TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
+
+	if !oneOf(c.LogLevel, "debug", "info", "warn", "warning", "error") {
+		fail("LOG_LEVEL", "must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if !oneOf(c.LogFormat, "json", "text") {
+		fail("LOG_FORMAT", "must be json or text, got %q", c.LogFormat)
+	}
+	if !oneOf(c.LogOutput, "stdout", "file", "both") {
+		fail("LOG_OUTPUT", "must be stdout, file or both, got %q", c.LogOutput)
+	}
+	if c.LogOutput != "stdout" && c.LogFile == "" {
+		fail("LOG_FILE", "must be set when LOG_OUTPUT is %s", c.LogOutput)
+	}
+	if c.LogMaxAge < 0 {
+		fail("LOG_MAX_AGE", "must not be negative, got %s", c.LogMaxAge)
+	}
+	for route, rate := range c.LogSampling {
+		if rate < 0 || rate > 1 {
+			fail("LOG_SAMPLING", "rate for %s must be between 0 and 1, got %g", route, rate)
+		}
+	}
+
+	if c.AuthEnabled && len(c.AuthAPIKeys) == 0 && c.AuthJWKSFile == "" && c.AuthJWTSecret == "" {
+		fail("AUTH_ENABLED", "needs AUTH_API_KEYS, AUTH_JWKS_FILE or AUTH_JWT_SECRET")
+	}
+	if c.AuthJWTSecret != "" && len(c.AuthJWTSecret) < 32 {
+		fail("AUTH_JWT_SECRET", "must be at least 32 bytes, got %d", len(c.AuthJWTSecret))
+	}
+
+	if !oneOf(c.RateLimitKey, "ip", "credential") {
+		fail("RATE_LIMIT_KEY", "must be ip or credential, got %q", c.RateLimitKey)
+	}
+	for group, limit := range c.RateLimits {
+		switch {
+		case !oneOf(group, RateLimitGroups...):
+			fail("RATE_LIMITS", "unknown group %q, want one of %s", group, strings.Join(RateLimitGroups, ", "))
+		case limit.Requests < 1 || limit.Period <= 0 || limit.Burst < 1:
+			fail("RATE_LIMITS", "limit for %s needs at least one request, a positive period and a burst of at least 1, got %s", group, limit)
+		}
+	}
+
+	// Every Prefork child would run its own scheduler over the same file,
+	// firing each alarm once per child and overwriting each other's changes.
+	// Loading turns alarms off under Prefork unless they were asked for.
+	if c.AlarmsEnabled && c.Prefork {
+		fail("ALARMS_ENABLED", "alarms run in a single process and cannot be used with PREFORK; unset ALARMS_ENABLED or set PREFORK=false")
+	}
+	if c.WebhookAttempts < 1 {
+		fail("WEBHOOK_MAX_ATTEMPTS", "must be at least 1, got %d", c.WebhookAttempts)
+	}
+	if c.WebhookTimeout < 1 {
+		fail("WEBHOOK_TIMEOUT", "must be at least 1, got %d", c.WebhookTimeout)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+func validOrigin(origin string) bool {
+	m := originPattern.FindStringSubmatch(origin)
+	if m == nil {
+		return false
+	}
+	port := m[7]
+	return port == "" || port == "*" || validPort(port)
+}
+
+func oneOf(s string, options ...string) bool {
+	for _, o := range options {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg := &Config{}
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestValidateDefaults(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Errorf("expected defaults to be valid, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"port out of range", func(c *Config) { c.Port = "70000" }, "PORT:"},
+		{"port not a number", func(c *Config) { c.Port = "http" }, "PORT:"},
+		{"metrics port clash", func(c *Config) { c.MetricsPort = c.Port }, "METRICS_PORT: must differ"},
+		{"unknown timezone", func(c *Config) { c.DefaultTimezone = "Mars/Olympus" }, `DEFAULT_TZ: unknown timezone "Mars/Olympus"`},
+		{"origin without scheme", func(c *Config) { c.AllowedOrigins = []string{"example.com"} }, "ALLOWED_ORIGINS: malformed origin"},
+		{"origin with path", func(c *Config) { c.AllowedOrigins = []string{"https://example.com/app"} }, "ALLOWED_ORIGINS"},
+		{"origin inner wildcard", func(c *Config) { c.AllowedOrigins = []string{"https://app.*.com"} }, "ALLOWED_ORIGINS"},
+		{"ping above pong", func(c *Config) { c.WSPingInterval = 90 }, "WS_PING_INTERVAL: must be less than WS_PONG_WAIT"},
+		{"zero write wait", func(c *Config) { c.WSWriteWait = 0 }, "WS_WRITE_WAIT"},
+		{"min interval not dividing a second", func(c *Config) { c.WSMinInterval = 300_000_000 }, "WS_MIN_INTERVAL"},
+		{"compression level", func(c *Config) { c.WSCompressionLevel = 10 }, "WS_COMPRESSION_LEVEL"},
+		{"negative cap", func(c *Config) { c.WSMaxConnsPerIP = -1 }, "WS_MAX_CONNECTIONS_PER_IP"},
+		{"log level", func(c *Config) { c.LogLevel = "verbose" }, "LOG_LEVEL"},
+		{"sampling rate", func(c *Config) { c.LogSampling = map[string]float64{"/health": 2} }, "LOG_SAMPLING"},
+		{"exporter", func(c *Config) { c.TracingExporter = "jaeger" }, "TRACING_EXPORTER"},
+		{"auth without credentials", func(c *Config) { c.AuthEnabled = true }, "AUTH_ENABLED: needs"},
+		{"short JWT secret", func(c *Config) { c.AuthJWTSecret = "short" }, "AUTH_JWT_SECRET"},
+		{"alarms with prefork", func(c *Config) { c.Prefork = true }, "ALARMS_ENABLED: alarms run in a single process"},
+		{"rate limit key", func(c *Config) { c.RateLimitKey = "token" }, "RATE_LIMIT_KEY"},
+		{"rate limit group", func(c *Config) { c.RateLimits = map[string]RateLimit{"admin": {1, time.Second, 1}} }, `RATE_LIMITS: unknown group "admin"`},
+		{"zero burst", func(c *Config) { c.RateLimits = map[string]RateLimit{"api": {1, time.Second, 0}} }, "RATE_LIMITS: limit for api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidOrigin(t *testing.T) {
+	for _, origin := range []string{"http://localhost:3000", "https://*.example.com", "http://localhost:*", "http://[::1]:8080", "https://a-b.example.co.uk"} {
+		if !validOrigin(origin) {
+			t.Errorf("expected %q to be valid", origin)
+		}
+	}
+	for _, origin := range []string{"ftp://example.com", "https://", "https://*", "http://localhost:99999", "https://example.com/"} {
+		if validOrigin(origin) {
+			t.Errorf("expected %q to be invalid", origin)
+		}
+	}
+}
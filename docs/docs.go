@@ -15,122 +15,978 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/config": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The configuration in effect, with secrets redacted, and the result of the last reload",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Effective configuration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ConfigResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/config/reload": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Re-reads the config file and environment and applies the settings that can change at runtime. An invalid config is rejected with 422 and the current one kept. In Prefork mode the result is that of the child answering, and the other children reload after it.",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reload configuration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReloadResult"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReloadResult"
+                        }
+                    }
+                }
+            }
+        },
+        "/alarms": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Alarms"
+                ],
+                "summary": "List alarms",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Alarm"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Schedules a one-shot (date) or repeating (days) alarm delivered over WebSocket and to an optional webhook",
+                "tags": [
+                    "Alarms"
+                ],
+                "summary": "Create alarm",
+                "parameters": [
+                    {
+                        "description": "Alarm",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AlarmRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Alarm"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/alarms/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Alarms"
+                ],
+                "summary": "Get alarm",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Alarm ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Alarm"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Alarms"
+                ],
+                "summary": "Replace alarm",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Alarm ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Alarm",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AlarmRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Alarm"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Alarms"
+                ],
+                "summary": "Delete alarm",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Alarm ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/health": {
             "get": {
+                "description": "With verbose=1, adds build details, uptime and the tzdata, log file, clock and hub checks. A failed check makes the status unhealthy and the response 503.",
                 "tags": [
                     "Health"
                 ],
                 "summary": "Health check",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include build details and checks",
+                        "name": "verbose",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/models.HealthResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/models.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/livez": {
+            "get": {
+                "description": "Answers 200 while the process can serve requests.",
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ProbeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Answers 200 once tzdata loads and the broadcast hub is ticking, and 503 with the failed checks otherwise, including while the server shuts down.",
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ProbeResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/models.ProbeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sse/time": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Streams the same time_update messages as /ws/time. Event IDs are tick timestamps; reconnecting with Last-Event-ID replays missed ticks (up to 100).",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Time"
+                ],
+                "summary": "Stream time over Server-Sent Events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Timezone (default server default)",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO8601, 12hour or 24hour",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Go duration between updates, e.g. 100ms or 5s (default 1s)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Push only at minute, hour, day (midnight) or dst changes instead of an interval; comma-separated",
+                        "name": "on_change",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sub-second precision: s, ms, us or ns (default s)",
+                        "name": "precision",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale such as en, ms, ar, de, fr, ja, zh or hi-u-nu-deva (default Accept-Language)",
+                        "name": "locale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last event received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebSocketMessage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/time": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Time"
+                ],
+                "summary": "Get current time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Timezone (default UTC)",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sub-second precision: s, ms, us or ns (default s)",
+                        "name": "precision",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale such as en, ms, ar, de, fr, ja, zh or hi-u-nu-deva (default Accept-Language)",
+                        "name": "locale",
+                        "in": "query"
                     }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TimeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/time/convert": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Time"
+                ],
+                "summary": "Convert time",
+                "parameters": [
+                    {
+                        "description": "Conversion request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TimeConvertRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sub-second precision: s, ms, us or ns (default s)",
+                        "name": "precision",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale such as en, ms, ar, de, fr, ja, zh or hi-u-nu-deva (default Accept-Language)",
+                        "name": "locale",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TimeConvertResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/time/parse": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Resolves phrases like \"next Friday 3pm\" or \"in 2 weeks\" relative to a reference instant",
+                "tags": [
+                    "Time"
+                ],
+                "summary": "Parse natural-language time",
+                "parameters": [
+                    {
+                        "description": "Parse request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ParseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ParseResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/time/relative": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Describes a timestamp relative to a reference instant, e.g. \"in 3 hours\" or \"yesterday at 5 PM\"",
+                "tags": [
+                    "Time"
+                ],
+                "summary": "Relative time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 timestamp to describe",
+                        "name": "timestamp",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 reference instant (default now)",
+                        "name": "reference",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Timezone used for calendar phrases (default UTC)",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale such as en, ms, ar, de, fr, ja, zh or hi (default Accept-Language, then en)",
+                        "name": "locale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Smallest unit: second, minute, hour, day, week, month or year (default second)",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "round, floor or ceil (default round)",
+                        "name": "rounding",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Use yesterday/tomorrow phrases (default true)",
+                        "name": "calendar",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RelativeTimeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/time/sync": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns high-resolution receive/transmit timestamps for NTP-style offset estimation",
+                "tags": [
+                    "Time"
+                ],
+                "summary": "Clock synchronization",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "description": "Client transmit time in Unix milliseconds",
+                        "name": "t0",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ClockSyncResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/time/{timezone}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Time"
+                ],
+                "summary": "Get time by timezone",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Timezone",
+                        "name": "timezone",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sub-second precision: s, ms, us or ns (default s)",
+                        "name": "precision",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale such as en, ms, ar, de, fr, ja, zh or hi-u-nu-deva (default Accept-Language)",
+                        "name": "locale",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TimeResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/timezones": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "Time"
+                ],
+                "summary": "Get available timezones",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.TimezoneInfo"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/ws/stats": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Counters for the broadcast hub and WebSocket connection limits",
+                "tags": [
+                    "WebSocket"
+                ],
+                "summary": "WebSocket statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WSStats"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.ConnStats": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "integer"
+                },
+                "closed_idle": {
+                    "type": "integer"
+                },
+                "closed_rate_limited": {
+                    "type": "integer"
+                },
+                "ips": {
+                    "type": "integer"
+                },
+                "max_connections": {
+                    "type": "integer"
+                },
+                "max_connections_per_ip": {
+                    "type": "integer"
+                },
+                "rejected_global": {
+                    "type": "integer"
+                },
+                "rejected_per_ip": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.HubStats": {
+            "type": "object",
+            "properties": {
+                "clients": {
+                    "type": "integer"
+                },
+                "evicted": {
+                    "type": "integer"
+                },
+                "last_tick": {
+                    "type": "string"
+                },
+                "messages_sent": {
+                    "type": "integer"
+                },
+                "streams": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.WSStats": {
+            "type": "object",
+            "properties": {
+                "connections": {
+                    "$ref": "#/definitions/handlers.ConnStats"
+                },
+                "hub": {
+                    "$ref": "#/definitions/handlers.HubStats"
+                }
+            }
+        },
+        "models.Alarm": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2024-01-03T14:30:45Z"
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2024-01-05"
+                },
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "weekdays"
+                    ]
+                },
+                "id": {
+                    "type": "string",
+                    "example": "7f9c2ba4-e88f-4e0b-9c3a-7d3a1c5e2b10"
+                },
+                "last_fired": {
+                    "type": "string",
+                    "example": "2024-01-04T09:00:00+08:00"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Daily stand-up in 5 minutes"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Stand-up"
+                },
+                "next_fire": {
+                    "type": "string",
+                    "example": "2024-01-05T09:00:00+08:00"
+                },
+                "time": {
+                    "type": "string",
+                    "example": "09:00"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Asia/Kuala_Lumpur"
+                },
+                "webhook_url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/alarm"
+                }
+            }
+        },
+        "models.AlarmRequest": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "type": "string",
+                    "example": "2024-01-05"
+                },
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "weekdays"
+                    ]
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Daily stand-up in 5 minutes"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Stand-up"
+                },
+                "time": {
+                    "type": "string",
+                    "example": "09:00"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Asia/Kuala_Lumpur"
+                },
+                "webhook_url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/alarm"
+                }
+            }
+        },
+        "models.ClockSyncResponse": {
+            "type": "object",
+            "properties": {
+                "originate": {
+                    "type": "number",
+                    "example": 1704315045123.456
+                },
+                "receive": {
+                    "type": "number",
+                    "example": 1704315045125.789
+                },
+                "receive_ns": {
+                    "type": "integer",
+                    "example": 1704315045125789000
+                },
+                "transmit": {
+                    "type": "number",
+                    "example": 1704315045125.812
+                },
+                "transmit_ns": {
+                    "type": "integer",
+                    "example": 1704315045125812000
                 }
             }
         },
-        "/time": {
-            "get": {
-                "tags": [
-                    "Time"
-                ],
-                "summary": "Get current time",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Timezone (default UTC)",
-                        "name": "timezone",
-                        "in": "query"
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.TimeResponse"
-                        }
-                    }
+        "models.ConfigResponse": {
+            "type": "object",
+            "properties": {
+                "config": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "file": {
+                    "type": "string",
+                    "example": "/opt/gotimedate/config.yaml"
+                },
+                "last_reload": {
+                    "$ref": "#/definitions/models.ReloadResult"
                 }
             }
         },
-        "/time/convert": {
-            "post": {
-                "tags": [
-                    "Time"
-                ],
-                "summary": "Convert time",
-                "parameters": [
-                    {
-                        "description": "Conversion request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.TimeConvertRequest"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.TimeConvertResponse"
-                        }
-                    }
+        "models.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "error": {
+                    "type": "string",
+                    "example": "Invalid timezone"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "The specified timezone is not supported"
                 }
             }
         },
-        "/time/{timezone}": {
-            "get": {
-                "tags": [
-                    "Time"
-                ],
-                "summary": "Get time by timezone",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Timezone",
-                        "name": "timezone",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.TimeResponse"
-                        }
-                    }
+        "models.HealthCheck": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "68 zones available"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "pass"
                 }
             }
         },
-        "/timezones": {
-            "get": {
-                "tags": [
-                    "Time"
-                ],
-                "summary": "Get available timezones",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.TimezoneInfo"
-                            }
-                        }
-                    }
-                }
-            }
-        }
-    },
-    "definitions": {
         "models.HealthResponse": {
             "type": "object",
             "properties": {
+                "build_date": {
+                    "type": "string",
+                    "example": "2024-01-03T12:00:00Z"
+                },
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.HealthCheck"
+                    }
+                },
+                "commit": {
+                    "type": "string",
+                    "example": "86bae18"
+                },
                 "status": {
                     "type": "string",
                     "example": "healthy"
@@ -139,12 +995,171 @@ const docTemplate = `{
                     "type": "string",
                     "example": "2024-01-03T14:30:45Z"
                 },
+                "uptime": {
+                    "type": "string",
+                    "example": "72h3m12s"
+                },
                 "version": {
                     "type": "string",
                     "example": "1.0.0"
                 }
             }
         },
+        "models.ParseMatch": {
+            "type": "object",
+            "properties": {
+                "end": {
+                    "type": "integer",
+                    "example": 25
+                },
+                "start": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "text": {
+                    "type": "string",
+                    "example": "tomorrow at noon in Tokyo"
+                }
+            }
+        },
+        "models.ParseRequest": {
+            "type": "object",
+            "properties": {
+                "reference": {
+                    "type": "string",
+                    "example": "2024-01-03T14:30:45Z"
+                },
+                "text": {
+                    "type": "string",
+                    "example": "tomorrow at noon in Tokyo"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "UTC"
+                }
+            }
+        },
+        "models.ParseResponse": {
+            "type": "object",
+            "properties": {
+                "ambiguous": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "confidence": {
+                    "type": "number",
+                    "example": 1
+                },
+                "input": {
+                    "type": "string",
+                    "example": "tomorrow at noon in Tokyo"
+                },
+                "match": {
+                    "$ref": "#/definitions/models.ParseMatch"
+                },
+                "reference": {
+                    "type": "string",
+                    "example": "2024-01-03T23:30:45+09:00"
+                },
+                "resolved": {
+                    "$ref": "#/definitions/models.TimeResponse"
+                }
+            }
+        },
+        "models.ProbeResponse": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.HealthCheck"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "models.RelativeTimeResponse": {
+            "type": "object",
+            "properties": {
+                "delta_seconds": {
+                    "type": "number",
+                    "example": 10800
+                },
+                "direction": {
+                    "type": "string",
+                    "example": "future"
+                },
+                "locale": {
+                    "type": "string",
+                    "example": "en"
+                },
+                "reference": {
+                    "type": "string",
+                    "example": "2024-01-03T14:30:45Z"
+                },
+                "text": {
+                    "type": "string",
+                    "example": "in 3 hours"
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2024-01-03T17:30:45Z"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "UTC"
+                },
+                "unit": {
+                    "type": "string",
+                    "example": "hour"
+                },
+                "value": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "models.ReloadResult": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "allowed_origins",
+                        "log_level"
+                    ]
+                },
+                "error": {
+                    "type": "string"
+                },
+                "restart_required": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "port"
+                    ]
+                },
+                "status": {
+                    "type": "string",
+                    "example": "applied"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string",
+                    "example": "sighup"
+                }
+            }
+        },
         "models.TimeConvertRequest": {
             "type": "object",
             "properties": {
@@ -192,10 +1207,18 @@ const docTemplate = `{
                     "type": "string",
                     "example": "2:30:45 PM"
                 },
+                "locale": {
+                    "type": "string",
+                    "example": "en"
+                },
                 "timestamp": {
                     "type": "string",
                     "example": "2024-01-03T14:30:45Z"
                 },
+                "timestamp_nano": {
+                    "type": "string",
+                    "example": "2024-01-03T14:30:45.123456789Z"
+                },
                 "timezone": {
                     "type": "string",
                     "example": "UTC"
@@ -203,6 +1226,22 @@ const docTemplate = `{
                 "unix": {
                     "type": "integer",
                     "example": 1704315045
+                },
+                "unix_ms": {
+                    "type": "integer",
+                    "example": 1704315045123
+                },
+                "unix_ns": {
+                    "type": "integer",
+                    "example": 1704315045123456789
+                },
+                "unix_offset": {
+                    "type": "integer",
+                    "example": -18000
+                },
+                "unix_us": {
+                    "type": "integer",
+                    "example": 1704315045123456
                 }
             }
         },
@@ -222,10 +1261,83 @@ const docTemplate = `{
                     "example": -5
                 }
             }
+        },
+        "models.WebSocketMessage": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "subscribe"
+                },
+                "alarm_id": {
+                    "type": "string",
+                    "example": "7f9c2ba4-e88f-4e0b-9c3a-7d3a1c5e2b10"
+                },
+                "data": {},
+                "format": {
+                    "type": "string",
+                    "example": "12hour"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "tokyo"
+                },
+                "interval": {
+                    "type": "string",
+                    "example": "5s"
+                },
+                "locale": {
+                    "type": "string",
+                    "example": "de"
+                },
+                "on_change": {
+                    "type": "string",
+                    "example": "minute"
+                },
+                "originate": {
+                    "type": "number",
+                    "example": 1704315045123.456
+                },
+                "precision": {
+                    "type": "string",
+                    "example": "ms"
+                },
+                "target": {
+                    "type": "string",
+                    "example": "2024-01-04T00:00:00Z"
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2024-01-03T14:30:45Z"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "America/New_York"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "time_update"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key, required when AUTH_ENABLED is set",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT or API key as \"Bearer \u003ctoken\u003e\", required when AUTH_ENABLED is set",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
 
+// SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0.0",
 	Host:             "localhost:8080",
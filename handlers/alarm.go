@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gotimedate/config"
+	"gotimedate/models"
+	"gotimedate/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AlarmHandler struct {
+	alarms    *services.AlarmService
+	webhooks  *services.WebhookSender
+	defaultTZ tzVar
+}
+
+// NewAlarmHandler loads the alarm store and delivers fired alarms to hub
+// subscribers and to each alarm's webhook.
+func NewAlarmHandler(cfg *config.Config, hub *Hub) (*AlarmHandler, error) {
+	alarms, err := services.NewAlarmService(services.NewFileAlarmStore(cfg.AlarmsFile))
+	if err != nil {
+		return nil, err
+	}
+	h := &AlarmHandler{
+		alarms:   alarms,
+		webhooks: services.NewWebhookSender(cfg.WebhookSecret, cfg.WebhookAttempts, seconds(cfg.WebhookTimeout, 10*time.Second), cfg.WebhookAllowPrivate),
+	}
+	h.defaultTZ.Store(cfg.DefaultTimezone)
+	hub.alarms.Store(true)
+	alarms.OnFire(func(a models.Alarm, ev models.AlarmEvent) {
+		hub.PublishAlarm(ev)
+		if a.WebhookURL != "" {
+			go func() {
+				if err := h.webhooks.Send(context.Background(), a.WebhookURL, ev); err != nil {
+					slog.Error("Alarm webhook failed", "alarm_id", a.ID, "error", err)
+				}
+			}()
+		}
+	})
+	return h, nil
+}
+
+// SetDefaultTimezone changes the timezone of alarms created without one.
+func (h *AlarmHandler) SetDefaultTimezone(tz string) {
+	h.defaultTZ.Store(tz)
+}
+
+// Close stops the alarm scheduler.
+func (h *AlarmHandler) Close() {
+	h.alarms.Close()
+}
+
+func alarmError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrAlarmNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidAlarm):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		return err
+	}
+}
+
+func (h *AlarmHandler) parseRequest(c *fiber.Ctx) (*models.AlarmRequest, error) {
+	var req models.AlarmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid body")
+	}
+	if req.Timezone == "" {
+		req.Timezone = h.defaultTZ.Load()
+	}
+	return &req, nil
+}
+
+// @Summary List alarms
+// @Tags Alarms
+// @Success 200 {array} models.Alarm
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /alarms [get]
+func (h *AlarmHandler) ListAlarms(c *fiber.Ctx) error {
+	return c.JSON(h.alarms.List())
+}
+
+// @Summary Create alarm
+// @Description Schedules a one-shot (date) or repeating (days) alarm delivered over WebSocket and to an optional webhook
+// @Tags Alarms
+// @Param request body models.AlarmRequest true "Alarm"
+// @Success 201 {object} models.Alarm
+// @Failure 400 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /alarms [post]
+func (h *AlarmHandler) CreateAlarm(c *fiber.Ctx) error {
+	req, err := h.parseRequest(c)
+	if err != nil {
+		return err
+	}
+	alarm, err := h.alarms.Create(req)
+	if err != nil {
+		return alarmError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(alarm)
+}
+
+// @Summary Get alarm
+// @Tags Alarms
+// @Param id path string true "Alarm ID"
+// @Success 200 {object} models.Alarm
+// @Failure 404 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /alarms/{id} [get]
+func (h *AlarmHandler) GetAlarm(c *fiber.Ctx) error {
+	alarm, err := h.alarms.Get(c.Params("id"))
+	if err != nil {
+		return alarmError(err)
+	}
+	return c.JSON(alarm)
+}
+
+// @Summary Replace alarm
+// @Tags Alarms
+// @Param id path string true "Alarm ID"
+// @Param request body models.AlarmRequest true "Alarm"
+// @Success 200 {object} models.Alarm
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /alarms/{id} [put]
+func (h *AlarmHandler) UpdateAlarm(c *fiber.Ctx) error {
+	req, err := h.parseRequest(c)
+	if err != nil {
+		return err
+	}
+	alarm, err := h.alarms.Update(c.Params("id"), req)
+	if err != nil {
+		return alarmError(err)
+	}
+	return c.JSON(alarm)
+}
+
+// @Summary Delete alarm
+// @Tags Alarms
+// @Param id path string true "Alarm ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /alarms/{id} [delete]
+func (h *AlarmHandler) DeleteAlarm(c *fiber.Ctx) error {
+	if err := h.alarms.Delete(c.Params("id")); err != nil {
+		return alarmError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
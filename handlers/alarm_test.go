@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gotimedate/config"
+	"gotimedate/models"
+	"gotimedate/services"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newAlarmApp(t *testing.T, cfg *config.Config) (*fiber.App, *WSHandler) {
+	t.Helper()
+	ws := newWSHandler(t, cfg)
+	h, err := NewAlarmHandler(cfg, ws.Hub())
+	if err != nil {
+		t.Fatalf("failed to create alarm handler: %v", err)
+	}
+	t.Cleanup(h.Close)
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		code := fiber.StatusInternalServerError
+		if e, ok := err.(*fiber.Error); ok {
+			code = e.Code
+		}
+		return c.Status(code).JSON(fiber.Map{"message": err.Error()})
+	}})
+	app.Get("/alarms", h.ListAlarms)
+	app.Post("/alarms", h.CreateAlarm)
+	app.Get("/alarms/:id", h.GetAlarm)
+	app.Put("/alarms/:id", h.UpdateAlarm)
+	app.Delete("/alarms/:id", h.DeleteAlarm)
+	return app, ws
+}
+
+func sendJSON(t *testing.T, app *fiber.App, method, path string, body interface{}) (*http.Response, []byte) {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		r = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return resp, data
+}
+
+func TestAlarmHandler_CRUD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.json")
+	app, _ := newAlarmApp(t, &config.Config{DefaultTimezone: "Asia/Kuala_Lumpur", AlarmsFile: path})
+
+	resp, body := sendJSON(t, app, "POST", "/alarms", models.AlarmRequest{Name: "standup", Time: "09:00", Days: []string{"weekdays"}})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.StatusCode, body)
+	}
+	var alarm models.Alarm
+	json.Unmarshal(body, &alarm)
+	if alarm.Timezone != "Asia/Kuala_Lumpur" || alarm.NextFire == "" {
+		t.Errorf("expected default timezone and next fire, got %+v", alarm)
+	}
+
+	resp, body = sendJSON(t, app, "GET", "/alarms", nil)
+	var list []models.Alarm
+	json.Unmarshal(body, &list)
+	if resp.StatusCode != http.StatusOK || len(list) != 1 || list[0].ID != alarm.ID {
+		t.Errorf("expected list with the alarm, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, body = sendJSON(t, app, "PUT", "/alarms/"+alarm.ID, models.AlarmRequest{Time: "10:15", Timezone: "UTC"})
+	json.Unmarshal(body, &alarm)
+	if resp.StatusCode != http.StatusOK || alarm.Time != "10:15" {
+		t.Errorf("expected updated alarm, got %d %s", resp.StatusCode, body)
+	}
+
+	if resp, _ := sendJSON(t, app, "DELETE", "/alarms/"+alarm.ID, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", resp.StatusCode)
+	}
+	if resp, _ := sendJSON(t, app, "GET", "/alarms/"+alarm.ID, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestAlarmHandler_Validation(t *testing.T) {
+	app, _ := newAlarmApp(t, &config.Config{DefaultTimezone: "UTC"})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+	}{
+		{"bad time", "POST", "/alarms", models.AlarmRequest{Time: "noon"}, http.StatusBadRequest},
+		{"bad timezone", "POST", "/alarms", models.AlarmRequest{Time: "12:00", Timezone: "Mars/Olympus"}, http.StatusBadRequest},
+		{"bad day", "POST", "/alarms", models.AlarmRequest{Time: "12:00", Days: []string{"caturday"}}, http.StatusBadRequest},
+		{"past date", "POST", "/alarms", models.AlarmRequest{Time: "12:00", Date: "2001-01-01"}, http.StatusBadRequest},
+		{"bad webhook", "POST", "/alarms", models.AlarmRequest{Time: "12:00", WebhookURL: "not a url"}, http.StatusBadRequest},
+		{"update missing", "PUT", "/alarms/nope", models.AlarmRequest{Time: "12:00"}, http.StatusNotFound},
+		{"delete missing", "DELETE", "/alarms/nope", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp, body := sendJSON(t, app, tt.method, tt.path, tt.body); resp.StatusCode != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, resp.StatusCode, body)
+			}
+		})
+	}
+}
+
+func TestAlarmDelivery(t *testing.T) {
+	hooks := make(chan *http.Request, 1)
+	hookBodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hooks <- r
+		hookBodies <- body
+	}))
+	defer server.Close()
+
+	app, ws := newAlarmApp(t, &config.Config{DefaultTimezone: "UTC", WebhookSecret: "s3cret", WebhookAllowPrivate: true})
+	conn := dialWS(t, startWSServer(t, ws))
+	conn.WriteJSON(models.WebSocketMessage{Action: "alarms", ID: "mine"})
+	if msg := readReply(t, conn); msg.Type != "ack" || msg.Action != "alarms" {
+		t.Fatalf("expected alarms ack, got %+v", msg)
+	}
+
+	at := time.Now().Add(time.Second).Truncate(time.Second).Add(time.Second)
+	resp, body := sendJSON(t, app, "POST", "/alarms", models.AlarmRequest{
+		Name:       "ping",
+		Time:       at.UTC().Format("15:04:05"),
+		WebhookURL: server.URL,
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.StatusCode, body)
+	}
+	var alarm models.Alarm
+	json.Unmarshal(body, &alarm)
+
+	msg := readReply(t, conn)
+	if msg.Type != "alarm" || msg.ID != "mine" {
+		t.Fatalf("expected alarm event, got %+v", msg)
+	}
+	if data := msg.Data.(map[string]interface{}); data["alarm_id"] != alarm.ID {
+		t.Errorf("expected alarm %s, got %v", alarm.ID, data["alarm_id"])
+	}
+
+	select {
+	case r := <-hooks:
+		body := <-hookBodies
+		want := services.SignWebhook([]byte("s3cret"), r.Header.Get(services.WebhookTimestampHeader), body)
+		if got := r.Header.Get(services.WebhookSignatureHeader); got != want {
+			t.Errorf("expected signature %s, got %s", want, got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected webhook delivery")
+	}
+}
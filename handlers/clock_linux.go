@@ -0,0 +1,21 @@
+package handlers
+
+import "syscall"
+
+// Kernel clock state values from <sys/timex.h>.
+const (
+	timeError = 5    // TIME_ERROR: the clock is not synchronized
+	staUnsync = 0x40 // STA_UNSYNC
+)
+
+// ntpSynchronized reads the kernel's clock discipline state. It reports
+// whether an NTP daemon keeps the clock synchronized, and false for ok when
+// the state cannot be read.
+func ntpSynchronized() (synced, ok bool) {
+	var tx syscall.Timex
+	state, err := syscall.Adjtimex(&tx)
+	if err != nil {
+		return false, false
+	}
+	return state != timeError && tx.Status&staUnsync == 0, true
+}
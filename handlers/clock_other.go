@@ -0,0 +1,8 @@
+//go:build !linux
+
+package handlers
+
+// ntpSynchronized reports the NTP sync state, which is only read on Linux.
+func ntpSynchronized() (synced, ok bool) {
+	return false, false
+}
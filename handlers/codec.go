@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// codec encodes WebSocket messages for one subprotocol. Every codec reuses the
+// models' json tags, so field names are identical across encodings.
+type codec struct {
+	name      string
+	index     int // position in codecs, used to cache per-codec payloads
+	frameType int
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+	// encodeID returns what withID splices into a payload for subscription
+	// id; nil for the default subscription.
+	encodeID func(id string) []byte
+	withID   func(payload, encodedID []byte) []byte
+}
+
+var jsonCodec = &codec{
+	name:      "json",
+	frameType: websocket.TextMessage,
+	marshal:   json.Marshal,
+	unmarshal: json.Unmarshal,
+	encodeID: func(id string) []byte {
+		quoted, _ := json.Marshal(id)
+		return quoted
+	},
+	withID: withID,
+}
+
+var msgpackCodec = &codec{
+	name:      "msgpack",
+	frameType: websocket.BinaryMessage,
+	marshal: func(v interface{}) ([]byte, error) {
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	},
+	unmarshal: func(data []byte, v interface{}) error {
+		dec := msgpack.NewDecoder(bytes.NewReader(data))
+		dec.SetCustomStructTag("json")
+		return dec.Decode(v)
+	},
+}
+
+var cborCodec = &codec{
+	name:      "cbor",
+	frameType: websocket.BinaryMessage,
+	marshal:   cbor.Marshal,
+	unmarshal: cbor.Unmarshal,
+}
+
+// codecs lists the supported subprotocols in the server's order of preference:
+// a client offering several gets the most compact. A client that offers none
+// of them gets JSON.
+var codecs = []*codec{msgpackCodec, cborCodec, jsonCodec}
+
+func init() {
+	for i, cd := range codecs {
+		cd.index = i
+	}
+	for _, cd := range []*codec{msgpackCodec, cborCodec} {
+		cd.encodeID = binaryID(cd)
+		cd.withID = mapWithID(cd)
+	}
+}
+
+// Subprotocols returns the Sec-WebSocket-Protocol values the server accepts.
+func Subprotocols() []string {
+	names := make([]string, len(codecs))
+	for i, cd := range codecs {
+		names[i] = cd.name
+	}
+	return names
+}
+
+// codecFor returns the codec negotiated as subprotocol, defaulting to JSON.
+func codecFor(subprotocol string) *codec {
+	for _, cd := range codecs {
+		if cd.name == subprotocol {
+			return cd
+		}
+	}
+	return jsonCodec
+}
+
+// binaryID pre-encodes the "id" key and its value as a map entry.
+func binaryID(cd *codec) func(string) []byte {
+	return func(id string) []byte {
+		k, _ := cd.marshal("id")
+		v, _ := cd.marshal(id)
+		return append(k, v...)
+	}
+}
+
+// mapWithID inserts an encoded "id" entry into a serialized message. MessagePack
+// fixmaps (0x80|n) and small CBOR maps (0xa0|n) keep their entry count in the
+// first byte, so the entry is added by bumping it. WebSocketMessage has fewer
+// fields than either form holds, so its payloads always qualify.
+func mapWithID(cd *codec) func(payload, encodedID []byte) []byte {
+	lo, hi := byte(0x80), byte(0x8f)
+	if cd == cborCodec {
+		lo, hi = 0xa0, 0xb7
+	}
+	return func(payload, encodedID []byte) []byte {
+		if encodedID == nil || len(payload) == 0 || payload[0] < lo || payload[0] >= hi {
+			return payload
+		}
+		out := make([]byte, 0, len(payload)+len(encodedID))
+		out = append(out, payload[0]+1)
+		out = append(out, encodedID...)
+		return append(out, payload[1:]...)
+	}
+}
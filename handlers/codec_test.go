@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/flate"
+	"gotimedate/models"
+	"gotimedate/services"
+	"testing"
+)
+
+// timeFrame decodes a time_update with its data typed, which the binary
+// decoders cannot infer for interface{} fields.
+type timeFrame struct {
+	Type      string              `json:"type"`
+	ID        string              `json:"id"`
+	Data      models.TimeResponse `json:"data"`
+	Timestamp string              `json:"timestamp"`
+}
+
+func TestCodecFor(t *testing.T) {
+	for _, name := range []string{"json", "msgpack", "cbor"} {
+		if cd := codecFor(name); cd.name != name {
+			t.Errorf("expected codec %s, got %s", name, cd.name)
+		}
+	}
+	if cd := codecFor(""); cd != jsonCodec {
+		t.Errorf("expected JSON by default, got %s", cd.name)
+	}
+	if cd := codecFor("xml"); cd != jsonCodec {
+		t.Errorf("expected JSON for unknown subprotocol, got %s", cd.name)
+	}
+}
+
+func TestCodecRoundTripWithID(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), 0, 0, 0)
+	msg, err := hub.render(testKey("Asia/Tokyo"), testTick)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	for _, cd := range codecs {
+		t.Run(cd.name, func(t *testing.T) {
+			payload, err := cd.marshal(msg)
+			if err != nil {
+				t.Fatalf("marshal failed: %v", err)
+			}
+			for _, id := range []string{"", "tokyo", `a"b`} {
+				var encodedID []byte
+				if id != "" {
+					encodedID = cd.encodeID(id)
+				}
+				var got timeFrame
+				if err := cd.unmarshal(cd.withID(payload, encodedID), &got); err != nil {
+					t.Fatalf("unmarshal failed: %v", err)
+				}
+				if got.ID != id {
+					t.Errorf("expected id %q, got %q", id, got.ID)
+				}
+				if got.Type != "time_update" || got.Data.Timezone != "Asia/Tokyo" || got.Data.Unix != testTick.Unix() {
+					t.Errorf("unexpected frame %+v", got)
+				}
+				if got.Timestamp != msg.Timestamp {
+					t.Errorf("expected timestamp %s, got %s", msg.Timestamp, got.Timestamp)
+				}
+			}
+		})
+	}
+}
+
+func TestBroadcastEncodesPerCodec(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), 0, 0, 0)
+	jc, mc := hub.Register(), hub.register(msgpackCodec)
+	hub.Subscribe(jc, "a", testKey("UTC"))
+	hub.Subscribe(mc, "b", testKey("UTC"))
+	hub.Tick(testTick)
+
+	if msg := readUpdate(t, jc); msg.ID != "a" {
+		t.Errorf("expected JSON id a, got %q", msg.ID)
+	}
+	var got timeFrame
+	if err := msgpackCodec.unmarshal(<-mc.Send(), &got); err != nil {
+		t.Fatalf("invalid msgpack payload: %v", err)
+	}
+	if got.ID != "b" || got.Data.Timezone != "UTC" {
+		t.Errorf("unexpected msgpack frame %+v", got)
+	}
+}
+
+// deflated returns the size of payload as a permessage-deflate frame body:
+// compressed without context takeover, minus the trailing empty block.
+func deflated(b *testing.B, payload []byte, level int) int {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w.Write(payload)
+	w.Flush()
+	return buf.Len() - 4
+}
+
+// BenchmarkFrameSize reports the bytes per time_update frame for each
+// subprotocol, with and without permessage-deflate.
+func BenchmarkFrameSize(b *testing.B) {
+	hub := NewHub(services.NewTimeService(), 0, 0, 0)
+	key := testKey("America/New_York")
+	key.opts.Precision = services.PrecisionMillisecond
+	msg, err := hub.render(key, testTick)
+	if err != nil {
+		b.Fatalf("render failed: %v", err)
+	}
+	for _, cd := range codecs {
+		encodedID := cd.encodeID("nyc")
+		b.Run(cd.name, func(b *testing.B) {
+			var payload []byte
+			for i := 0; i < b.N; i++ {
+				payload, _ = cd.marshal(msg)
+				payload = cd.withID(payload, encodedID)
+			}
+			b.ReportMetric(float64(len(payload)), "bytes/frame")
+		})
+		b.Run(cd.name+"+deflate", func(b *testing.B) {
+			payload := cd.withID(mustEncode(b, cd, msg), encodedID)
+			var n int
+			for i := 0; i < b.N; i++ {
+				n = deflated(b, payload, flate.BestSpeed)
+			}
+			b.ReportMetric(float64(n), "bytes/frame")
+		})
+	}
+}
+
+func mustEncode(b *testing.B, cd *codec, v interface{}) []byte {
+	payload, err := cd.marshal(v)
+	if err != nil {
+		b.Fatalf("%s marshal failed: %v", cd.name, err)
+	}
+	return payload
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"gotimedate/config"
+	"gotimedate/models"
+	"log/slog"
+	"os"
+	"syscall"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConfigHandler serves the effective configuration and reloads it on demand.
+type ConfigHandler struct {
+	reloader *config.Reloader
+	// siblings has the other Prefork children reload after this one has.
+	siblings func() error
+}
+
+func NewConfigHandler(reloader *config.Reloader) *ConfigHandler {
+	h := &ConfigHandler{reloader: reloader}
+	if fiber.IsChild() {
+		h.siblings = hangUpMaster
+	}
+	return h
+}
+
+// hangUpMaster sends SIGHUP to the Prefork master, which forwards it to
+// every child.
+func hangUpMaster() error {
+	master, err := os.FindProcess(os.Getppid())
+	if err != nil {
+		return err
+	}
+	return master.Signal(syscall.SIGHUP)
+}
+
+// @Summary Effective configuration
+// @Description The configuration in effect, with secrets redacted, and the result of the last reload
+// @Tags Admin
+// @Success 200 {object} models.ConfigResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/config [get]
+func (h *ConfigHandler) Get(c *fiber.Ctx) error {
+	cfg := h.reloader.Current()
+	return c.JSON(models.ConfigResponse{
+		File:       cfg.File,
+		Config:     cfg.Redacted(),
+		LastReload: h.reloader.LastReload(),
+	})
+}
+
+// @Summary Reload configuration
+// @Description Re-reads the config file and environment and applies the settings that can change at runtime. An invalid config is rejected with 422 and the current one kept. In Prefork mode the result is that of the child answering, and the other children reload after it.
+// @Tags Admin
+// @Success 200 {object} models.ReloadResult
+// @Failure 422 {object} models.ReloadResult
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/config/reload [post]
+func (h *ConfigHandler) Reload(c *fiber.Ctx) error {
+	result := h.reloader.Reload("api")
+	if result.Status == config.ReloadRejected {
+		c.Status(fiber.StatusUnprocessableEntity)
+	} else if h.siblings != nil {
+		if err := h.siblings(); err != nil {
+			slog.ErrorContext(c.UserContext(), "Signalling prefork master to reload failed", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError, "reloaded this worker only")
+		}
+	}
+	return c.JSON(result)
+}
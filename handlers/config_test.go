@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"gotimedate/config"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestConfigReloadSignalsSiblings(t *testing.T) {
+	var loadErr, signalErr error
+	reloader := config.NewReloader(&config.Config{LogLevel: "info"}, func() (*config.Config, error) {
+		return &config.Config{LogLevel: "debug"}, loadErr
+	})
+	h := NewConfigHandler(reloader)
+	signals := 0
+	h.siblings = func() error { signals++; return signalErr }
+	app := fiber.New()
+	app.Post("/reload", h.Reload)
+
+	reload := func() int {
+		t.Helper()
+		req, _ := http.NewRequest("POST", "/reload", nil)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := reload(); status != fiber.StatusOK || signals != 1 {
+		t.Errorf("expected 200 and the siblings signalled once, got %d and %d", status, signals)
+	}
+	loadErr = errors.New("bad config")
+	if status := reload(); status != fiber.StatusUnprocessableEntity || signals != 1 {
+		t.Errorf("expected 422 without signalling a rejected config, got %d and %d", status, signals)
+	}
+	loadErr, signalErr = nil, errors.New("no master")
+	if status := reload(); status != fiber.StatusInternalServerError {
+		t.Errorf("expected 500 when the master cannot be signalled, got %d", status)
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"fmt"
+	"gotimedate/buildinfo"
+	"gotimedate/config"
+	"gotimedate/models"
+	"gotimedate/services"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Health check results.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// HealthHandler serves the liveness, readiness and health endpoints.
+type HealthHandler struct {
+	hub     *Hub
+	logFile string
+	started time.Time
+	now     func() time.Time
+}
+
+// NewHealthHandler checks hub and, unless logs only go to stdout, the
+// writability of the configured log file.
+func NewHealthHandler(cfg *config.Config, hub *Hub) *HealthHandler {
+	h := &HealthHandler{hub: hub, started: time.Now(), now: time.Now}
+	if cfg.LogOutput != "stdout" {
+		h.logFile = cfg.LogFile
+	}
+	return h
+}
+
+// @Summary Liveness probe
+// @Description Answers 200 while the process can serve requests.
+// @Tags Health
+// @Success 200 {object} models.ProbeResponse
+// @Router /livez [get]
+func (h *HealthHandler) Live(c *fiber.Ctx) error {
+	return c.JSON(models.ProbeResponse{Status: "ok"})
+}
+
+// @Summary Readiness probe
+// @Description Answers 200 once tzdata loads and the broadcast hub is ticking, and 503 with the failed checks otherwise, including while the server shuts down.
+// @Tags Health
+// @Success 200 {object} models.ProbeResponse
+// @Failure 503 {object} models.ProbeResponse
+// @Router /readyz [get]
+func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	failed := make(map[string]models.HealthCheck)
+	for name, check := range map[string]func() models.HealthCheck{
+		"tzdata": h.checkTZData,
+		"hub":    h.checkHub,
+	} {
+		if r := check(); r.Status == checkFail {
+			failed[name] = r
+		}
+	}
+	if len(failed) > 0 {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.ProbeResponse{Status: "unavailable", Checks: failed})
+	}
+	return c.JSON(models.ProbeResponse{Status: "ok"})
+}
+
+// @Summary Health check
+// @Description With verbose=1, adds build details, uptime and the tzdata, log file, clock and hub checks. A failed check makes the status unhealthy and the response 503.
+// @Tags Health
+// @Param verbose query bool false "Include build details and checks"
+// @Success 200 {object} models.HealthResponse
+// @Failure 503 {object} models.HealthResponse
+// @Router /health [get]
+func (h *HealthHandler) Health(c *fiber.Ctx) error {
+	now := h.now()
+	resp := models.HealthResponse{Status: "healthy", Timestamp: now, Version: buildinfo.Version}
+	if !c.QueryBool("verbose") {
+		return c.JSON(resp)
+	}
+
+	resp.Commit = buildinfo.Commit
+	resp.BuildDate = buildinfo.Date
+	resp.Uptime = now.Sub(h.started).Round(time.Second).String()
+	resp.Checks = map[string]models.HealthCheck{
+		"tzdata":   h.checkTZData(),
+		"log_file": h.checkLogFile(),
+		"clock":    h.checkClock(),
+		"hub":      h.checkHub(),
+	}
+	for _, r := range resp.Checks {
+		switch {
+		case r.Status == checkFail:
+			resp.Status = "unhealthy"
+		case r.Status == checkWarn && resp.Status == "healthy":
+			resp.Status = "degraded"
+		}
+	}
+	if resp.Status == "unhealthy" {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.JSON(resp)
+}
+
+func (h *HealthHandler) checkTZData() models.HealthCheck {
+	n, err := services.CheckTZData()
+	if err != nil {
+		return models.HealthCheck{Status: checkFail, Message: err.Error()}
+	}
+	return models.HealthCheck{Status: checkPass, Message: fmt.Sprintf("%d zones available", n)}
+}
+
+// checkLogFile opens the log file for appending without writing to it. A
+// log file that cannot be written loses logs but does not stop serving.
+func (h *HealthHandler) checkLogFile() models.HealthCheck {
+	if h.logFile == "" {
+		return models.HealthCheck{Status: checkPass, Message: "logging to stdout only"}
+	}
+	f, err := os.OpenFile(h.logFile, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return models.HealthCheck{Status: checkWarn, Message: err.Error()}
+	}
+	f.Close()
+	return models.HealthCheck{Status: checkPass, Message: h.logFile}
+}
+
+// checkClock fails when the system clock reads earlier than the build date,
+// which only a clock that was never set can do, and warns when the kernel
+// reports it is not NTP-synchronized.
+func (h *HealthHandler) checkClock() models.HealthCheck {
+	now := h.now()
+	if built, ok := buildinfo.BuildTime(); ok && now.Before(built) {
+		return models.HealthCheck{Status: checkFail, Message: fmt.Sprintf("system clock %s is before the build date %s", now.UTC().Format(time.RFC3339), buildinfo.Date)}
+	}
+	synced, ok := ntpSynchronized()
+	switch {
+	case !ok:
+		return models.HealthCheck{Status: checkPass, Message: "NTP sync status unavailable"}
+	case !synced:
+		return models.HealthCheck{Status: checkWarn, Message: "system clock is not NTP-synchronized"}
+	}
+	return models.HealthCheck{Status: checkPass, Message: "NTP-synchronized"}
+}
+
+// checkHub fails once the hub shuts down or when its ticker has stalled.
+func (h *HealthHandler) checkHub() models.HealthCheck {
+	if h.hub.shuttingDown() {
+		return models.HealthCheck{Status: checkFail, Message: "shutting down"}
+	}
+	last := h.hub.lastTick.Load()
+	if last == 0 {
+		return models.HealthCheck{Status: checkFail, Message: "has not ticked yet"}
+	}
+	age := h.now().Sub(time.Unix(0, last))
+	if age > 3*h.hub.resolution+time.Second {
+		return models.HealthCheck{Status: checkFail, Message: fmt.Sprintf("last tick %s ago", age.Round(time.Millisecond))}
+	}
+	stats := h.hub.Stats()
+	return models.HealthCheck{Status: checkPass, Message: fmt.Sprintf("%d clients, %d streams", stats.Clients, stats.Streams)}
+}
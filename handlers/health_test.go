@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gotimedate/buildinfo"
+	"gotimedate/config"
+	"gotimedate/models"
+	"gotimedate/services"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func healthApp(h *HealthHandler) *fiber.App {
+	app := fiber.New()
+	app.Get("/health", h.Health)
+	app.Get("/livez", h.Live)
+	app.Get("/readyz", h.Ready)
+	return app
+}
+
+func getJSON(t *testing.T, app *fiber.App, path string, v interface{}) int {
+	t.Helper()
+	req, _ := http.NewRequest("GET", path, nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("invalid body %s: %v", body, err)
+	}
+	return resp.StatusCode
+}
+
+// tickedHub returns a hub that has just ticked, as a running hub would have.
+func tickedHub() *Hub {
+	hub := NewHub(services.NewTimeService(), time.Second, time.Minute, 2)
+	hub.Tick(time.Now())
+	return hub
+}
+
+func TestHealthHandler_Health(t *testing.T) {
+	h := NewHealthHandler(&config.Config{LogOutput: "stdout"}, tickedHub())
+	app := healthApp(h)
+
+	var healthResp models.HealthResponse
+	if status := getJSON(t, app, "/health", &healthResp); status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if healthResp.Status != "healthy" {
+		t.Errorf("handler returned unexpected body: got %v want %v", healthResp.Status, "healthy")
+	}
+	if healthResp.Version != buildinfo.Version || healthResp.Checks != nil {
+		t.Errorf("expected the build version and no checks, got %+v", healthResp)
+	}
+}
+
+func TestHealthHandler_Verbose(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "server.log")
+	h := NewHealthHandler(&config.Config{LogOutput: "both", LogFile: logFile}, tickedHub())
+	h.started = time.Now().Add(-90 * time.Minute)
+	app := healthApp(h)
+
+	var resp models.HealthResponse
+	getJSON(t, app, "/health?verbose=1", &resp)
+	if resp.Uptime != "1h30m0s" {
+		t.Errorf("expected uptime 1h30m0s, got %q", resp.Uptime)
+	}
+	for _, name := range []string{"tzdata", "log_file", "clock", "hub"} {
+		if _, ok := resp.Checks[name]; !ok {
+			t.Errorf("expected a %s check, got %+v", name, resp.Checks)
+		}
+	}
+	if c := resp.Checks["tzdata"]; c.Status != checkPass {
+		t.Errorf("expected tzdata to pass, got %+v", c)
+	}
+	if c := resp.Checks["hub"]; c.Status != checkPass {
+		t.Errorf("expected hub to pass, got %+v", c)
+	}
+	// The log file does not exist yet.
+	if c := resp.Checks["log_file"]; c.Status != checkWarn || resp.Status == "healthy" {
+		t.Errorf("expected an unwritable log file to degrade health, got %+v (%s)", c, resp.Status)
+	}
+}
+
+func TestHealthHandler_ClockBeforeBuild(t *testing.T) {
+	defer func(d string) { buildinfo.Date = d }(buildinfo.Date)
+	buildinfo.Date = time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	h := NewHealthHandler(&config.Config{LogOutput: "stdout"}, tickedHub())
+	var resp models.HealthResponse
+	if status := getJSON(t, healthApp(h), "/health?verbose=true", &resp); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", status)
+	}
+	if resp.Status != "unhealthy" || resp.Checks["clock"].Status != checkFail {
+		t.Errorf("expected a failed clock check, got %+v", resp)
+	}
+}
+
+func TestHealthHandler_Probes(t *testing.T) {
+	hub := tickedHub()
+	h := NewHealthHandler(&config.Config{LogOutput: "stdout"}, hub)
+	app := healthApp(h)
+
+	var probe models.ProbeResponse
+	if status := getJSON(t, app, "/livez", &probe); status != http.StatusOK || probe.Status != "ok" {
+		t.Errorf("expected live, got %d %+v", status, probe)
+	}
+	if status := getJSON(t, app, "/readyz", &probe); status != http.StatusOK || probe.Status != "ok" {
+		t.Errorf("expected ready, got %d %+v", status, probe)
+	}
+
+	h.now = func() time.Time { return time.Now().Add(time.Minute) }
+	probe = models.ProbeResponse{}
+	if status := getJSON(t, app, "/readyz", &probe); status != http.StatusServiceUnavailable || probe.Checks["hub"].Status != checkFail {
+		t.Errorf("expected a stalled hub to be unready, got %d %+v", status, probe)
+	}
+
+	h.now = time.Now
+	hub.Shutdown(models.GoingAway{Reason: "server shutting down"})
+	probe = models.ProbeResponse{}
+	if status := getJSON(t, app, "/readyz", &probe); status != http.StatusServiceUnavailable || probe.Checks["hub"].Message != "shutting down" {
+		t.Errorf("expected unready while shutting down, got %d %+v", status, probe)
+	}
+	if status := getJSON(t, app, "/livez", &probe); status != http.StatusOK {
+		t.Errorf("expected live while shutting down, got %d", status)
+	}
+}
@@ -0,0 +1,604 @@
+package handlers
+
+import (
+	"bytes"
+	"gotimedate/models"
+	"gotimedate/services"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// streamKey identifies a distinct stream. Subscribers sharing a key receive
+// the same pre-serialized payload. A non-zero onChange replaces the interval
+// with pushes at the selected wall-clock changes in the timezone. A non-zero
+// target (Unix nanoseconds) makes it a countdown stream that ends with
+// countdown_complete at that instant. A non-empty alarm makes it an event
+// stream that never ticks and only carries alarms published for that ID, or
+// for any ID when it is allAlarms.
+type streamKey struct {
+	timezone string
+	opts     services.TimeOptions
+	interval time.Duration
+	onChange changeMode
+	target   int64
+	alarm    string
+}
+
+// changeMode is a set of wall-clock changes that trigger an on-change push.
+type changeMode uint8
+
+const (
+	changeMinute changeMode = 1 << iota
+	changeHour
+	changeDay
+	changeDST
+)
+
+// changeModes maps the names accepted in on_change to their modes.
+var changeModes = map[string]changeMode{
+	"minute":   changeMinute,
+	"hour":     changeHour,
+	"day":      changeDay,
+	"midnight": changeDay,
+	"dst":      changeDST,
+}
+
+// String lists the modes in canonical order, comma-separated.
+func (m changeMode) String() string {
+	var names []string
+	for _, n := range []string{"minute", "hour", "day", "dst"} {
+		if m&changeModes[n] != 0 {
+			names = append(names, n)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// changed reports whether a selected wall-clock change happens at now in loc.
+// Boundaries are read from the local clock, so an hour boundary in a +05:30
+// zone falls at :30 UTC, and a DST jump counts as a minute and hour boundary
+// because the displayed time jumps. Day changes compare calendar dates, which
+// also catches zones whose DST transition skips midnight.
+func (m changeMode) changed(loc *time.Location, now time.Time) bool {
+	if now.Nanosecond() != 0 {
+		return false
+	}
+	local := now.In(loc)
+	before := now.Add(-time.Second).In(loc)
+	_, offset := local.Zone()
+	_, prevOffset := before.Zone()
+	dst := offset != prevOffset
+	_, min, sec := local.Clock()
+	switch {
+	case m&changeDST != 0 && dst:
+		return true
+	case m&changeDay != 0 && local.YearDay() != before.YearDay():
+		return true
+	case m&changeHour != 0 && (dst || min == 0 && sec == 0):
+		return true
+	case m&changeMinute != 0 && (dst || sec == 0):
+		return true
+	}
+	return false
+}
+
+// allAlarms is the alarm stream ID that receives every alarm.
+const allAlarms = "*"
+
+// member is one subscription of a client to a stream. encodedID is the
+// subscription ID in the client's encoding, spliced into the shared payload,
+// and is nil for the default subscription.
+type member struct {
+	client    *Client
+	id        string
+	encodedID []byte
+}
+
+// stream holds the members of one streamKey. The members slice is replaced
+// rather than mutated so ticks can read it without holding the hub lock.
+// Countdown streams own the timer that fires their completion.
+type stream struct {
+	key     streamKey
+	members []member
+	timer   *time.Timer
+	loc     *time.Location // on-change streams only
+}
+
+// HubStats is a point-in-time snapshot of hub activity.
+type HubStats struct {
+	Clients  int    `json:"clients"`
+	Streams  int    `json:"streams"`
+	Sent     uint64 `json:"messages_sent"`
+	Evicted  uint64 `json:"evicted"`
+	LastTick string `json:"last_tick,omitempty"`
+}
+
+// Hub drives every time_update and countdown stream from a single ticker
+// aligned to the wall-clock resolution boundary. Each distinct stream is
+// rendered and serialized once per tick and fanned out to bounded per-client
+// queues; clients whose queue is full are evicted as slow consumers.
+type Hub struct {
+	timeService *services.TimeService
+	resolution  time.Duration
+	maxInterval time.Duration
+	queueSize   int
+
+	mu      sync.Mutex
+	streams map[streamKey]*stream
+	clients map[*Client]map[string]streamKey
+
+	sent     atomic.Uint64
+	evicted  atomic.Uint64
+	lastTick atomic.Int64
+	// alarms is set once an AlarmHandler publishes to the hub.
+	alarms atomic.Bool
+
+	// notice is set by Shutdown; clients registered after it are turned
+	// away at once.
+	notice *models.GoingAway
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewHub creates a hub that ticks every resolution, which is also the
+// shortest interval a stream may use; maxInterval is the longest.
+func NewHub(timeService *services.TimeService, resolution, maxInterval time.Duration, queueSize int) *Hub {
+	if resolution <= 0 {
+		resolution = 100 * time.Millisecond
+	}
+	if maxInterval <= 0 {
+		maxInterval = time.Minute
+	}
+	if maxInterval < resolution {
+		maxInterval = resolution
+	}
+	if queueSize <= 0 {
+		queueSize = 16
+	}
+	return &Hub{
+		timeService: timeService,
+		resolution:  resolution,
+		maxInterval: maxInterval,
+		queueSize:   queueSize,
+		streams:     make(map[streamKey]*stream),
+		clients:     make(map[*Client]map[string]streamKey),
+		stop:        make(chan struct{}),
+	}
+}
+
+// replyQueue is how many replies a client may have waiting to be rendered.
+const replyQueue = 4
+
+// Client is a hub subscriber with a bounded send queue of messages already
+// encoded with its codec, and a short queue of replies rendered only when
+// they are written. Done is closed when the client is unregistered or
+// evicted.
+type Client struct {
+	hub       *Hub
+	codec     *codec
+	send      chan []byte
+	replies   chan func() interface{}
+	done      chan struct{}
+	closeOnce sync.Once
+	evicted   atomic.Bool
+	goingAway atomic.Pointer[models.GoingAway]
+}
+
+// Send returns the client's outbound queue.
+func (c *Client) Send() <-chan []byte {
+	return c.send
+}
+
+// Done is closed once the client must stop writing.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Evicted reports whether the client was dropped as a slow consumer.
+func (c *Client) Evicted() bool {
+	return c.evicted.Load()
+}
+
+// GoingAway returns the shutdown notice when the client was disconnected
+// because the hub shut down, and nil otherwise.
+func (c *Client) GoingAway() *models.GoingAway {
+	return c.goingAway.Load()
+}
+
+// Enqueue queues a message without blocking. A full queue evicts the client.
+func (c *Client) Enqueue(msg []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		c.hub.sent.Add(1)
+		return true
+	default:
+		c.evicted.Store(true)
+		c.hub.evicted.Add(1)
+		c.hub.Unregister(c)
+		return false
+	}
+}
+
+// EnqueueReply queues a reply that render builds when it is written, ahead
+// of queued updates, so that timestamps in it are taken just before the
+// write. A full reply queue evicts the client.
+func (c *Client) EnqueueReply(render func() interface{}) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.replies <- render:
+		return true
+	default:
+		c.evicted.Store(true)
+		c.hub.evicted.Add(1)
+		c.hub.Unregister(c)
+		return false
+	}
+}
+
+// EnqueueMessage encodes v with the client's codec and queues it.
+func (c *Client) EnqueueMessage(v interface{}) bool {
+	msg, err := c.codec.marshal(v)
+	if err != nil {
+		return false
+	}
+	return c.Enqueue(msg)
+}
+
+// Register adds a JSON client with no subscriptions.
+func (h *Hub) Register() *Client {
+	return h.register(jsonCodec)
+}
+
+// register adds a client whose messages are encoded with cd.
+func (h *Hub) register(cd *codec) *Client {
+	c := &Client{
+		hub:     h,
+		codec:   cd,
+		send:    make(chan []byte, h.queueSize),
+		replies: make(chan func() interface{}, replyQueue),
+		done:    make(chan struct{}),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.notice != nil {
+		c.goingAway.Store(h.notice)
+		c.closeOnce.Do(func() { close(c.done) })
+		return c
+	}
+	h.clients[c] = make(map[string]streamKey)
+	return c
+}
+
+// Unregister removes all of the client's subscriptions and closes Done.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	if subs, ok := h.clients[c]; ok {
+		for id, key := range subs {
+			h.removeMember(key, c, id)
+		}
+		delete(h.clients, c)
+	}
+	h.mu.Unlock()
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// Subscribe points the client's subscription id at key, replacing any stream
+// it was previously attached to. It returns false for unknown clients.
+func (h *Hub) Subscribe(c *Client, id string, key streamKey) bool {
+	var encodedID []byte
+	if id != "" {
+		encodedID = c.codec.encodeID(id)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs, ok := h.clients[c]
+	if !ok {
+		return false
+	}
+	if old, ok := subs[id]; ok {
+		h.removeMember(old, c, id)
+	}
+	subs[id] = key
+	s, ok := h.streams[key]
+	if !ok {
+		s = &stream{key: key}
+		if key.onChange != 0 {
+			s.loc, _ = services.LoadLocation(key.timezone)
+		}
+		if key.target != 0 {
+			s.timer = time.AfterFunc(time.Until(time.Unix(0, key.target)), func() { h.complete(key) })
+		}
+		h.streams[key] = s
+	}
+	members := make([]member, len(s.members), len(s.members)+1)
+	copy(members, s.members)
+	s.members = append(members, member{client: c, id: id, encodedID: encodedID})
+	return true
+}
+
+// Unsubscribe detaches the client's subscription id and reports whether it
+// existed.
+func (h *Hub) Unsubscribe(c *Client, id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if subs, ok := h.clients[c]; ok {
+		if key, ok := subs[id]; ok {
+			h.removeMember(key, c, id)
+			delete(subs, id)
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribed reports whether the client holds subscription id.
+func (h *Hub) Subscribed(c *Client, id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.clients[c][id]
+	return ok
+}
+
+// SubscriptionCount returns how many subscriptions the client holds.
+func (h *Hub) SubscriptionCount(c *Client) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients[c])
+}
+
+// removeMember must be called with h.mu held.
+func (h *Hub) removeMember(key streamKey, c *Client, id string) {
+	s, ok := h.streams[key]
+	if !ok {
+		return
+	}
+	members := make([]member, 0, len(s.members))
+	for _, m := range s.members {
+		if m.client == c && m.id == id {
+			continue
+		}
+		members = append(members, m)
+	}
+	if len(members) == 0 {
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		delete(h.streams, key)
+		return
+	}
+	s.members = members
+}
+
+// Stats returns a snapshot of the hub counters.
+func (h *Hub) Stats() HubStats {
+	h.mu.Lock()
+	stats := HubStats{Clients: len(h.clients), Streams: len(h.streams)}
+	h.mu.Unlock()
+	stats.Sent = h.sent.Load()
+	stats.Evicted = h.evicted.Load()
+	if last := h.lastTick.Load(); last != 0 {
+		stats.LastTick = time.Unix(0, last).UTC().Format(time.RFC3339Nano)
+	}
+	return stats
+}
+
+// Run ticks until Stop is called. Each tick lands on a multiple of the
+// resolution so every client sees the same second boundary.
+func (h *Hub) Run() {
+	res := int64(h.resolution)
+	for {
+		next := time.Unix(0, (time.Now().UnixNano()/res+1)*res)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			h.Tick(next)
+		case <-h.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Stop ends Run and cancels pending countdown completions.
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+		h.mu.Lock()
+		for _, s := range h.streams {
+			if s.timer != nil {
+				s.timer.Stop()
+			}
+		}
+		h.mu.Unlock()
+	})
+}
+
+// Shutdown stops the hub and disconnects every client with notice, which
+// their transports pass on before closing.
+func (h *Hub) Shutdown(notice models.GoingAway) {
+	h.Stop()
+	h.mu.Lock()
+	h.notice = &notice
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+	for _, c := range clients {
+		c.goingAway.Store(&notice)
+		h.Unregister(c)
+	}
+}
+
+// shuttingDown reports whether Shutdown has been called.
+func (h *Hub) shuttingDown() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.notice != nil
+}
+
+// Tick renders every stream due at now and fans it out.
+func (h *Hub) Tick(now time.Time) {
+	h.lastTick.Store(now.UnixNano())
+	h.mu.Lock()
+	due := make([]stream, 0, len(h.streams))
+	for _, s := range h.streams {
+		if s.key.alarm != "" {
+			continue
+		}
+		if s.key.target != 0 && now.UnixNano() >= s.key.target {
+			continue
+		}
+		if s.key.onChange != 0 {
+			if s.loc != nil && s.key.onChange.changed(s.loc, now) {
+				due = append(due, *s)
+			}
+			continue
+		}
+		if s.key.interval <= h.resolution || now.UnixNano()%int64(s.key.interval) == 0 {
+			due = append(due, *s)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, s := range due {
+		msg, err := h.render(s.key, now)
+		if err != nil {
+			continue
+		}
+		broadcast(s.members, msg)
+	}
+}
+
+// broadcast sends msg to members, serializing it once per codec in use.
+func broadcast(members []member, msg models.WebSocketMessage) {
+	payloads := make([][]byte, len(codecs))
+	for _, m := range members {
+		cd := m.client.codec
+		if payloads[cd.index] == nil {
+			payload, err := cd.marshal(msg)
+			if err != nil {
+				continue
+			}
+			payloads[cd.index] = payload
+		}
+		m.client.Enqueue(cd.withID(payloads[cd.index], m.encodedID))
+	}
+}
+
+// Snapshot sends the current value of key to one subscription right away, so
+// on-change subscribers need not wait for the next boundary.
+func (h *Hub) Snapshot(c *Client, id string, key streamKey) {
+	msg, err := h.render(key, time.Now())
+	if err != nil {
+		return
+	}
+	msg.ID = id
+	c.EnqueueMessage(msg)
+}
+
+// Interval rounds d to a multiple of the resolution and reports whether it
+// lies within the hub's bounds.
+func (h *Hub) Interval(d time.Duration) (time.Duration, bool) {
+	if d < h.resolution || d > h.maxInterval {
+		return 0, false
+	}
+	return d.Round(h.resolution), true
+}
+
+// PublishAlarm sends ev to the subscribers of its alarm and of all alarms.
+func (h *Hub) PublishAlarm(ev models.AlarmEvent) {
+	msg := models.WebSocketMessage{
+		Type:      "alarm",
+		Data:      ev,
+		Timestamp: ev.FiredAt,
+	}
+	var members []member
+	h.mu.Lock()
+	for _, id := range []string{allAlarms, ev.AlarmID} {
+		if s, ok := h.streams[streamKey{alarm: id}]; ok {
+			members = append(members, s.members...)
+		}
+	}
+	h.mu.Unlock()
+	broadcast(members, msg)
+}
+
+// complete ends a countdown stream: its members are detached and sent a
+// single countdown_complete message stamped with the target instant.
+func (h *Hub) complete(key streamKey) {
+	h.mu.Lock()
+	s, ok := h.streams[key]
+	if ok {
+		delete(h.streams, key)
+		for _, m := range s.members {
+			delete(h.clients[m.client], m.id)
+		}
+	}
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	target := time.Unix(0, key.target)
+	resp, err := h.timeService.Countdown(target, target, key.timezone, key.opts)
+	if err != nil {
+		return
+	}
+	broadcast(s.members, models.WebSocketMessage{
+		Type:      "countdown_complete",
+		Data:      resp,
+		Timestamp: target.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// render builds the message key carries at now.
+func (h *Hub) render(key streamKey, now time.Time) (models.WebSocketMessage, error) {
+	if key.target != 0 {
+		resp, err := h.timeService.Countdown(time.Unix(0, key.target), now, key.timezone, key.opts)
+		if err != nil {
+			return models.WebSocketMessage{}, err
+		}
+		return models.WebSocketMessage{
+			Type:      "countdown",
+			Data:      resp,
+			Timestamp: now.UTC().Format(time.RFC3339Nano),
+		}, nil
+	}
+	resp, err := h.timeService.TimeAt(now, key.timezone, key.opts)
+	if err != nil {
+		return models.WebSocketMessage{}, err
+	}
+	return models.WebSocketMessage{
+		Type:      "time_update",
+		Data:      resp,
+		Timestamp: now.UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+// withID splices "id":<quotedID> into a JSON message right after its
+// leading "type" member. A nil quotedID returns the shared payload as is.
+func withID(payload, quotedID []byte) []byte {
+	if quotedID == nil {
+		return payload
+	}
+	i := bytes.IndexByte(payload, ',') + 1
+	out := make([]byte, 0, len(payload)+len(quotedID)+6)
+	out = append(out, payload[:i]...)
+	out = append(out, `"id":`...)
+	out = append(out, quotedID...)
+	out = append(out, ',')
+	return append(out, payload[i:]...)
+}
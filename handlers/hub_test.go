@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gotimedate/models"
+	"gotimedate/services"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testTick = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func testKey(tz string) streamKey {
+	return streamKey{
+		timezone: tz,
+		opts:     services.TimeOptions{Precision: services.PrecisionSecond},
+		interval: time.Second,
+	}
+}
+
+func readUpdate(t *testing.T, c *Client) models.WebSocketMessage {
+	t.Helper()
+	select {
+	case raw := <-c.Send():
+		var msg models.WebSocketMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			t.Fatalf("invalid payload %s: %v", raw, err)
+		}
+		return msg
+	default:
+		t.Fatal("expected a queued message")
+	}
+	return models.WebSocketMessage{}
+}
+
+func TestHubFanOut(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), time.Second, time.Minute, 4)
+	a, b := hub.Register(), hub.Register()
+	hub.Subscribe(a, "", testKey("UTC"))
+	hub.Subscribe(b, "tokyo", testKey("Asia/Tokyo"))
+	hub.Subscribe(b, "utc", testKey("UTC"))
+
+	if stats := hub.Stats(); stats.Streams != 2 || stats.Clients != 2 {
+		t.Errorf("expected 2 streams and 2 clients, got %+v", stats)
+	}
+
+	hub.Tick(testTick)
+
+	msg := readUpdate(t, a)
+	if msg.Type != "time_update" || msg.ID != "" {
+		t.Errorf("expected untagged time_update, got %+v", msg)
+	}
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		msg := readUpdate(t, b)
+		ids[msg.ID] = true
+		data := msg.Data.(map[string]interface{})
+		if data["unix"].(float64) != float64(testTick.Unix()) {
+			t.Errorf("expected unix %d, got %v", testTick.Unix(), data["unix"])
+		}
+	}
+	if !ids["tokyo"] || !ids["utc"] {
+		t.Errorf("expected tokyo and utc updates, got %v", ids)
+	}
+
+	hub.Unsubscribe(b, "tokyo")
+	if n := hub.SubscriptionCount(b); n != 1 {
+		t.Errorf("expected 1 subscription, got %d", n)
+	}
+	if stats := hub.Stats(); stats.Streams != 1 {
+		t.Errorf("expected 1 stream after unsubscribe, got %d", stats.Streams)
+	}
+}
+
+func TestHubInterval(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), time.Second, time.Minute, 8)
+	c := hub.Register()
+	key := testKey("UTC")
+	key.interval = 5 * time.Second
+	hub.Subscribe(c, "", key)
+
+	for i := 0; i < 10; i++ {
+		hub.Tick(testTick.Add(time.Duration(i) * time.Second))
+	}
+	if n := len(c.Send()); n != 2 {
+		t.Errorf("expected 2 updates in 10 ticks at 5s interval, got %d", n)
+	}
+}
+
+func TestHubEvictsSlowConsumer(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), time.Second, time.Minute, 2)
+	slow, fast := hub.Register(), hub.Register()
+	hub.Subscribe(slow, "", testKey("UTC"))
+	hub.Subscribe(fast, "", testKey("UTC"))
+
+	for i := 0; i < 3; i++ {
+		hub.Tick(testTick.Add(time.Duration(i) * time.Second))
+		<-fast.Send()
+	}
+
+	if !slow.Evicted() {
+		t.Error("expected slow client to be evicted")
+	}
+	select {
+	case <-slow.Done():
+	default:
+		t.Error("expected evicted client to be done")
+	}
+	if fast.Evicted() {
+		t.Error("expected fast client to stay connected")
+	}
+	if stats := hub.Stats(); stats.Clients != 1 || stats.Evicted != 1 {
+		t.Errorf("expected 1 client and 1 eviction, got %+v", stats)
+	}
+}
+
+func TestHubShutdown(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), time.Second, time.Minute, 2)
+	before := hub.Register()
+	hub.Subscribe(before, "", testKey("UTC"))
+
+	notice := models.GoingAway{Reason: "server shutting down", ReconnectAfterMs: 5000}
+	hub.Shutdown(notice)
+	after := hub.Register()
+
+	for name, c := range map[string]*Client{"registered": before, "late": after} {
+		select {
+		case <-c.Done():
+		default:
+			t.Errorf("%s: expected client to be done", name)
+		}
+		if got := c.GoingAway(); got == nil || *got != notice {
+			t.Errorf("%s: expected notice %+v, got %v", name, notice, got)
+		}
+		if c.Evicted() {
+			t.Errorf("%s: expected no eviction", name)
+		}
+	}
+	if stats := hub.Stats(); stats.Clients != 0 || stats.Streams != 0 {
+		t.Errorf("expected no clients or streams, got %+v", stats)
+	}
+}
+
+func TestHubRunAligned(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), 100*time.Millisecond, time.Minute, 4)
+	c := hub.Register()
+	key := testKey("UTC")
+	key.interval = 100 * time.Millisecond
+	hub.Subscribe(c, "", key)
+	go hub.Run()
+	defer hub.Stop()
+
+	select {
+	case <-c.Send():
+	case <-time.After(time.Second):
+		t.Fatal("expected a tick within 1s")
+	}
+	last, err := time.Parse(time.RFC3339Nano, hub.Stats().LastTick)
+	if err != nil {
+		t.Fatalf("invalid last tick: %v", err)
+	}
+	if last.UnixNano()%int64(100*time.Millisecond) != 0 {
+		t.Errorf("expected tick aligned to 100ms, got %s", last.Format(time.RFC3339Nano))
+	}
+}
+
+func TestHubCountdown(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), time.Second, time.Minute, 8)
+	c := hub.Register()
+	target := time.Now().Add(50 * time.Millisecond)
+	key := testKey("UTC")
+	key.target = target.UnixNano()
+	hub.Subscribe(c, "launch", key)
+
+	hub.Tick(target.Add(-time.Second))
+	if msg := readUpdate(t, c); msg.Type != "countdown" || msg.ID != "launch" {
+		t.Errorf("expected countdown update, got %+v", msg)
+	}
+	hub.Tick(target)
+	if n := len(c.Send()); n != 0 {
+		t.Errorf("expected no update at the target tick, got %d", n)
+	}
+
+	select {
+	case raw := <-c.Send():
+		var msg models.WebSocketMessage
+		json.Unmarshal(raw, &msg)
+		if msg.Type != "countdown_complete" || msg.ID != "launch" {
+			t.Errorf("expected countdown_complete, got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected countdown_complete")
+	}
+	if hub.Subscribed(c, "launch") {
+		t.Error("expected completed countdown to be unsubscribed")
+	}
+	if n := hub.Stats().Streams; n != 0 {
+		t.Errorf("expected no streams, got %d", n)
+	}
+}
+
+func TestWithID(t *testing.T) {
+	payload := []byte(`{"type":"time_update","data":{}}`)
+	if got := string(withID(payload, nil)); got != string(payload) {
+		t.Errorf("expected payload unchanged, got %s", got)
+	}
+	want := `{"type":"time_update","id":"a\"b","data":{}}`
+	if got := string(withID(payload, []byte(strconv.Quote(`a"b`)))); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+const benchClients = 10000
+
+// benchZones spreads the benchmark clients over a handful of streams, as a
+// real deployment would.
+var benchZones = []string{"UTC", "America/New_York", "Europe/London", "Asia/Tokyo", "Asia/Kolkata"}
+
+// BenchmarkHubTick10k measures one shared-hub tick delivering to 10k clients.
+func BenchmarkHubTick10k(b *testing.B) {
+	hub := NewHub(services.NewTimeService(), time.Second, time.Minute, 1)
+	clients := make([]*Client, benchClients)
+	for i := range clients {
+		clients[i] = hub.Register()
+		hub.Subscribe(clients[i], "", testKey(benchZones[i%len(benchZones)]))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hub.Tick(testTick.Add(time.Duration(i) * time.Second))
+		for _, c := range clients {
+			<-c.send
+		}
+	}
+}
+
+// BenchmarkPerConnectionTicker10k measures the previous design, where every
+// connection rendered and serialized its own update each second.
+func BenchmarkPerConnectionTicker10k(b *testing.B) {
+	timeService := services.NewTimeService()
+	opts := services.TimeOptions{Precision: services.PrecisionSecond}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for c := 0; c < benchClients; c++ {
+			resp, err := timeService.GetCurrentTime(benchZones[c%len(benchZones)], opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := json.Marshal(models.WebSocketMessage{
+				Type:      "time_update",
+				Data:      resp,
+				Timestamp: time.Now().Format(time.RFC3339),
+			}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestChangeModeChanged(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	ny, _ := time.LoadLocation("America/New_York")
+	utc := func(s string) time.Time {
+		v, _ := time.Parse(time.RFC3339Nano, s)
+		return v
+	}
+
+	tests := []struct {
+		name string
+		mode changeMode
+		loc  *time.Location
+		now  time.Time
+		want bool
+	}{
+		{"minute boundary", changeMinute, time.UTC, utc("2024-01-05T10:15:00Z"), true},
+		{"mid-minute", changeMinute, time.UTC, utc("2024-01-05T10:15:01Z"), false},
+		{"sub-second tick", changeMinute, time.UTC, utc("2024-01-05T10:15:00.5Z"), false},
+		{"hour in +05:30 zone", changeHour, kolkata, utc("2024-01-05T10:30:00Z"), true},
+		{"UTC hour in +05:30 zone", changeHour, kolkata, utc("2024-01-05T10:00:00Z"), false},
+		{"local midnight", changeDay, kolkata, utc("2024-01-05T18:30:00Z"), true},
+		{"UTC midnight", changeDay, kolkata, utc("2024-01-06T00:00:00Z"), false},
+		{"spring forward", changeDST, ny, utc("2024-03-10T07:00:00Z"), true},
+		{"fall back", changeDST, ny, utc("2024-11-03T06:00:00Z"), true},
+		{"no transition", changeDST, ny, utc("2024-03-10T08:00:00Z"), false},
+		{"fall back repeats hour", changeHour, ny, utc("2024-11-03T06:00:00Z"), true},
+		{"combined modes", changeDay | changeDST, ny, utc("2024-01-05T05:00:00Z"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.mode.changed(tt.loc, tt.now); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHubOnChange(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), 100*time.Millisecond, time.Minute, 64)
+	c := hub.Register()
+	hub.Subscribe(c, "", streamKey{timezone: "UTC", onChange: changeMinute})
+
+	start := time.Date(2024, 1, 5, 10, 14, 58, 0, time.UTC)
+	for tick := start; tick.Before(start.Add(3 * time.Second)); tick = tick.Add(100 * time.Millisecond) {
+		hub.Tick(tick)
+	}
+	if n := len(c.Send()); n != 1 {
+		t.Fatalf("expected 1 update at the minute boundary, got %d", n)
+	}
+	if msg := readUpdate(t, c); msg.Timestamp != "2024-01-05T10:15:00Z" {
+		t.Errorf("expected update at 10:15:00, got %s", msg.Timestamp)
+	}
+}
+
+func TestHubSubSecondInterval(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), 100*time.Millisecond, time.Minute, 64)
+	c := hub.Register()
+	key := testKey("UTC")
+	key.interval = 250 * time.Millisecond
+	if d, ok := hub.Interval(250 * time.Millisecond); !ok || d != 300*time.Millisecond {
+		t.Errorf("expected 250ms to round to 300ms, got %s (%v)", d, ok)
+	}
+	key.interval = 200 * time.Millisecond
+	hub.Subscribe(c, "", key)
+
+	for i := 0; i < 10; i++ {
+		hub.Tick(testTick.Add(time.Duration(i) * 100 * time.Millisecond))
+	}
+	if n := len(c.Send()); n != 5 {
+		t.Errorf("expected 5 updates in 1s at 200ms, got %d", n)
+	}
+	readUpdate(t, c)
+	if msg := readUpdate(t, c); msg.Timestamp != "2024-03-10T12:00:00.2Z" {
+		t.Errorf("expected sub-second timestamp, got %s", msg.Timestamp)
+	}
+}
+
+func TestClientEnqueueReply(t *testing.T) {
+	hub := NewHub(services.NewTimeService(), time.Second, time.Minute, 2)
+	c := hub.Register()
+	rendered := false
+	if !c.EnqueueReply(func() interface{} { rendered = true; return nil }) {
+		t.Fatal("expected the reply to be queued")
+	}
+	if rendered {
+		t.Error("expected the reply to be rendered only when written")
+	}
+	for i := 0; i < replyQueue; i++ {
+		c.EnqueueReply(func() interface{} { return nil })
+	}
+	if !c.Evicted() {
+		t.Error("expected a full reply queue to evict the client")
+	}
+}
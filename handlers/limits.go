@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConnLimiter caps concurrent WebSocket connections globally and per client
+// IP. A zero cap means unlimited.
+type ConnLimiter struct {
+	maxTotal int
+	maxPerIP int
+
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+
+	rejectedTotal atomic.Uint64
+	rejectedPerIP atomic.Uint64
+	rateLimited   atomic.Uint64
+	idleClosed    atomic.Uint64
+}
+
+// ConnStats is a point-in-time snapshot of connection limiting.
+type ConnStats struct {
+	Active        int    `json:"active"`
+	IPs           int    `json:"ips"`
+	MaxTotal      int    `json:"max_connections,omitempty"`
+	MaxPerIP      int    `json:"max_connections_per_ip,omitempty"`
+	RejectedTotal uint64 `json:"rejected_global"`
+	RejectedPerIP uint64 `json:"rejected_per_ip"`
+	RateLimited   uint64 `json:"closed_rate_limited"`
+	IdleClosed    uint64 `json:"closed_idle"`
+}
+
+func NewConnLimiter(maxTotal, maxPerIP int) *ConnLimiter {
+	return &ConnLimiter{maxTotal: maxTotal, maxPerIP: maxPerIP, perIP: make(map[string]int)}
+}
+
+// SetLimits changes the caps. Connections already over a lowered cap stay
+// open; new ones are refused until enough have closed.
+func (l *ConnLimiter) SetLimits(maxTotal, maxPerIP int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxTotal = maxTotal
+	l.maxPerIP = maxPerIP
+}
+
+// Acquire reserves a connection slot for ip. It returns 0 on success,
+// 503 when the server is full or 429 when ip holds too many connections.
+func (l *ConnLimiter) Acquire(ip string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxTotal > 0 && l.total >= l.maxTotal {
+		l.rejectedTotal.Add(1)
+		return fiber.StatusServiceUnavailable
+	}
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		l.rejectedPerIP.Add(1)
+		return fiber.StatusTooManyRequests
+	}
+	l.total++
+	l.perIP[ip]++
+	return 0
+}
+
+// Release frees a slot taken by Acquire.
+func (l *ConnLimiter) Release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perIP[ip] == 0 {
+		return
+	}
+	l.total--
+	if l.perIP[ip]--; l.perIP[ip] == 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// Stats returns a snapshot of the limiter counters.
+func (l *ConnLimiter) Stats() ConnStats {
+	l.mu.Lock()
+	stats := ConnStats{Active: l.total, IPs: len(l.perIP), MaxTotal: l.maxTotal, MaxPerIP: l.maxPerIP}
+	l.mu.Unlock()
+	stats.RejectedTotal = l.rejectedTotal.Load()
+	stats.RejectedPerIP = l.rejectedPerIP.Load()
+	stats.RateLimited = l.rateLimited.Load()
+	stats.IdleClosed = l.idleClosed.Load()
+	return stats
+}
+
+// tokenBucket allows rate events per second on average with bursts of up to
+// burst events. It is not safe for concurrent use.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// allow takes a token at now and reports whether one was available.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestConnLimiter(t *testing.T) {
+	l := NewConnLimiter(3, 2)
+	for i := 0; i < 2; i++ {
+		if status := l.Acquire("10.0.0.1"); status != 0 {
+			t.Fatalf("expected slot %d to be granted, got %d", i, status)
+		}
+	}
+	if status := l.Acquire("10.0.0.1"); status != fiber.StatusTooManyRequests {
+		t.Errorf("expected 429 over the per-IP cap, got %d", status)
+	}
+	if status := l.Acquire("10.0.0.2"); status != 0 {
+		t.Errorf("expected another IP to be granted, got %d", status)
+	}
+	if status := l.Acquire("10.0.0.3"); status != fiber.StatusServiceUnavailable {
+		t.Errorf("expected 503 over the global cap, got %d", status)
+	}
+
+	l.Release("10.0.0.1")
+	l.Release("10.0.0.9") // never acquired
+	if status := l.Acquire("10.0.0.3"); status != 0 {
+		t.Errorf("expected a released slot to be reusable, got %d", status)
+	}
+	stats := l.Stats()
+	if stats.Active != 3 || stats.IPs != 3 || stats.RejectedPerIP != 1 || stats.RejectedTotal != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestConnLimiterUnlimited(t *testing.T) {
+	l := NewConnLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if status := l.Acquire("10.0.0.1"); status != 0 {
+			t.Fatalf("expected no cap, got %d", status)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(2, 3, now)
+	for i := 0; i < 3; i++ {
+		if !b.allow(now) {
+			t.Fatalf("expected burst token %d", i)
+		}
+	}
+	if b.allow(now) {
+		t.Error("expected the bucket to be empty")
+	}
+	if !b.allow(now.Add(500 * time.Millisecond)) {
+		t.Error("expected a token after refilling for 500ms at 2/s")
+	}
+	if b.allow(now.Add(500 * time.Millisecond)) {
+		t.Error("expected only one refilled token")
+	}
+	if !b.allow(now.Add(time.Hour)) || !b.allow(now.Add(time.Hour)) || !b.allow(now.Add(time.Hour)) || b.allow(now.Add(time.Hour)) {
+		t.Error("expected refill to cap at the burst size")
+	}
+}
+
+func TestConnLimiterSetLimits(t *testing.T) {
+	l := NewConnLimiter(0, 2)
+	l.Acquire("10.0.0.1")
+	l.Acquire("10.0.0.1")
+	l.SetLimits(0, 1)
+	if status := l.Acquire("10.0.0.1"); status != fiber.StatusTooManyRequests {
+		t.Errorf("expected 429 under the lowered cap, got %d", status)
+	}
+	if stats := l.Stats(); stats.Active != 2 || stats.MaxPerIP != 1 {
+		t.Errorf("expected open connections to be kept under the new cap, got %+v", stats)
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"time"
+
+	"gotimedate/config"
+	"gotimedate/locale"
+	"gotimedate/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxSSEReplay bounds how many missed ticks a resuming client is sent.
+const maxSSEReplay = 100
+
+// sseRetry is the reconnect delay suggested to EventSource clients.
+const sseRetry = 3 * time.Second
+
+// SSEHandler streams time_update payloads from the WebSocket hub over
+// text/event-stream for clients that cannot upgrade.
+type SSEHandler struct {
+	hub       *Hub
+	defaultTZ tzVar
+	heartbeat time.Duration
+}
+
+func NewSSEHandler(cfg *config.Config, hub *Hub) *SSEHandler {
+	h := &SSEHandler{hub: hub, heartbeat: seconds(cfg.SSEHeartbeat, 15*time.Second)}
+	h.defaultTZ.Store(cfg.DefaultTimezone)
+	return h
+}
+
+// SetDefaultTimezone changes the timezone of streams that name none.
+func (h *SSEHandler) SetDefaultTimezone(tz string) {
+	h.defaultTZ.Store(tz)
+}
+
+// tickTime extracts the tick instant from a hub payload. The timestamp is the
+// envelope's last member, so it is found without decoding the message.
+func tickTime(payload []byte) (time.Time, bool) {
+	const marker = `"timestamp":"`
+	i := bytes.LastIndex(payload, []byte(marker))
+	if i < 0 {
+		return time.Time{}, false
+	}
+	rest := payload[i+len(marker):]
+	end := bytes.IndexByte(rest, '"')
+	if end < 0 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(rest[:end]))
+	return t, err == nil
+}
+
+// writeEvent writes payload as an SSE event whose ID is its tick timestamp.
+func writeEvent(w *bufio.Writer, tick time.Time, payload []byte) error {
+	fmt.Fprintf(w, "id: %s\ndata: ", tick.UTC().Format(time.RFC3339Nano))
+	w.Write(payload)
+	w.WriteString("\n\n")
+	return w.Flush()
+}
+
+// writeGoingAway tells the client the server is shutting down, first setting
+// the EventSource reconnect delay to the notice's hint if it has one.
+func writeGoingAway(w *bufio.Writer, notice *models.GoingAway) error {
+	payload, err := jsonCodec.marshal(models.WebSocketMessage{Type: "going_away", Data: notice})
+	if err != nil {
+		return err
+	}
+	if notice.ReconnectAfterMs > 0 {
+		fmt.Fprintf(w, "retry: %d\n", notice.ReconnectAfterMs)
+	}
+	w.WriteString("data: ")
+	w.Write(payload)
+	w.WriteString("\n\n")
+	return w.Flush()
+}
+
+// @Summary Stream time over Server-Sent Events
+// @Description Streams the same time_update messages as /ws/time. Event IDs are tick timestamps; reconnecting with Last-Event-ID replays missed ticks (up to 100).
+// @Tags Time
+// @Produce text/event-stream
+// @Param timezone query string false "Timezone (default server default)"
+// @Param format query string false "ISO8601, 12hour or 24hour"
+// @Param interval query string false "Go duration between updates, e.g. 100ms or 5s (default 1s)"
+// @Param on_change query string false "Push only at minute, hour, day (midnight) or dst changes instead of an interval; comma-separated"
+// @Param precision query string false "Sub-second precision: s, ms, us or ns (default s)"
+// @Param locale query string false "Locale such as en, ms, ar, de, fr, ja, zh or hi-u-nu-deva (default Accept-Language)"
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Success 200 {object} models.WebSocketMessage
+// @Failure 400 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sse/time [get]
+func (h *SSEHandler) Stream(c *fiber.Ctx) error {
+	sub := defaultSubscription(h.hub, h.defaultTZ.Load(), locale.Negotiate(c.Get(fiber.HeaderAcceptLanguage)))
+	if e := sub.apply(h.hub, &models.WebSocketMessage{
+		Timezone:  c.Query("timezone"),
+		Format:    c.Query("format"),
+		Interval:  c.Query("interval"),
+		OnChange:  c.Query("on_change"),
+		Precision: c.Query("precision"),
+		Locale:    c.Query("locale"),
+	}); e != nil {
+		return fiber.NewError(fiber.StatusBadRequest, e.Message)
+	}
+	key := sub.key()
+	c.Locals("timezone", sub.timezone)
+
+	var last time.Time
+	if id := c.Get("Last-Event-ID"); id != "" {
+		t, err := time.Parse(time.RFC3339Nano, id)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid Last-Event-ID: "+id)
+		}
+		if t.Before(time.Now()) {
+			last = t
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// Register before replaying so no tick falls between the two; ticks
+	// already covered by the replay are skipped below.
+	client := h.hub.Register()
+	h.hub.Subscribe(client, "", key)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.hub.Unregister(client)
+
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+		if err := w.Flush(); err != nil {
+			return
+		}
+		if sub.onChange != 0 {
+			// Boundaries are sparse, so start with the current value
+			// instead of replaying.
+			h.hub.Snapshot(client, "", key)
+		} else if !last.IsZero() {
+			var err error
+			if last, err = h.replay(w, key, last, time.Now()); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(h.heartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case msg := <-client.Send():
+				tick, ok := tickTime(msg)
+				if !ok || !tick.After(last) {
+					continue
+				}
+				if err := writeEvent(w, tick, msg); err != nil {
+					return
+				}
+				last = tick
+			case <-heartbeat.C:
+				w.WriteString(": heartbeat\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			case <-client.Done():
+				if notice := client.GoingAway(); notice != nil {
+					writeGoingAway(w, notice)
+				}
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// replay re-renders the ticks of key after last and up to now, which are
+// deterministic, and returns the last one written.
+func (h *SSEHandler) replay(w *bufio.Writer, key streamKey, last, now time.Time) (time.Time, error) {
+	step := key.interval
+	if step < h.hub.resolution {
+		step = h.hub.resolution
+	}
+	first := time.Unix(0, (last.UnixNano()/int64(step)+1)*int64(step))
+	if missed := int(now.Sub(first)/step) + 1; missed > maxSSEReplay {
+		first = first.Add(time.Duration(missed-maxSSEReplay) * step)
+	}
+	for tick := first; !tick.After(now); tick = tick.Add(step) {
+		msg, err := h.hub.render(key, tick)
+		if err != nil {
+			return last, err
+		}
+		payload, err := jsonCodec.marshal(msg)
+		if err != nil {
+			return last, err
+		}
+		if err := writeEvent(w, tick, payload); err != nil {
+			return last, err
+		}
+		last = tick
+	}
+	return last, nil
+}
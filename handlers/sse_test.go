@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"gotimedate/config"
+	"gotimedate/models"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type sseEvent struct {
+	id      string
+	data    string
+	comment string
+	retry   string
+}
+
+// startSSE serves h on a random local port and opens /sse/time with query.
+func startSSE(t *testing.T, h *SSEHandler, query string, header http.Header) (*http.Response, <-chan sseEvent) {
+	t.Helper()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/sse/time", h.Stream)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	req, _ := http.NewRequest("GET", "http://"+ln.Addr().String()+"/sse/time?"+query, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan sseEvent, 256)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev != (sseEvent{}) {
+					events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				ev.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				ev.id = line[4:]
+			case strings.HasPrefix(line, "data: "):
+				ev.data = line[6:]
+			case strings.HasPrefix(line, "retry: "):
+				ev.retry = line[7:]
+			}
+		}
+	}()
+	return resp, events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			if ev.id != "" || ev.comment != "" {
+				return ev
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("expected an event")
+		}
+	}
+}
+
+func TestSSEStream(t *testing.T) {
+	ws := newWSHandler(t, &config.Config{})
+	h := NewSSEHandler(&config.Config{}, ws.Hub())
+	resp, events := startSSE(t, h, "timezone=Asia/Tokyo&format=24hour", nil)
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %s", ct)
+	}
+	ev := nextEvent(t, events)
+	var msg struct {
+		Type      string              `json:"type"`
+		Data      models.TimeResponse `json:"data"`
+		Timestamp string              `json:"timestamp"`
+	}
+	if err := json.Unmarshal([]byte(ev.data), &msg); err != nil {
+		t.Fatalf("invalid data %q: %v", ev.data, err)
+	}
+	if msg.Type != "time_update" || msg.Data.Timezone != "Asia/Tokyo" {
+		t.Errorf("expected Asia/Tokyo time_update, got %+v", msg)
+	}
+	if id, _ := time.Parse(time.RFC3339Nano, ev.id); !id.Equal(mustParse(t, msg.Timestamp)) {
+		t.Errorf("expected event ID %s to match timestamp %s", ev.id, msg.Timestamp)
+	}
+}
+
+func mustParse(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatalf("invalid timestamp %q: %v", s, err)
+	}
+	return v
+}
+
+func TestSSEResume(t *testing.T) {
+	ws := newWSHandler(t, &config.Config{})
+	h := NewSSEHandler(&config.Config{}, ws.Hub())
+	last := time.Now().Add(-5 * time.Second).Truncate(time.Second)
+	_, events := startSSE(t, h, "", http.Header{"Last-Event-Id": {last.UTC().Format(time.RFC3339Nano)}})
+
+	// Missed ticks arrive first and in order, then live ticks continue the
+	// sequence without gaps or duplicates.
+	want := last
+	for i := 0; i < 7; i++ {
+		want = want.Add(time.Second)
+		ev := nextEvent(t, events)
+		if got := mustParse(t, ev.id); !got.Equal(want) {
+			t.Fatalf("event %d: expected ID %s, got %s", i, want.UTC().Format(time.RFC3339), ev.id)
+		}
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	ws := newWSHandler(t, &config.Config{})
+	h := NewSSEHandler(&config.Config{SSEHeartbeat: 1}, ws.Hub())
+	_, events := startSSE(t, h, "interval=1m", nil)
+
+	if ev := nextEvent(t, events); ev.comment != "heartbeat" {
+		t.Errorf("expected heartbeat comment, got %+v", ev)
+	}
+}
+
+func TestSSEValidation(t *testing.T) {
+	ws := newWSHandler(t, &config.Config{})
+	h := NewSSEHandler(&config.Config{}, ws.Hub())
+	app := fiber.New()
+	app.Get("/sse/time", h.Stream)
+
+	for _, q := range []string{"timezone=Mars/Olympus", "format=sundial", "interval=soon"} {
+		req, _ := http.NewRequest("GET", "/sse/time?"+q, nil)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestSSEShutdown(t *testing.T) {
+	ws := newWSHandler(t, &config.Config{})
+	h := NewSSEHandler(&config.Config{}, ws.Hub())
+	_, events := startSSE(t, h, "", nil)
+	nextEvent(t, events)
+
+	ws.Hub().Shutdown(models.GoingAway{Reason: "server shutting down", ReconnectAfterMs: 5000})
+
+	var last sseEvent
+	timeout := time.After(3 * time.Second)
+	for open := true; open; {
+		select {
+		case ev, ok := <-events:
+			if ok {
+				last = ev
+			}
+			open = ok
+		case <-timeout:
+			t.Fatal("expected the stream to end")
+		}
+	}
+	var msg struct {
+		Type string           `json:"type"`
+		Data models.GoingAway `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(last.data), &msg); err != nil {
+		t.Fatalf("invalid data %q: %v", last.data, err)
+	}
+	if msg.Type != "going_away" || msg.Data.ReconnectAfterMs != 5000 || last.retry != "5000" {
+		t.Errorf("expected going_away with a 5000ms retry as the last event, got %+v", last)
+	}
+}
@@ -1,32 +1,112 @@
 package handlers
 
 import (
+	"errors"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"gotimedate/locale"
 	"gotimedate/models"
 	"gotimedate/services"
+	"gotimedate/tracing"
 
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// traceService starts a child span of the request span for a TimeService
+// call. The returned function ends it, recording err if there was one.
+func traceService(c *fiber.Ctx, method string, attrs ...attribute.KeyValue) func(error) {
+	_, span := tracing.Tracer("gotimedate/handlers").Start(c.UserContext(), "TimeService."+method, trace.WithAttributes(attrs...))
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// tzVar is a default timezone that can be replaced while requests read it.
+type tzVar struct {
+	name atomic.Pointer[string]
+}
+
+func (v *tzVar) Load() string {
+	if p := v.name.Load(); p != nil {
+		return *p
+	}
+	return ""
+}
+
+func (v *tzVar) Store(name string) {
+	v.name.Store(&name)
+}
+
 type TimeHandler struct {
 	timeService *services.TimeService
-	defaultTZ   string
+	defaultTZ   tzVar
 }
 
 func NewTimeHandler(defaultTZ string) *TimeHandler {
-	return &TimeHandler{timeService: services.NewTimeService(), defaultTZ: defaultTZ}
+	h := &TimeHandler{timeService: services.NewTimeService()}
+	h.defaultTZ.Store(defaultTZ)
+	return h
+}
+
+// SetDefaultTimezone changes the timezone used when a request names none.
+func (h *TimeHandler) SetDefaultTimezone(tz string) {
+	h.defaultTZ.Store(tz)
+}
+
+func (h *TimeHandler) timeOptions(c *fiber.Ctx) (services.TimeOptions, error) {
+	precision, err := services.ParsePrecision(c.Query("precision"))
+	if err != nil {
+		return services.TimeOptions{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	tag, err := requestLocale(c)
+	if err != nil {
+		return services.TimeOptions{}, err
+	}
+	return services.TimeOptions{Precision: precision, Locale: tag}, nil
+}
+
+// requestLocale returns the explicit locale query parameter, or else the best
+// supported match from Accept-Language. An empty result means no localization.
+func requestLocale(c *fiber.Ctx) (string, error) {
+	if tag := c.Query("locale"); tag != "" {
+		if _, ok := locale.Get(tag); !ok {
+			return "", fiber.NewError(fiber.StatusBadRequest, "unsupported locale: "+tag)
+		}
+		return tag, nil
+	}
+	c.Vary(fiber.HeaderAcceptLanguage)
+	return locale.Negotiate(c.Get(fiber.HeaderAcceptLanguage)), nil
 }
 
 // @Summary Get current time
 // @Tags Time
 // @Param timezone query string false "Timezone (default UTC)"
+// @Param precision query string false "Sub-second precision: s, ms, us or ns (default s)"
+// @Param locale query string false "Locale such as en, ms, ar, de, fr, ja, zh or hi-u-nu-deva (default Accept-Language)"
 // @Success 200 {object} models.TimeResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /time [get]
 func (h *TimeHandler) GetCurrentTime(c *fiber.Ctx) error {
-	tz := c.Query("timezone", h.defaultTZ)
-	resp, err := h.timeService.GetCurrentTime(tz)
+	tz := c.Query("timezone", h.defaultTZ.Load())
+	c.Locals("timezone", tz)
+	opts, err := h.timeOptions(c)
+	if err != nil {
+		return err
+	}
+	end := traceService(c, "GetCurrentTime", attribute.String("timezone", tz))
+	resp, err := h.timeService.GetCurrentTime(tz, opts)
+	end(err)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
@@ -36,7 +116,11 @@ func (h *TimeHandler) GetCurrentTime(c *fiber.Ctx) error {
 // @Summary Get time by timezone
 // @Tags Time
 // @Param timezone path string true "Timezone"
+// @Param precision query string false "Sub-second precision: s, ms, us or ns (default s)"
+// @Param locale query string false "Locale such as en, ms, ar, de, fr, ja, zh or hi-u-nu-deva (default Accept-Language)"
 // @Success 200 {object} models.TimeResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /time/{timezone} [get]
 func (h *TimeHandler) GetTimeByTimezone(c *fiber.Ctx) error {
 	tz := c.Params("*")
@@ -44,7 +128,108 @@ func (h *TimeHandler) GetTimeByTimezone(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "timezone is required")
 	}
 	tz = strings.TrimPrefix(tz, "/")
-	resp, err := h.timeService.GetCurrentTime(tz)
+	c.Locals("timezone", tz)
+	opts, err := h.timeOptions(c)
+	if err != nil {
+		return err
+	}
+	end := traceService(c, "GetCurrentTime", attribute.String("timezone", tz))
+	resp, err := h.timeService.GetCurrentTime(tz, opts)
+	end(err)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.JSON(resp)
+}
+
+// @Summary Clock synchronization
+// @Description Returns high-resolution receive/transmit timestamps for NTP-style offset estimation
+// @Tags Time
+// @Param t0 query number false "Client transmit time in Unix milliseconds"
+// @Success 200 {object} models.ClockSyncResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /time/sync [get]
+func (h *TimeHandler) ClockSync(c *fiber.Ctx) error {
+	received := time.Now()
+	var originate float64
+	if raw := c.Query("t0"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid t0: "+raw)
+		}
+		originate = v
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	end := traceService(c, "ClockSync")
+	resp := h.timeService.ClockSync(originate, received)
+	end(nil)
+	return c.JSON(resp)
+}
+
+// @Summary Relative time
+// @Description Describes a timestamp relative to a reference instant, e.g. "in 3 hours" or "yesterday at 5 PM"
+// @Tags Time
+// @Param timestamp query string true "RFC 3339 timestamp to describe"
+// @Param reference query string false "RFC 3339 reference instant (default now)"
+// @Param timezone query string false "Timezone used for calendar phrases (default UTC)"
+// @Param locale query string false "Locale such as en, ms, ar, de, fr, ja, zh or hi (default Accept-Language, then en)"
+// @Param granularity query string false "Smallest unit: second, minute, hour, day, week, month or year (default second)"
+// @Param rounding query string false "round, floor or ceil (default round)"
+// @Param calendar query bool false "Use yesterday/tomorrow phrases (default true)"
+// @Success 200 {object} models.RelativeTimeResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /time/relative [get]
+func (h *TimeHandler) GetRelativeTime(c *fiber.Ctx) error {
+	timestamp := c.Query("timestamp")
+	if timestamp == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "timestamp is required")
+	}
+	opts := services.RelativeOptions{
+		Granularity: c.Query("granularity"),
+		Rounding:    c.Query("rounding"),
+		Calendar:    c.QueryBool("calendar", true),
+	}
+	tag, err := requestLocale(c)
+	if err != nil {
+		return err
+	}
+	tz := c.Query("timezone", h.defaultTZ.Load())
+	c.Locals("timezone", tz)
+	end := traceService(c, "GetRelativeTime", attribute.String("locale", tag))
+	resp, err := h.timeService.GetRelativeTime(timestamp, c.Query("reference"), tz, tag, opts)
+	end(err)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.JSON(resp)
+}
+
+// @Summary Parse natural-language time
+// @Description Resolves phrases like "next Friday 3pm" or "in 2 weeks" relative to a reference instant
+// @Tags Time
+// @Param request body models.ParseRequest true "Parse request"
+// @Success 200 {object} models.ParseResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /time/parse [post]
+func (h *TimeHandler) ParseTime(c *fiber.Ctx) error {
+	var req models.ParseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid body")
+	}
+	if req.Timezone == "" {
+		req.Timezone = h.defaultTZ.Load()
+	}
+	c.Locals("timezone", req.Timezone)
+	end := traceService(c, "ParseNatural", attribute.String("timezone", req.Timezone))
+	resp, err := h.timeService.ParseNatural(&req)
+	end(err)
+	if errors.Is(err, services.ErrNoDateFound) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
@@ -54,33 +239,42 @@ func (h *TimeHandler) GetTimeByTimezone(c *fiber.Ctx) error {
 // @Summary Get available timezones
 // @Tags Time
 // @Success 200 {array} models.TimezoneInfo
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /timezones [get]
 func (h *TimeHandler) GetAvailableTimezones(c *fiber.Ctx) error {
+	end := traceService(c, "GetAvailableTimezones")
 	timezones := h.timeService.GetAvailableTimezones()
+	end(nil)
 	return c.JSON(timezones)
 }
 
 // @Summary Convert time
 // @Tags Time
 // @Param request body models.TimeConvertRequest true "Conversion request"
+// @Param precision query string false "Sub-second precision: s, ms, us or ns (default s)"
+// @Param locale query string false "Locale such as en, ms, ar, de, fr, ja, zh or hi-u-nu-deva (default Accept-Language)"
 // @Success 200 {object} models.TimeConvertResponse
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /time/convert [post]
 func (h *TimeHandler) ConvertTime(c *fiber.Ctx) error {
 	var req models.TimeConvertRequest
 	if err := c.BodyParser(&req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid body")
 	}
-	resp, err := h.timeService.ConvertTime(&req)
+	c.Locals("timezone", req.ToTimezone)
+	opts, err := h.timeOptions(c)
+	if err != nil {
+		return err
+	}
+	end := traceService(c, "ConvertTime",
+		attribute.String("from_timezone", req.FromTimezone),
+		attribute.String("to_timezone", req.ToTimezone))
+	resp, err := h.timeService.ConvertTime(&req, opts)
+	end(err)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 	return c.JSON(resp)
 }
-
-// @Summary Health check
-// @Tags Health
-// @Success 200 {object} models.HealthResponse
-// @Router /health [get]
-func (h *TimeHandler) HealthCheck(c *fiber.Ctx) error {
-	return c.JSON(models.HealthResponse{Status: "healthy", Timestamp: time.Now(), Version: "1.0.0"})
-}
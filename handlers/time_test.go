@@ -8,16 +8,20 @@ import (
 	"testing"
 
 	"gotimedate/models"
+	"gotimedate/tracing"
 
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
-func TestTimeHandler_HealthCheck(t *testing.T) {
+func TestTimeHandler_GetCurrentTime(t *testing.T) {
 	app := fiber.New()
 	h := NewTimeHandler("UTC")
-	app.Get("/health", h.HealthCheck)
+	app.Get("/api/v1/time", h.GetCurrentTime)
 
-	req, _ := http.NewRequest("GET", "/health", nil)
+	req, _ := http.NewRequest("GET", "/api/v1/time?timezone=UTC", nil)
 	resp, err := app.Test(req)
 	if err != nil {
 		t.Fatalf("failed to send request: %v", err)
@@ -27,20 +31,20 @@ func TestTimeHandler_HealthCheck(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
 	}
 
-	var healthResp models.HealthResponse
+	var timeResp models.TimeResponse
 	body, _ := io.ReadAll(resp.Body)
-	json.Unmarshal(body, &healthResp)
-	if healthResp.Status != "healthy" {
-		t.Errorf("handler returned unexpected body: got %v want %v", healthResp.Status, "healthy")
+	json.Unmarshal(body, &timeResp)
+	if timeResp.Timezone != "UTC" {
+		t.Errorf("expected timezone UTC, got %s", timeResp.Timezone)
 	}
 }
 
-func TestTimeHandler_GetCurrentTime(t *testing.T) {
+func TestTimeHandler_GetCurrentTimePrecision(t *testing.T) {
 	app := fiber.New()
 	h := NewTimeHandler("UTC")
 	app.Get("/api/v1/time", h.GetCurrentTime)
 
-	req, _ := http.NewRequest("GET", "/api/v1/time?timezone=UTC", nil)
+	req, _ := http.NewRequest("GET", "/api/v1/time?precision=ns", nil)
 	resp, err := app.Test(req)
 	if err != nil {
 		t.Fatalf("failed to send request: %v", err)
@@ -53,8 +57,40 @@ func TestTimeHandler_GetCurrentTime(t *testing.T) {
 	var timeResp models.TimeResponse
 	body, _ := io.ReadAll(resp.Body)
 	json.Unmarshal(body, &timeResp)
-	if timeResp.Timezone != "UTC" {
-		t.Errorf("expected timezone UTC, got %s", timeResp.Timezone)
+	if timeResp.UnixNs == 0 || timeResp.TimestampNano == "" {
+		t.Errorf("expected nanosecond fields, got %+v", timeResp)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/v1/time?precision=ps", nil)
+	resp, _ = app.Test(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %v for invalid precision, got %v", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestTimeHandler_AcceptLanguage(t *testing.T) {
+	app := fiber.New()
+	h := NewTimeHandler("UTC")
+	app.Get("/api/v1/time", h.GetCurrentTime)
+
+	req, _ := http.NewRequest("GET", "/api/v1/time", nil)
+	req.Header.Set("Accept-Language", "fr-CA,fr;q=0.9,en;q=0.5")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	var timeResp models.TimeResponse
+	body, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(body, &timeResp)
+	if timeResp.Locale != "fr" {
+		t.Errorf("expected locale fr, got %q", timeResp.Locale)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/v1/time?locale=xx", nil)
+	resp, _ = app.Test(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %v for unsupported locale, got %v", http.StatusBadRequest, resp.StatusCode)
 	}
 }
 
@@ -104,6 +140,67 @@ func TestTimeHandler_GetAvailableTimezones(t *testing.T) {
 	}
 }
 
+func TestTimeHandler_ClockSync(t *testing.T) {
+	app := fiber.New()
+	h := NewTimeHandler("UTC")
+	app.Get("/api/v1/time/sync", h.ClockSync)
+
+	req, _ := http.NewRequest("GET", "/api/v1/time/sync?t0=1704315045123.456", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	var syncResp models.ClockSyncResponse
+	body, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(body, &syncResp)
+	if syncResp.Originate != 1704315045123.456 {
+		t.Errorf("expected originate to be echoed, got %f", syncResp.Originate)
+	}
+	if syncResp.Transmit < syncResp.Receive {
+		t.Errorf("transmit %f precedes receive %f", syncResp.Transmit, syncResp.Receive)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/v1/time/sync?t0=abc", nil)
+	resp, _ = app.Test(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %v for invalid t0, got %v", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestTimeHandler_GetRelativeTime(t *testing.T) {
+	app := fiber.New()
+	h := NewTimeHandler("UTC")
+	app.Get("/api/v1/time/relative", h.GetRelativeTime)
+
+	req, _ := http.NewRequest("GET", "/api/v1/time/relative?timestamp=2026-01-03T17:00:00Z&reference=2026-01-04T12:00:00Z", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	var relResp models.RelativeTimeResponse
+	body, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(body, &relResp)
+	if relResp.Text != "yesterday at 5 PM" {
+		t.Errorf("expected %q, got %q", "yesterday at 5 PM", relResp.Text)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/v1/time/relative", nil)
+	resp, _ = app.Test(req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %v without timestamp, got %v", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
 func TestTimeHandler_ConvertTime(t *testing.T) {
 	app := fiber.New()
 	h := NewTimeHandler("UTC")
@@ -135,6 +232,43 @@ func TestTimeHandler_ConvertTime(t *testing.T) {
 	}
 }
 
+func TestTimeHandler_ParseTime(t *testing.T) {
+	app := fiber.New()
+	h := NewTimeHandler("UTC")
+	app.Post("/api/v1/time/parse", h.ParseTime)
+
+	parseReq := models.ParseRequest{
+		Text:      "tomorrow at noon in Tokyo",
+		Reference: "2026-01-07T10:00:00Z",
+	}
+	body, _ := json.Marshal(parseReq)
+	req, _ := http.NewRequest("POST", "/api/v1/time/parse", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	var parseResp models.ParseResponse
+	respBody, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(respBody, &parseResp)
+	if parseResp.Resolved.Timestamp != "2026-01-08T12:00:00+09:00" {
+		t.Errorf("expected 2026-01-08T12:00:00+09:00, got %s", parseResp.Resolved.Timestamp)
+	}
+
+	req, _ = http.NewRequest("POST", "/api/v1/time/parse", bytes.NewBufferString(`{"text":"no dates here"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %v for unparseable text, got %v", http.StatusUnprocessableEntity, resp.StatusCode)
+	}
+}
+
 func TestTimeHandler_InputValidation(t *testing.T) {
 	app := fiber.New()
 	h := NewTimeHandler("UTC")
@@ -224,3 +358,41 @@ func TestTimeHandler_InputValidation(t *testing.T) {
 		}
 	})
 }
+
+func TestTimeHandler_TimeServiceSpan(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(exp, 1, true)
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		ctx, span := tp.Tracer("test").Start(c.UserContext(), "request")
+		defer span.End()
+		c.SetUserContext(ctx)
+		return c.Next()
+	})
+	h := NewTimeHandler("UTC")
+	app.Get("/time", h.GetCurrentTime)
+
+	req, _ := http.NewRequest("GET", "/time?timezone=Mars/Olympus", nil)
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected request and service spans, got %d", len(spans))
+	}
+	child, parent := spans[0], spans[1]
+	if child.Name != "TimeService.GetCurrentTime" {
+		t.Errorf("expected TimeService.GetCurrentTime, got %s", child.Name)
+	}
+	if child.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Error("expected the service span to be a child of the request span")
+	}
+	if child.Status.Code != codes.Error {
+		t.Errorf("expected an error status for an invalid timezone, got %v", child.Status.Code)
+	}
+}
@@ -1,75 +1,602 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"gotimedate/auth"
 	"gotimedate/config"
+	"gotimedate/locale"
 	"gotimedate/models"
 	"gotimedate/services"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
+// maxSubscriptions bounds how many subscriptions a single connection may hold.
+const maxSubscriptions = 32
+
+// Inbound size limits. Messages over maxMessageSize get an error reply; frames
+// over maxFrameSize are refused with close code 1009 without being read.
+const (
+	maxMessageSize = 4 << 10
+	maxFrameSize   = 64 << 10
+)
+
+// Error codes sent in the data of "error" replies.
+const (
+	errInvalidMessage       = "invalid_message"
+	errMessageTooLarge      = "message_too_large"
+	errUnknownAction        = "unknown_action"
+	errInvalidTimezone      = "invalid_timezone"
+	errInvalidFormat        = "invalid_format"
+	errInvalidPrecision     = "invalid_precision"
+	errUnsupportedLocale    = "unsupported_locale"
+	errInvalidInterval      = "invalid_interval"
+	errInvalidTarget        = "invalid_target"
+	errInvalidOnChange      = "invalid_on_change"
+	errTooManySubscriptions = "too_many_subscriptions"
+	errUnknownSubscription  = "unknown_subscription"
+	errAlarmsUnavailable    = "alarms_unavailable"
+	errForbidden            = "forbidden"
+)
+
+func wsError(code, field, format string, args ...interface{}) *models.WebSocketError {
+	return &models.WebSocketError{Code: code, Message: fmt.Sprintf(format, args...), Field: field}
+}
+
+// Keepalive defaults used when the config leaves a WebSocket timing unset.
+const (
+	defaultPingInterval = 30 * time.Second
+	defaultPongWait     = 60 * time.Second
+	defaultWriteWait    = 10 * time.Second
+)
+
+// wsTimings holds the keepalive and deadline durations for a connection.
+type wsTimings struct {
+	pingInterval time.Duration
+	pongWait     time.Duration
+	writeWait    time.Duration
+}
+
+func seconds(n int, def time.Duration) time.Duration {
+	if n <= 0 {
+		return def
+	}
+	return time.Duration(n) * time.Second
+}
+
+// timings converts the config's WS_* seconds into durations. Pings must go out
+// before the pong deadline expires, so the interval is capped below it.
+func timings(cfg *config.Config) wsTimings {
+	t := wsTimings{
+		pingInterval: seconds(cfg.WSPingInterval, defaultPingInterval),
+		pongWait:     seconds(cfg.WSPongWait, defaultPongWait),
+		writeWait:    seconds(cfg.WSWriteWait, defaultWriteWait),
+	}
+	if t.pingInterval >= t.pongWait {
+		t.pingInterval = t.pongWait * 9 / 10
+	}
+	return t
+}
+
 type WSHandler struct {
 	timeService *services.TimeService
-	cfg         *config.Config
+	cfg         atomic.Pointer[config.Config]
+	hub         *Hub
+	limiter     *ConnLimiter
 }
 
+// NewWSHandler creates the handler and starts the broadcast hub that drives
+// all of its connections.
 func NewWSHandler(cfg *config.Config) *WSHandler {
-	return &WSHandler{
-		timeService: services.NewTimeService(),
-		cfg:         cfg,
+	timeService := services.NewTimeService()
+	hub := NewHub(timeService, cfg.WSMinInterval, cfg.WSMaxInterval, cfg.WSSendQueue)
+	go hub.Run()
+	h := &WSHandler{
+		timeService: timeService,
+		hub:         hub,
+		limiter:     NewConnLimiter(cfg.WSMaxConnections, cfg.WSMaxConnsPerIP),
 	}
+	h.cfg.Store(cfg)
+	return h
 }
 
-func (h *WSHandler) ServeHTTP(c *websocket.Conn) {
-	tz := h.cfg.DefaultTimezone
-	if tz == "" {
-		tz = "UTC"
+// Reload applies cfg's connection caps, message rate limits, idle timeout and
+// default timezone. Open connections keep the settings they started with,
+// except that the caps apply to every later upgrade.
+func (h *WSHandler) Reload(cfg *config.Config) {
+	h.cfg.Store(cfg)
+	h.limiter.SetLimits(cfg.WSMaxConnections, cfg.WSMaxConnsPerIP)
+}
+
+// Stop stops the hub started by NewWSHandler without disconnecting anyone.
+// Shutdown also stops it.
+func (h *WSHandler) Stop() {
+	h.hub.Stop()
+}
+
+// Hub returns the broadcast hub shared by all connections.
+func (h *WSHandler) Hub() *Hub {
+	return h.hub
+}
+
+// wsIPKey is the Locals key under which Admit records the client IP whose
+// connection slot the WebSocket handler must release.
+const wsIPKey = "ws_ip"
+
+// wsPrincipalKey is the Locals key under which Admit records the
+// authenticated caller, whose scopes the actions check after the upgrade.
+const wsPrincipalKey = "ws_principal"
+
+// Admit reserves a connection slot for the client IP before the upgrade,
+// answering 503 when the server is at WS_MAX_CONNECTIONS and 429 when the IP
+// is at WS_MAX_CONNECTIONS_PER_IP. The slot is released when the connection
+// ends, or right away if the upgrade fails.
+func (h *WSHandler) Admit(c *fiber.Ctx) error {
+	ip := c.IP()
+	switch h.limiter.Acquire(ip) {
+	case fiber.StatusServiceUnavailable:
+		c.Set(fiber.HeaderRetryAfter, "30")
+		return fiber.NewError(fiber.StatusServiceUnavailable, "too many WebSocket connections")
+	case fiber.StatusTooManyRequests:
+		c.Set(fiber.HeaderRetryAfter, "30")
+		return fiber.NewError(fiber.StatusTooManyRequests, "too many WebSocket connections from "+ip)
+	}
+	c.Locals(wsIPKey, ip)
+	c.Locals(wsPrincipalKey, auth.FromContext(c))
+	if err := c.Next(); err != nil {
+		h.limiter.Release(ip)
+		return err
 	}
-	format := "12hour"
-	stop := make(chan bool)
+	return nil
+}
 
-	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				resp, _ := h.timeService.GetCurrentTime(tz)
-				// Parse the timestamp from resp to get the timezone-converted time
-				if parsedTime, err := time.Parse(time.RFC3339, resp.Timestamp); err == nil {
-					resp.Formatted = h.timeService.FormatTime(parsedTime, format)
-				}
-				msg := models.WebSocketMessage{
-					Type:      "time_update",
-					Data:      resp,
-					Timestamp: time.Now().Format(time.RFC3339),
-				}
-				if err := c.WriteJSON(msg); err != nil {
-					log.Errorf("WebSocket write error: %v", err)
-					return
+// WSStats combines the hub and connection limiter counters.
+type WSStats struct {
+	Hub         HubStats  `json:"hub"`
+	Connections ConnStats `json:"connections"`
+}
+
+// @Summary WebSocket statistics
+// @Description Counters for the broadcast hub and WebSocket connection limits
+// @Tags WebSocket
+// @Success 200 {object} handlers.WSStats
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /ws/stats [get]
+func (h *WSHandler) Stats(c *fiber.Ctx) error {
+	return c.JSON(h.CurrentStats())
+}
+
+// CurrentStats returns a snapshot of the hub and connection counters.
+func (h *WSHandler) CurrentStats() WSStats {
+	return WSStats{Hub: h.hub.Stats(), Connections: h.limiter.Stats()}
+}
+
+// Shutdown disconnects every WebSocket and SSE client with notice, then waits
+// until the WebSocket connections have finished their close handshakes or ctx
+// ends.
+func (h *WSHandler) Shutdown(ctx context.Context, notice models.GoingAway) error {
+	h.hub.Shutdown(notice)
+	poll := time.NewTicker(50 * time.Millisecond)
+	defer poll.Stop()
+	for h.limiter.Stats().Active > 0 {
+		select {
+		case <-poll.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%d WebSocket connections still open: %w", h.limiter.Stats().Active, ctx.Err())
+		}
+	}
+	return nil
+}
+
+// subscription holds the settings of one stream on a connection so later
+// subscribe messages can change individual fields. The subscription with an
+// empty ID is the legacy default stream that every connection starts with.
+type subscription struct {
+	timezone string
+	opts     services.TimeOptions
+	interval time.Duration
+	onChange changeMode
+}
+
+// defaultSubscription is what a connection streams before any subscribe
+// message: one update per second (or the nearest allowed interval).
+func defaultSubscription(hub *Hub, timezone, localeTag string) subscription {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	interval, ok := hub.Interval(time.Second)
+	if !ok {
+		interval = hub.resolution
+		if time.Second > hub.maxInterval {
+			interval = hub.maxInterval
+		}
+	}
+	return subscription{
+		timezone: timezone,
+		opts: services.TimeOptions{
+			Precision: services.PrecisionSecond,
+			Locale:    localeTag,
+		},
+		interval: interval,
+	}
+}
+
+// apply validates a subscribe message and merges its non-empty fields into s.
+// On error s is left unchanged.
+func (s *subscription) apply(hub *Hub, msg *models.WebSocketMessage) *models.WebSocketError {
+	ts := hub.timeService
+	next := *s
+	if msg.Timezone != "" {
+		if _, err := services.LoadLocation(msg.Timezone); err != nil {
+			return wsError(errInvalidTimezone, "timezone", "invalid timezone: %s", msg.Timezone)
+		}
+		next.timezone = msg.Timezone
+	}
+	if msg.Format != "" {
+		if !ts.IsValidFormat(msg.Format) {
+			return wsError(errInvalidFormat, "format", "invalid format: %s", msg.Format)
+		}
+		next.opts.Format = msg.Format
+	}
+	if msg.Precision != "" {
+		p, err := services.ParsePrecision(msg.Precision)
+		if err != nil {
+			return wsError(errInvalidPrecision, "precision", "%s", err.Error())
+		}
+		next.opts.Precision = p
+	}
+	if msg.Locale != "" {
+		if _, ok := locale.Get(msg.Locale); !ok {
+			return wsError(errUnsupportedLocale, "locale", "unsupported locale: %s", msg.Locale)
+		}
+		next.opts.Locale = msg.Locale
+	}
+	if msg.Interval != "" {
+		d, err := time.ParseDuration(msg.Interval)
+		if err != nil {
+			return wsError(errInvalidInterval, "interval", "invalid interval: %s", msg.Interval)
+		}
+		interval, ok := hub.Interval(d)
+		if !ok {
+			return wsError(errInvalidInterval, "interval", "interval must be between %s and %s", hub.resolution, hub.maxInterval)
+		}
+		next.interval = interval
+		next.onChange = 0
+	}
+	if msg.OnChange != "" {
+		var mode changeMode
+		if msg.OnChange != "none" {
+			for _, name := range strings.Split(msg.OnChange, ",") {
+				m, ok := changeModes[strings.TrimSpace(name)]
+				if !ok {
+					return wsError(errInvalidOnChange, "on_change", "invalid on_change mode: %s (expected minute, hour, day, midnight or dst)", name)
 				}
-			case <-stop:
+				mode |= m
+			}
+		}
+		next.onChange = mode
+	}
+	*s = next
+	return nil
+}
+
+// ack describes the settings a subscription now uses.
+func (s subscription) ack() models.SubscriptionAck {
+	ack := models.SubscriptionAck{
+		Timezone:  s.timezone,
+		Format:    s.opts.Format,
+		Precision: s.opts.Precision,
+		Locale:    s.opts.Locale,
+		Interval:  s.interval.String(),
+		OnChange:  s.onChange.String(),
+	}
+	if s.onChange != 0 {
+		ack.Interval = ""
+	}
+	return ack
+}
+
+// countdown validates a countdown message on top of the connection defaults
+// and returns the stream key and ack for it.
+func (h *WSHandler) countdown(defaults subscription, msg *models.WebSocketMessage, now time.Time) (streamKey, models.SubscriptionAck, *models.WebSocketError) {
+	if msg.Target == "" {
+		return streamKey{}, models.SubscriptionAck{}, wsError(errInvalidTarget, "target", "target is required")
+	}
+	target, err := time.Parse(time.RFC3339, msg.Target)
+	if err != nil {
+		return streamKey{}, models.SubscriptionAck{}, wsError(errInvalidTarget, "target", "invalid target timestamp: %s", msg.Target)
+	}
+	if !target.After(now) {
+		return streamKey{}, models.SubscriptionAck{}, wsError(errInvalidTarget, "target", "target is in the past: %s", msg.Target)
+	}
+	sub := defaults
+	if e := sub.apply(h.hub, msg); e != nil {
+		return streamKey{}, models.SubscriptionAck{}, e
+	}
+	key := sub.key()
+	key.target = target.UnixNano()
+	ack := sub.ack()
+	ack.Target = target.UTC().Format(time.RFC3339Nano)
+	return key, ack, nil
+}
+
+func (s subscription) key() streamKey {
+	if s.onChange != 0 {
+		return streamKey{timezone: s.timezone, opts: s.opts, onChange: s.onChange}
+	}
+	return streamKey{timezone: s.timezone, opts: s.opts, interval: s.interval}
+}
+
+// closeWith starts the close handshake with code and reason. WriteControl is
+// safe to call concurrently with the write pump.
+func closeWith(c *websocket.Conn, code int, reason string, writeWait time.Duration) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+}
+
+// writePump drains the client's queue onto the socket and sends keepalive
+// pings. Every write carries a deadline so a stuck peer cannot block it. A
+// client evicted as a slow consumer is sent a policy-violation close frame,
+// and one disconnected by shutdown a going_away message and a going-away close
+// frame; either is given writeWait to answer before the read loop gives up.
+func writePump(c *websocket.Conn, client *Client, t wsTimings) {
+	ping := time.NewTicker(t.pingInterval)
+	defer ping.Stop()
+	write := func(msg []byte) bool {
+		c.SetWriteDeadline(time.Now().Add(t.writeWait))
+		if err := c.WriteMessage(client.codec.frameType, msg); err != nil {
+			slog.Error("WebSocket write failed", "error", err)
+			client.hub.Unregister(client)
+			return false
+		}
+		return true
+	}
+	reply := func(render func() interface{}) bool {
+		msg, err := client.codec.marshal(render())
+		if err != nil {
+			return true
+		}
+		client.hub.sent.Add(1)
+		return write(msg)
+	}
+	for {
+		// Replies go ahead of queued updates.
+		select {
+		case render := <-client.replies:
+			if !reply(render) {
+				return
+			}
+			continue
+		default:
+		}
+		select {
+		case render := <-client.replies:
+			if !reply(render) {
+				return
+			}
+		case msg := <-client.Send():
+			if !write(msg) {
+				return
+			}
+		case <-ping.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(t.writeWait)); err != nil {
+				client.hub.Unregister(client)
 				return
 			}
+		case <-client.Done():
+			if client.Evicted() {
+				closeWith(c, websocket.ClosePolicyViolation, "slow consumer", t.writeWait)
+				c.SetReadDeadline(time.Now().Add(t.writeWait))
+			} else if notice := client.GoingAway(); notice != nil {
+				if msg, err := client.codec.marshal(models.WebSocketMessage{Type: "going_away", Data: notice}); err == nil {
+					c.SetWriteDeadline(time.Now().Add(t.writeWait))
+					c.WriteMessage(client.codec.frameType, msg)
+				}
+				closeWith(c, websocket.CloseGoingAway, notice.Reason, t.writeWait)
+				c.SetReadDeadline(time.Now().Add(t.writeWait))
+			}
+			return
+		}
+	}
+}
+
+func (h *WSHandler) ServeHTTP(c *websocket.Conn) {
+	cfg := h.cfg.Load()
+	defaults := defaultSubscription(h.hub, cfg.DefaultTimezone, locale.Negotiate(c.Headers("Accept-Language")))
+	subs := map[string]subscription{"": defaults}
+
+	// The upgrader already picked the subprotocol; no match means JSON.
+	// Compression only takes effect when the client negotiated
+	// permessage-deflate.
+	cd := codecFor(c.Subprotocol())
+	if cfg.WSCompression {
+		c.EnableWriteCompression(true)
+		c.SetCompressionLevel(cfg.WSCompressionLevel)
+	}
+
+	if ip, ok := c.Locals(wsIPKey).(string); ok {
+		defer h.limiter.Release(ip)
+	}
+	// Without auth there is no principal and every action is allowed.
+	principal, _ := c.Locals(wsPrincipalKey).(*auth.Principal)
+	readAlarms := principal == nil || principal.HasScope(auth.ScopeAlarmsRead)
+
+	t := timings(cfg)
+	// closing is set once the server has sent its own close frame; from
+	// then on the read loop only waits for the peer's reply.
+	var closing atomic.Bool
+	closeNow := func(code int, reason string) {
+		if closing.CompareAndSwap(false, true) {
+			closeWith(c, code, reason, t.writeWait)
+			c.SetReadDeadline(time.Now().Add(t.writeWait))
 		}
+	}
+	c.SetReadLimit(maxFrameSize)
+	c.SetReadDeadline(time.Now().Add(t.pongWait))
+	c.SetPongHandler(func(string) error {
+		if closing.Load() {
+			return nil
+		}
+		return c.SetReadDeadline(time.Now().Add(t.pongWait))
+	})
+
+	// Pongs keep the socket open but only client messages reset the idle
+	// timer.
+	var idle *time.Timer
+	idleTimeout := seconds(cfg.WSIdleTimeout, 0)
+	if idleTimeout > 0 {
+		idle = time.AfterFunc(idleTimeout, func() {
+			h.limiter.idleClosed.Add(1)
+			closeNow(websocket.CloseGoingAway, "idle timeout")
+		})
+		defer idle.Stop()
+	}
+	var bucket *tokenBucket
+	if cfg.WSMessageRate > 0 {
+		bucket = newTokenBucket(float64(cfg.WSMessageRate), cfg.WSMessageBurst, time.Now())
+	}
+
+	client := h.hub.register(cd)
+	h.hub.Subscribe(client, "", defaults.key())
+
+	done := make(chan struct{})
+	go func() {
+		writePump(c, client, t)
+		close(done)
 	}()
+	defer func() {
+		h.hub.Unregister(client)
+		<-done
+		c.Close()
+	}()
+
+	// atLimit reports whether adding subscription id would exceed the cap.
+	atLimit := func(id string) bool {
+		return !h.hub.Subscribed(client, id) && h.hub.SubscriptionCount(client) >= maxSubscriptions
+	}
+	tooManySubscriptions := wsError(errTooManySubscriptions, "id", "at most %d subscriptions per connection", maxSubscriptions)
+	reply := func(msg *models.WebSocketMessage, typ string, data interface{}) {
+		client.EnqueueMessage(models.WebSocketMessage{Type: typ, ID: msg.ID, Action: msg.Action, Data: data})
+	}
 
 	for {
+		frameType, raw, err := c.ReadMessage()
+		if err != nil {
+			// A peer-initiated close is echoed by the default close handler.
+			// A missed pong means the peer went silent; say why before
+			// dropping it.
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() && !client.Evicted() && client.GoingAway() == nil && !closing.Load() {
+				closeWith(c, websocket.CloseGoingAway, "pong timeout", t.writeWait)
+			}
+			return
+		}
+		received := time.Now()
+		if closing.Load() {
+			continue
+		}
+		if bucket != nil && !bucket.allow(received) {
+			h.limiter.rateLimited.Add(1)
+			closeNow(websocket.ClosePolicyViolation, "rate limit exceeded")
+			continue
+		}
+		if idle != nil {
+			idle.Reset(idleTimeout)
+		}
 		var msg models.WebSocketMessage
-		if err := c.ReadJSON(&msg); err != nil {
-			close(stop)
-			break
+		if len(raw) > maxMessageSize {
+			reply(&msg, "error", wsError(errMessageTooLarge, "", "message exceeds %d bytes", maxMessageSize))
+			continue
+		}
+		// Text frames are always JSON; binary frames use the negotiated
+		// encoding.
+		dec := jsonCodec
+		if frameType == websocket.BinaryMessage {
+			dec = cd
 		}
-		if msg.Action == "subscribe" {
-			if msg.Timezone != "" {
-				tz = msg.Timezone
+		if err := dec.unmarshal(raw, &msg); err != nil {
+			reply(&msg, "error", wsError(errInvalidMessage, "", "invalid %s message: %v", strings.ToUpper(dec.name), err))
+			continue
+		}
+		switch msg.Action {
+		case "sync":
+			// The transmit time is taken as the reply is written, not
+			// behind whatever updates are queued.
+			id, originate := msg.ID, msg.Originate
+			client.EnqueueReply(func() interface{} {
+				return models.WebSocketMessage{Type: "sync", ID: id, Action: "sync", Data: h.timeService.ClockSync(originate, received)}
+			})
+		case "subscribe":
+			if atLimit(msg.ID) {
+				reply(&msg, "error", tooManySubscriptions)
+				continue
+			}
+			sub, ok := subs[msg.ID]
+			if !ok {
+				sub = defaults
+			}
+			if e := sub.apply(h.hub, &msg); e != nil {
+				reply(&msg, "error", e)
+				continue
+			}
+			subs[msg.ID] = sub
+			h.hub.Subscribe(client, msg.ID, sub.key())
+			reply(&msg, "ack", sub.ack())
+			if sub.onChange != 0 {
+				h.hub.Snapshot(client, msg.ID, sub.key())
+			}
+		case "countdown":
+			if atLimit(msg.ID) {
+				reply(&msg, "error", tooManySubscriptions)
+				continue
+			}
+			key, ack, e := h.countdown(defaults, &msg, received)
+			if e != nil {
+				reply(&msg, "error", e)
+				continue
+			}
+			// A countdown replaces any time subscription with the same ID.
+			delete(subs, msg.ID)
+			h.hub.Subscribe(client, msg.ID, key)
+			reply(&msg, "ack", ack)
+		case "alarms":
+			if !h.hub.alarms.Load() {
+				reply(&msg, "error", wsError(errAlarmsUnavailable, "action", "alarms are disabled on this server"))
+				continue
+			}
+			if !readAlarms {
+				reply(&msg, "error", wsError(errForbidden, "action", "the alarms action requires the %s scope", auth.ScopeAlarmsRead))
+				continue
+			}
+			if atLimit(msg.ID) {
+				reply(&msg, "error", tooManySubscriptions)
+				continue
+			}
+			alarm := msg.AlarmID
+			if alarm == "" {
+				alarm = allAlarms
 			}
-			if msg.Format != "" {
-				format = msg.Format
+			delete(subs, msg.ID)
+			h.hub.Subscribe(client, msg.ID, streamKey{alarm: alarm})
+			reply(&msg, "ack", nil)
+		case "unsubscribe":
+			delete(subs, msg.ID)
+			if !h.hub.Unsubscribe(client, msg.ID) {
+				reply(&msg, "error", wsError(errUnknownSubscription, "id", "no subscription with id %q", msg.ID))
+				continue
 			}
+			reply(&msg, "ack", nil)
+		default:
+			reply(&msg, "error", wsError(errUnknownAction, "action", "unknown action: %q", msg.Action))
 		}
 	}
 }
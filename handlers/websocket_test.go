@@ -1,21 +1,67 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"gotimedate/config"
+	"gotimedate/models"
+	"net"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	fws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 )
 
+// startWSServer serves h on a random local port and returns its /ws/time URL.
+func startWSServer(t *testing.T, h *WSHandler) string {
+	t.Helper()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/ws/time", h.Admit, websocket.New(h.ServeHTTP, websocket.Config{
+		Subprotocols:      Subprotocols(),
+		EnableCompression: true,
+	}))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "ws://" + ln.Addr().String() + "/ws/time"
+}
+
+// newWSHandler builds a handler whose hub is stopped when the test ends.
+func newWSHandler(t *testing.T, cfg *config.Config) *WSHandler {
+	t.Helper()
+	h := NewWSHandler(cfg)
+	t.Cleanup(h.Stop)
+	return h
+}
+
+func dialWS(t *testing.T, url string) *fws.Conn {
+	t.Helper()
+	conn, _, err := fws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
 func TestNewWSHandler(t *testing.T) {
 	cfg := &config.Config{
 		AllowedOrigins: []string{"http://localhost:3000", "http://localhost:8080"},
 	}
-	handler := NewWSHandler(cfg)
+	handler := newWSHandler(t, cfg)
 
 	if handler.timeService == nil {
 		t.Error("Expected timeService to be initialized")
 	}
 
-	if handler.cfg == nil {
+	if handler.cfg.Load() == nil {
 		t.Error("Expected config to be set")
 	}
 }
@@ -43,3 +89,553 @@ func TestWebSocketCORS(t *testing.T) {
 		}
 	}
 }
+
+func TestWebSocketMultipleSubscriptions(t *testing.T) {
+	url := startWSServer(t, newWSHandler(t, &config.Config{DefaultTimezone: "UTC"}))
+	conn := dialWS(t, url)
+
+	for _, sub := range []models.WebSocketMessage{
+		{Action: "subscribe", ID: "tokyo", Timezone: "Asia/Tokyo", Format: "24hour"},
+		{Action: "subscribe", ID: "nyc", Timezone: "America/New_York"},
+	} {
+		if err := conn.WriteJSON(sub); err != nil {
+			t.Fatalf("failed to subscribe: %v", err)
+		}
+	}
+
+	seen := map[string]string{}
+	deadline := time.Now().Add(5 * time.Second)
+	conn.SetReadDeadline(deadline)
+	for len(seen) < 3 && time.Now().Before(deadline) {
+		var msg struct {
+			Type string              `json:"type"`
+			ID   string              `json:"id"`
+			Data models.TimeResponse `json:"data"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		if msg.Type == "time_update" {
+			seen[msg.ID] = msg.Data.Timezone
+		}
+	}
+
+	want := map[string]string{"": "UTC", "tokyo": "Asia/Tokyo", "nyc": "America/New_York"}
+	for id, tz := range want {
+		if seen[id] != tz {
+			t.Errorf("subscription %q: expected timezone %s, got %q", id, tz, seen[id])
+		}
+	}
+}
+
+func TestWebSocketSilentClientDisconnected(t *testing.T) {
+	h := newWSHandler(t, &config.Config{WSPingInterval: 1, WSPongWait: 1, WSWriteWait: 1})
+	conn := dialWS(t, startWSServer(t, h))
+	// A silent client never answers pings.
+	conn.SetPingHandler(func(string) error { return nil })
+
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(5 * time.Second))
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	if !fws.IsCloseError(err, fws.CloseGoingAway) {
+		t.Fatalf("expected going-away close, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected disconnect after pong wait, got %s", elapsed)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for h.Hub().Stats().Clients != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := h.Hub().Stats().Clients; n != 0 {
+		t.Errorf("expected client to be unregistered, got %d clients", n)
+	}
+}
+
+func TestWebSocketPongKeepsConnectionAlive(t *testing.T) {
+	h := newWSHandler(t, &config.Config{WSPingInterval: 1, WSPongWait: 1, WSWriteWait: 1})
+	conn := dialWS(t, startWSServer(t, h))
+
+	// The default ping handler answers with a pong while we read.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	until := time.Now().Add(2500 * time.Millisecond)
+	for time.Now().Before(until) {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("expected connection to stay open, got %v", err)
+		}
+	}
+}
+
+func TestWebSocketCloseHandshake(t *testing.T) {
+	h := newWSHandler(t, &config.Config{})
+	conn := dialWS(t, startWSServer(t, h))
+
+	msg := fws.FormatCloseMessage(fws.CloseNormalClosure, "bye")
+	if err := conn.WriteControl(fws.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("failed to send close: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	if !fws.IsCloseError(err, fws.CloseNormalClosure) {
+		t.Errorf("expected normal close echoed, got %v", err)
+	}
+}
+
+// readReply returns the next message that is not a time_update.
+func readReply(t *testing.T, conn *fws.Conn) models.WebSocketMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		var msg models.WebSocketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		if msg.Type != "time_update" {
+			return msg
+		}
+	}
+}
+
+func TestWebSocketValidation(t *testing.T) {
+	conn := dialWS(t, startWSServer(t, newWSHandler(t, &config.Config{})))
+
+	tests := []struct {
+		name  string
+		raw   string
+		code  string
+		field string
+	}{
+		{"bad timezone", `{"action":"subscribe","timezone":"Mars/Olympus"}`, errInvalidTimezone, "timezone"},
+		{"bad format", `{"action":"subscribe","format":"sundial"}`, errInvalidFormat, "format"},
+		{"bad precision", `{"action":"subscribe","precision":"ps"}`, errInvalidPrecision, "precision"},
+		{"bad locale", `{"action":"subscribe","locale":"tlh"}`, errUnsupportedLocale, "locale"},
+		{"bad interval", `{"action":"subscribe","interval":"soon"}`, errInvalidInterval, "interval"},
+		{"interval too short", `{"action":"subscribe","interval":"10ms"}`, errInvalidInterval, "interval"},
+		{"interval too long", `{"action":"subscribe","interval":"2h"}`, errInvalidInterval, "interval"},
+		{"bad on_change", `{"action":"subscribe","on_change":"minute,fortnight"}`, errInvalidOnChange, "on_change"},
+		{"unknown action", `{"action":"teleport"}`, errUnknownAction, "action"},
+		{"missing action", `{"timezone":"UTC"}`, errUnknownAction, "action"},
+		{"unknown subscription", `{"action":"unsubscribe","id":"nope"}`, errUnknownSubscription, "id"},
+		{"alarms disabled", `{"action":"alarms"}`, errAlarmsUnavailable, "action"},
+		{"invalid json", `{"action":`, errInvalidMessage, ""},
+		{"countdown without target", `{"action":"countdown","id":"c"}`, errInvalidTarget, "target"},
+		{"countdown bad target", `{"action":"countdown","target":"tomorrow"}`, errInvalidTarget, "target"},
+		{"countdown past target", `{"action":"countdown","target":"2001-01-01T00:00:00Z"}`, errInvalidTarget, "target"},
+		{"countdown bad timezone", `{"action":"countdown","target":"2999-01-01T00:00:00Z","timezone":"Mars/Olympus"}`, errInvalidTimezone, "timezone"},
+		{"too large", `{"action":"subscribe","id":"` + strings.Repeat("x", maxMessageSize) + `"}`, errMessageTooLarge, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := conn.WriteMessage(fws.TextMessage, []byte(tt.raw)); err != nil {
+				t.Fatalf("failed to send: %v", err)
+			}
+			msg := readReply(t, conn)
+			if msg.Type != "error" {
+				t.Fatalf("expected error reply, got %+v", msg)
+			}
+			data := msg.Data.(map[string]interface{})
+			if data["code"] != tt.code {
+				t.Errorf("expected code %s, got %v", tt.code, data["code"])
+			}
+			if field, _ := data["field"].(string); field != tt.field {
+				t.Errorf("expected field %q, got %q", tt.field, field)
+			}
+		})
+	}
+
+	// The rejected timezone must not have replaced the default stream.
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var update struct {
+		Type string              `json:"type"`
+		Data models.TimeResponse `json:"data"`
+	}
+	for update.Type != "time_update" {
+		if err := conn.ReadJSON(&update); err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+	}
+	if update.Data.Timezone != "UTC" {
+		t.Errorf("expected UTC updates to continue, got %q", update.Data.Timezone)
+	}
+}
+
+func TestWebSocketAck(t *testing.T) {
+	conn := dialWS(t, startWSServer(t, newWSHandler(t, &config.Config{})))
+
+	conn.WriteJSON(models.WebSocketMessage{Action: "subscribe", ID: "tokyo", Timezone: "Asia/Tokyo", Interval: "5s"})
+	msg := readReply(t, conn)
+	if msg.Type != "ack" || msg.ID != "tokyo" || msg.Action != "subscribe" {
+		t.Fatalf("expected subscribe ack for tokyo, got %+v", msg)
+	}
+	data := msg.Data.(map[string]interface{})
+	if data["timezone"] != "Asia/Tokyo" || data["interval"] != "5s" {
+		t.Errorf("expected ack to echo settings, got %v", data)
+	}
+
+	conn.WriteJSON(models.WebSocketMessage{Action: "unsubscribe", ID: "tokyo"})
+	msg = readReply(t, conn)
+	if msg.Type != "ack" || msg.Action != "unsubscribe" {
+		t.Errorf("expected unsubscribe ack, got %+v", msg)
+	}
+}
+
+func TestWebSocketFrameTooLarge(t *testing.T) {
+	conn := dialWS(t, startWSServer(t, newWSHandler(t, &config.Config{})))
+
+	conn.WriteMessage(fws.TextMessage, make([]byte, maxFrameSize+1))
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	if !fws.IsCloseError(err, fws.CloseMessageTooBig) {
+		t.Errorf("expected close 1009, got %v", err)
+	}
+}
+
+func TestWebSocketCountdown(t *testing.T) {
+	h := newWSHandler(t, &config.Config{})
+	conn := dialWS(t, startWSServer(t, h))
+
+	target := time.Now().Add(2500 * time.Millisecond).Truncate(time.Millisecond)
+	conn.WriteJSON(models.WebSocketMessage{
+		Action:   "countdown",
+		ID:       "launch",
+		Target:   target.Format(time.RFC3339Nano),
+		Timezone: "Asia/Tokyo",
+	})
+	msg := readReply(t, conn)
+	if msg.Type != "ack" || msg.ID != "launch" || msg.Action != "countdown" {
+		t.Fatalf("expected countdown ack, got %+v", msg)
+	}
+
+	updates := 0
+	conn.SetReadDeadline(target.Add(2 * time.Second))
+	for {
+		var msg struct {
+			Type      string                   `json:"type"`
+			ID        string                   `json:"id"`
+			Data      models.CountdownResponse `json:"data"`
+			Timestamp string                   `json:"timestamp"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		if msg.ID != "launch" {
+			continue
+		}
+		if msg.Type == "countdown" {
+			updates++
+			if msg.Data.RemainingMs <= 0 || msg.Data.Seconds < 1 {
+				t.Errorf("expected time left before completion, got %+v", msg.Data)
+			}
+			continue
+		}
+		received := time.Now()
+		if msg.Type != "countdown_complete" {
+			t.Fatalf("expected countdown_complete, got %s", msg.Type)
+		}
+		if received.Before(target) {
+			t.Errorf("completion arrived %s early", target.Sub(received))
+		}
+		if late := received.Sub(target); late > 200*time.Millisecond {
+			t.Errorf("completion arrived %s late", late)
+		}
+		if msg.Timestamp != target.UTC().Format(time.RFC3339Nano) {
+			t.Errorf("expected timestamp %s, got %s", target.UTC().Format(time.RFC3339Nano), msg.Timestamp)
+		}
+		if msg.Data.RemainingMs != 0 || msg.Data.Target.Timezone != "Asia/Tokyo" {
+			t.Errorf("expected zero remaining in Asia/Tokyo, got %+v", msg.Data)
+		}
+		break
+	}
+	if updates < 1 {
+		t.Error("expected at least one countdown update")
+	}
+	if n := h.Hub().Stats().Streams; n != 1 {
+		t.Errorf("expected only the default stream after completion, got %d streams", n)
+	}
+}
+
+func TestWebSocketOnChange(t *testing.T) {
+	conn := dialWS(t, startWSServer(t, newWSHandler(t, &config.Config{})))
+
+	conn.WriteJSON(models.WebSocketMessage{Action: "subscribe", ID: "clock", Timezone: "Asia/Kolkata", OnChange: "day,minute"})
+	msg := readReply(t, conn)
+	if msg.Type != "ack" {
+		t.Fatalf("expected ack, got %+v", msg)
+	}
+	data := msg.Data.(map[string]interface{})
+	if data["on_change"] != "minute,day" || data["interval"] != nil {
+		t.Errorf("expected on_change minute,day without interval, got %v", data)
+	}
+
+	// The current value arrives at once rather than at the next boundary.
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var update models.WebSocketMessage
+		if err := conn.ReadJSON(&update); err != nil {
+			t.Fatalf("expected immediate snapshot: %v", err)
+		}
+		if update.ID == "clock" {
+			if update.Type != "time_update" {
+				t.Errorf("expected time_update, got %s", update.Type)
+			}
+			break
+		}
+	}
+}
+
+func TestWebSocketSubSecondInterval(t *testing.T) {
+	conn := dialWS(t, startWSServer(t, newWSHandler(t, &config.Config{})))
+	conn.WriteJSON(models.WebSocketMessage{Action: "subscribe", ID: "fast", Interval: "100ms", Precision: "ms"})
+
+	updates := 0
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	start := time.Now()
+	for updates < 10 {
+		var msg models.WebSocketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		if msg.ID == "fast" && msg.Type == "time_update" {
+			updates++
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("expected 10 updates at 100ms in about 1s, took %s", elapsed)
+	}
+}
+
+func TestWebSocketSubprotocol(t *testing.T) {
+	url := startWSServer(t, newWSHandler(t, &config.Config{DefaultTimezone: "UTC", WSCompression: true, WSCompressionLevel: 1}))
+
+	for _, cd := range codecs {
+		t.Run(cd.name, func(t *testing.T) {
+			dialer := fws.Dialer{Subprotocols: []string{cd.name}, EnableCompression: true}
+			conn, resp, err := dialer.Dial(url, nil)
+			if err != nil {
+				t.Fatalf("failed to dial: %v", err)
+			}
+			defer conn.Close()
+			if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != cd.name {
+				t.Errorf("expected subprotocol %s, got %q", cd.name, got)
+			}
+
+			req, _ := cd.marshal(models.WebSocketMessage{Action: "subscribe", ID: "tokyo", Timezone: "Asia/Tokyo"})
+			if err := conn.WriteMessage(cd.frameType, req); err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+			conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+			for {
+				frameType, raw, err := conn.ReadMessage()
+				if err != nil {
+					t.Fatalf("failed to read: %v", err)
+				}
+				if frameType != cd.frameType {
+					t.Fatalf("expected frame type %d, got %d", cd.frameType, frameType)
+				}
+				var got timeFrame
+				if err := cd.unmarshal(raw, &got); err != nil {
+					t.Fatalf("invalid %s frame: %v", cd.name, err)
+				}
+				if got.Type == "time_update" && got.ID == "tokyo" {
+					if got.Data.Timezone != "Asia/Tokyo" {
+						t.Errorf("expected Asia/Tokyo, got %s", got.Data.Timezone)
+					}
+					return
+				}
+			}
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		conn, resp, err := fws.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		defer conn.Close()
+		if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "" {
+			t.Errorf("expected no subprotocol, got %q", got)
+		}
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		frameType, _, err := conn.ReadMessage()
+		if err != nil || frameType != fws.TextMessage {
+			t.Errorf("expected a JSON text frame, got type %d, err %v", frameType, err)
+		}
+	})
+}
+
+// readClose reads until the connection closes and returns the close error.
+func readClose(t *testing.T, conn *fws.Conn, timeout time.Duration) error {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	return err
+}
+
+func TestWebSocketConnectionLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    *config.Config
+		status int
+	}{
+		{"per IP", &config.Config{WSMaxConnsPerIP: 2}, fiber.StatusTooManyRequests},
+		{"global", &config.Config{WSMaxConnections: 2, WSMaxConnsPerIP: 5}, fiber.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newWSHandler(t, tt.cfg)
+			url := startWSServer(t, h)
+			first := dialWS(t, url)
+			dialWS(t, url)
+
+			_, resp, err := fws.DefaultDialer.Dial(url, nil)
+			if err == nil {
+				t.Fatal("expected the third connection to be rejected")
+			}
+			if resp == nil || resp.StatusCode != tt.status {
+				t.Fatalf("expected status %d, got %v", tt.status, resp)
+			}
+			if resp.Header.Get("Retry-After") == "" {
+				t.Error("expected a Retry-After header")
+			}
+
+			// Closing a connection frees its slot.
+			first.Close()
+			deadline := time.Now().Add(2 * time.Second)
+			for h.limiter.Stats().Active != 1 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			dialWS(t, url)
+
+			stats := h.limiter.Stats()
+			if stats.RejectedTotal+stats.RejectedPerIP != 1 {
+				t.Errorf("expected one rejection, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestWebSocketMessageRateLimit(t *testing.T) {
+	h := newWSHandler(t, &config.Config{WSMessageRate: 1, WSMessageBurst: 3, WSWriteWait: 1})
+	conn := dialWS(t, startWSServer(t, h))
+
+	for i := 0; i < 5; i++ {
+		conn.WriteJSON(models.WebSocketMessage{Action: "sync"})
+	}
+	if err := readClose(t, conn, 3*time.Second); !fws.IsCloseError(err, fws.ClosePolicyViolation) {
+		t.Fatalf("expected policy-violation close, got %v", err)
+	}
+	if n := h.limiter.Stats().RateLimited; n != 1 {
+		t.Errorf("expected 1 rate-limited close, got %d", n)
+	}
+}
+
+func TestWebSocketIdleTimeout(t *testing.T) {
+	h := newWSHandler(t, &config.Config{WSIdleTimeout: 1, WSWriteWait: 1})
+	conn := dialWS(t, startWSServer(t, h))
+
+	start := time.Now()
+	err := readClose(t, conn, 5*time.Second)
+	if !fws.IsCloseError(err, fws.CloseGoingAway) {
+		t.Fatalf("expected going-away close, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected close after the idle timeout, got %s", elapsed)
+	}
+	if n := h.limiter.Stats().IdleClosed; n != 1 {
+		t.Errorf("expected 1 idle close, got %d", n)
+	}
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	h := newWSHandler(t, &config.Config{})
+	url := startWSServer(t, h)
+	conn := dialWS(t, url)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("expected a time update, got %v", err)
+	}
+
+	notice := models.GoingAway{Reason: "server shutting down", ReconnectAfterMs: 5000}
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		shutdown <- h.Shutdown(ctx, notice)
+	}()
+
+	var msg struct {
+		Type string           `json:"type"`
+		Data models.GoingAway `json:"data"`
+	}
+	for msg.Type != "going_away" {
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("expected a going_away message, got %v", err)
+		}
+	}
+	if msg.Data != notice {
+		t.Errorf("expected %+v, got %+v", notice, msg.Data)
+	}
+	var ce *fws.CloseError
+	if err := readClose(t, conn, 3*time.Second); !errors.As(err, &ce) || ce.Code != fws.CloseGoingAway || ce.Text != notice.Reason {
+		t.Errorf("expected close 1001 %q, got %v", notice.Reason, err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("expected shutdown to finish once the client closed, got %v", err)
+	}
+}
+
+func TestWebSocketSyncDuringUpdates(t *testing.T) {
+	conn := dialWS(t, startWSServer(t, newWSHandler(t, &config.Config{})))
+	// Fast updates keep the send queue busy while the sync is answered.
+	conn.WriteJSON(models.WebSocketMessage{Action: "subscribe", ID: "fast", Interval: "100ms"})
+	time.Sleep(300 * time.Millisecond)
+	sent := time.Now()
+	conn.WriteJSON(models.WebSocketMessage{Action: "sync", ID: "s1", Originate: float64(sent.UnixNano()) / 1e6})
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		var msg struct {
+			Type string                   `json:"type"`
+			ID   string                   `json:"id"`
+			Data models.ClockSyncResponse `json:"data"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("expected a sync reply, got %v", err)
+		}
+		if msg.Type != "sync" {
+			continue
+		}
+		if msg.ID != "s1" || msg.Data.TransmitNs < msg.Data.ReceiveNs || msg.Data.ReceiveNs < sent.UnixNano() {
+			t.Errorf("expected a stamped reply to s1, got %+v", msg)
+		}
+		return
+	}
+}
+
+func TestWSHandlerStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		NewWSHandler(&config.Config{}).Stop()
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected stopped hubs to exit, %d goroutines left over", n-before)
+	}
+}
@@ -0,0 +1,133 @@
+package locale
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numberingSystems maps CLDR numbering system names to their digits.
+var numberingSystems = map[string][]rune{
+	"latn":    []rune("0123456789"),
+	"arab":    []rune("٠١٢٣٤٥٦٧٨٩"),
+	"arabext": []rune("۰۱۲۳۴۵۶۷۸۹"),
+	"deva":    []rune("०१२३४५६७८९"),
+	"beng":    []rune("০১২৩৪৫৬৭৮৯"),
+	"thai":    []rune("๐๑๒๓๔๕๖๗๘๙"),
+}
+
+// Digits rewrites the ASCII digits in s using the locale's numbering system.
+func (l *Locale) Digits(s string) string {
+	digits := numberingSystems[l.Numbering]
+	if l.Numbering == "latn" || digits == nil {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return digits[r-'0']
+		}
+		return r
+	}, s)
+}
+
+// Hour12 reports whether the locale prefers a 12-hour clock.
+func (l *Locale) Hour12() bool {
+	return l.Calendar.HourCycle == "h12"
+}
+
+// FormatDate renders the long date, for example "Wednesday, January 3, 2024".
+func (l *Locale) FormatDate(t time.Time) string {
+	return l.Format(t, l.Calendar.Date)
+}
+
+// FormatTime renders the clock time with seconds in 12- or 24-hour style.
+func (l *Locale) FormatTime(t time.Time, hour12 bool) string {
+	if hour12 {
+		return l.Format(t, l.Calendar.Time12)
+	}
+	return l.Format(t, l.Calendar.Time24)
+}
+
+// Format renders t using a CLDR date pattern. Supported fields are y, yy, M,
+// MM, MMM(M), d, dd, E(EEE), h, hh, H, HH, K, m, mm, s, ss and a; text in
+// single quotes is copied literally. Abbreviated names fall back to the full
+// names since only those are embedded.
+func (l *Locale) Format(t time.Time, pattern string) string {
+	var b strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if r == '\'' {
+			j := i + 1
+			for j < len(runes) && runes[j] != '\'' {
+				j++
+			}
+			if j == i+1 {
+				b.WriteRune('\'')
+			} else {
+				b.WriteString(string(runes[i+1 : j]))
+			}
+			i = j + 1
+			continue
+		}
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			b.WriteRune(r)
+			i++
+			continue
+		}
+		n := 1
+		for i+n < len(runes) && runes[i+n] == r {
+			n++
+		}
+		b.WriteString(l.field(t, r, n))
+		i += n
+	}
+	return b.String()
+}
+
+func (l *Locale) field(t time.Time, r rune, n int) string {
+	switch r {
+	case 'y':
+		if n == 2 {
+			return l.number(t.Year()%100, 2)
+		}
+		return l.number(t.Year(), n)
+	case 'M':
+		if n >= 3 {
+			return l.Calendar.Months[t.Month()-1]
+		}
+		return l.number(int(t.Month()), n)
+	case 'd':
+		return l.number(t.Day(), n)
+	case 'E':
+		return l.Calendar.Weekdays[t.Weekday()]
+	case 'h':
+		h := t.Hour() % 12
+		if h == 0 {
+			h = 12
+		}
+		return l.number(h, n)
+	case 'H':
+		return l.number(t.Hour(), n)
+	case 'K':
+		return l.number(t.Hour()%12, n)
+	case 'm':
+		return l.number(t.Minute(), n)
+	case 's':
+		return l.number(t.Second(), n)
+	case 'a':
+		if t.Hour() < 12 {
+			return l.Calendar.AM
+		}
+		return l.Calendar.PM
+	}
+	return strings.Repeat(string(r), n)
+}
+
+func (l *Locale) number(v, width int) string {
+	s := strconv.Itoa(v)
+	if len(s) < width {
+		s = strings.Repeat("0", width-len(s)) + s
+	}
+	return l.Digits(s)
+}
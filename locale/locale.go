@@ -0,0 +1,166 @@
+// Package locale provides CLDR-derived strings and plural rules used to
+// render localized output. The data is embedded so it works offline.
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default is the tag used when a requested locale is not supported.
+const Default = "en"
+
+//go:embed data/*.json
+var dataFS embed.FS
+
+// Locale holds the localized data for a single language.
+type Locale struct {
+	Tag        string   `json:"tag"`
+	Name       string   `json:"name"`
+	PluralRule string   `json:"plural"`
+	Relative   Relative `json:"relative"`
+	Numbering  string   `json:"numbering"`
+	Calendar   Calendar `json:"calendar"`
+}
+
+// Relative holds the relative-time vocabulary. Units maps a unit name to a
+// direction ("future" or "past") and then to a plural category pattern, where
+// {0} is replaced with the count.
+type Relative struct {
+	Now       string                                  `json:"now"`
+	Today     string                                  `json:"today"`
+	Yesterday string                                  `json:"yesterday"`
+	Tomorrow  string                                  `json:"tomorrow"`
+	At        string                                  `json:"at"`
+	Units     map[string]map[string]map[string]string `json:"units"`
+}
+
+// Calendar holds month and weekday names (January and Sunday first) and
+// CLDR date/time patterns. HourCycle is "h12" or "h23" and decides the
+// default clock style when the client does not ask for one.
+type Calendar struct {
+	Months    []string `json:"months"`
+	Weekdays  []string `json:"weekdays"`
+	Date      string   `json:"date"`
+	Time12    string   `json:"time12"`
+	Time24    string   `json:"time24"`
+	Short     string   `json:"short"`
+	ShortHour string   `json:"short_hour"`
+	HourCycle string   `json:"hour_cycle"`
+	AM        string   `json:"am"`
+	PM        string   `json:"pm"`
+}
+
+var locales = map[string]*Locale{}
+
+func init() {
+	entries, err := dataFS.ReadDir("data")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		raw, err := dataFS.ReadFile("data/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		var l Locale
+		if err := json.Unmarshal(raw, &l); err != nil {
+			panic(fmt.Sprintf("locale: invalid data file %s: %v", e.Name(), err))
+		}
+		if _, ok := pluralRules[l.PluralRule]; !ok {
+			panic(fmt.Sprintf("locale: unknown plural rule %q in %s", l.PluralRule, e.Name()))
+		}
+		if _, ok := numberingSystems[l.Numbering]; !ok {
+			panic(fmt.Sprintf("locale: unknown numbering system %q in %s", l.Numbering, e.Name()))
+		}
+		if len(l.Calendar.Months) != 12 || len(l.Calendar.Weekdays) != 7 {
+			panic(fmt.Sprintf("locale: incomplete calendar names in %s", e.Name()))
+		}
+		locales[l.Tag] = &l
+	}
+}
+
+// Get returns the locale for a BCP 47 tag, falling back from a regional tag
+// such as "ms-MY" to its base language. A Unicode numbering extension such as
+// "ar-u-nu-latn" or "hi-IN-u-nu-deva" overrides the default digits.
+func Get(tag string) (*Locale, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	numbering := ""
+	if i := strings.Index(tag, "-u-"); i >= 0 {
+		ext := strings.Split(tag[i+3:], "-")
+		for k := 0; k+1 < len(ext); k++ {
+			if ext[k] == "nu" {
+				numbering = ext[k+1]
+			}
+		}
+		tag = tag[:i]
+	}
+	l, ok := locales[tag]
+	if !ok {
+		if i := strings.Index(tag, "-"); i > 0 {
+			l, ok = locales[tag[:i]]
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	if numbering != "" && numbering != l.Numbering {
+		if _, known := numberingSystems[numbering]; !known {
+			return nil, false
+		}
+		override := *l
+		override.Numbering = numbering
+		l = &override
+	}
+	return l, true
+}
+
+// Tags lists the supported locale tags in sorted order.
+func Tags() []string {
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Plural returns the CLDR plural category for n.
+func (l *Locale) Plural(n int) string {
+	return pluralRules[l.PluralRule](n)
+}
+
+// RelativeUnit renders a count of unit in the given direction, for example
+// "in 3 hours" or "2 days ago".
+func (l *Locale) RelativeUnit(unit string, future bool, n int) string {
+	direction := "past"
+	if future {
+		direction = "future"
+	}
+	forms := l.Relative.Units[unit][direction]
+	pattern, ok := forms[l.Plural(n)]
+	if !ok {
+		pattern = forms["other"]
+	}
+	return strings.ReplaceAll(pattern, "{0}", l.Digits(strconv.Itoa(n)))
+}
+
+// At joins a day word with a clock time, for example "yesterday at 5 PM".
+func (l *Locale) At(day, clock string) string {
+	return strings.NewReplacer("{0}", day, "{1}", clock).Replace(l.Relative.At)
+}
+
+// FormatClock renders t as a short clock time in the locale's style, dropping
+// the minutes on the hour where the locale does.
+func (l *Locale) FormatClock(t time.Time) string {
+	pattern := l.Calendar.Short
+	if t.Minute() == 0 && l.Calendar.ShortHour != "" {
+		pattern = l.Calendar.ShortHour
+	}
+	return l.Format(t, pattern)
+}
@@ -0,0 +1,149 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{"en", "en", true},
+		{"ms-MY", "ms", true},
+		{"zh_CN", "zh", true},
+		{"AR", "ar", true},
+		{"xx", "", false},
+	}
+	for _, tt := range tests {
+		l, ok := Get(tt.tag)
+		if ok != tt.ok {
+			t.Errorf("Get(%q) ok = %v, want %v", tt.tag, ok, tt.ok)
+			continue
+		}
+		if ok && l.Tag != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.tag, l.Tag, tt.want)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		tag  string
+		n    int
+		want string
+	}{
+		{"en", 1, One},
+		{"en", 0, Other},
+		{"de", 2, Other},
+		{"fr", 0, One},
+		{"fr", 1, One},
+		{"fr", 2, Other},
+		{"ja", 1, Other},
+		{"ar", 0, Zero},
+		{"ar", 1, One},
+		{"ar", 2, Two},
+		{"ar", 5, Few},
+		{"ar", 11, Many},
+		{"ar", 100, Other},
+		{"ar", 103, Few},
+	}
+	for _, tt := range tests {
+		l, _ := Get(tt.tag)
+		if got := l.Plural(tt.n); got != tt.want {
+			t.Errorf("%s.Plural(%d) = %s, want %s", tt.tag, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeUnit(t *testing.T) {
+	tests := []struct {
+		tag    string
+		unit   string
+		future bool
+		n      int
+		want   string
+	}{
+		{"en", "hour", true, 3, "in 3 hours"},
+		{"en", "day", false, 1, "1 day ago"},
+		{"ms", "day", false, 2, "2 hari lalu"},
+		{"de", "day", false, 2, "vor 2 Tagen"},
+		{"fr", "hour", true, 1, "dans 1 heure"},
+		{"ja", "minute", false, 5, "5 分前"},
+		{"zh", "hour", true, 3, "3小时后"},
+		{"ar", "hour", true, 2, "خلال ساعتين"},
+	}
+	for _, tt := range tests {
+		l, _ := Get(tt.tag)
+		if got := l.RelativeUnit(tt.unit, tt.future, tt.n); got != tt.want {
+			t.Errorf("%s.RelativeUnit(%s, %v, %d) = %q, want %q", tt.tag, tt.unit, tt.future, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatClock(t *testing.T) {
+	en, _ := Get("en")
+	if got := en.FormatClock(time.Date(2026, 1, 4, 17, 0, 0, 0, time.UTC)); got != "5 PM" {
+		t.Errorf("got %q, want %q", got, "5 PM")
+	}
+	ms, _ := Get("ms")
+	if got := ms.FormatClock(time.Date(2026, 1, 4, 17, 30, 0, 0, time.UTC)); got != "5:30 PTG" {
+		t.Errorf("got %q, want %q", got, "5:30 PTG")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	ts := time.Date(2024, 1, 3, 14, 30, 45, 0, time.UTC)
+	tests := []struct {
+		tag    string
+		date   string
+		clock  string
+		hour12 bool
+	}{
+		{"en", "Wednesday, January 3, 2024", "2:30:45 PM", true},
+		{"de", "Mittwoch, 3. Januar 2024", "14:30:45", false},
+		{"fr", "mercredi 3 janvier 2024", "14:30:45", false},
+		{"ms", "Rabu, 3 Januari 2024", "2:30:45 PTG", true},
+		{"ja", "2024年1月3日水曜日", "午後2:30:45", true},
+		{"ar", "الأربعاء، ٣ يناير ٢٠٢٤", "٢:٣٠:٤٥ م", true},
+		{"ar-u-nu-latn", "الأربعاء، 3 يناير 2024", "2:30:45 م", true},
+		{"hi-IN-u-nu-deva", "बुधवार, ३ जनवरी २०२४", "२:३०:४५ pm", true},
+	}
+	for _, tt := range tests {
+		l, ok := Get(tt.tag)
+		if !ok {
+			t.Fatalf("Get(%q) failed", tt.tag)
+		}
+		if got := l.FormatDate(ts); got != tt.date {
+			t.Errorf("%s date = %q, want %q", tt.tag, got, tt.date)
+		}
+		if got := l.FormatTime(ts, tt.hour12); got != tt.clock {
+			t.Errorf("%s time = %q, want %q", tt.tag, got, tt.clock)
+		}
+	}
+
+	if _, ok := Get("ar-u-nu-klingon"); ok {
+		t.Error("expected unknown numbering system to be rejected")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"de-DE,de;q=0.9,en;q=0.8", "de-DE"},
+		{"sv;q=0.9, ja;q=0.5", "ja"},
+		{"en;q=0.2, fr;q=0.7", "fr"},
+		{"sv, nl", ""},
+		{"*", ""},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
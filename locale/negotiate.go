@@ -0,0 +1,45 @@
+package locale
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Negotiate picks the best supported locale from an Accept-Language header.
+// It returns an empty string when nothing acceptable is supported.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	for _, c := range candidates {
+		if _, ok := Get(c.tag); ok {
+			return c.tag
+		}
+	}
+	return ""
+}
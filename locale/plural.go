@@ -0,0 +1,53 @@
+package locale
+
+// Plural categories as defined by CLDR.
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// pluralRules implements the CLDR cardinal rules for non-negative integers,
+// keyed by the rule name referenced from the data files.
+var pluralRules = map[string]func(n int) string{
+	// Languages without grammatical number, such as Malay, Japanese and Chinese.
+	"other": func(n int) string {
+		return Other
+	},
+	// English, German and most Germanic languages.
+	"one_other": func(n int) string {
+		if n == 1 {
+			return One
+		}
+		return Other
+	},
+	// French treats zero as singular.
+	"french": func(n int) string {
+		if n == 0 || n == 1 {
+			return One
+		}
+		if n != 0 && n%1000000 == 0 {
+			return Many
+		}
+		return Other
+	},
+	"arabic": func(n int) string {
+		switch mod := n % 100; {
+		case n == 0:
+			return Zero
+		case n == 1:
+			return One
+		case n == 2:
+			return Two
+		case mod >= 3 && mod <= 10:
+			return Few
+		case mod >= 11 && mod <= 99:
+			return Many
+		default:
+			return Other
+		}
+	},
+}
@@ -0,0 +1,106 @@
+// Package logging builds the server's structured slog logger.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"gotimedate/tracing"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// ParseLevel maps LOG_LEVEL values (debug, info, warn, error) to slog levels.
+// Unknown values mean info.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// New returns a logger writing records at level and above to w, as JSON lines
+// when format is "json" and as key=value text otherwise. Records logged with a
+// context that carries a span get its trace_id.
+func New(w io.Writer, format, level string) *slog.Logger {
+	return newLogger(w, format, ParseLevel(level))
+}
+
+func newLogger(w io.Writer, format string, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	if strings.ToLower(format) == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(traceHandler{h})
+}
+
+// level is the level of the default logger, changed by SetLevel.
+var level slog.LevelVar
+
+// Setup makes a logger like New(w, format, lvl) the default logger. Fiber's
+// own logger, which the framework still uses internally, gets the same output
+// and level.
+func Setup(w io.Writer, format, lvl string) *slog.Logger {
+	logger := newLogger(w, format, &level)
+	SetLevel(lvl)
+	slog.SetDefault(logger)
+	fiberlog.SetOutput(w)
+	return logger
+}
+
+// SetLevel changes the level of the default logger and Fiber's logger.
+func SetLevel(lvl string) {
+	level.Set(ParseLevel(lvl))
+	fiberlog.SetLevel(fiberLevel(level.Level()))
+}
+
+func fiberLevel(l slog.Level) fiberlog.Level {
+	switch {
+	case l <= slog.LevelDebug:
+		return fiberlog.LevelDebug
+	case l <= slog.LevelInfo:
+		return fiberlog.LevelInfo
+	case l <= slog.LevelWarn:
+		return fiberlog.LevelWarn
+	default:
+		return fiberlog.LevelError
+	}
+}
+
+// Fatal logs msg at error level and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// traceHandler adds the trace ID of the record's context.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := tracing.TraceID(ctx); id != "" {
+		r.AddAttrs(slog.String("trace_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"info":    slog.LevelInfo,
+		"WARN":    slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+		"verbose": slog.LevelInfo,
+		"":        slog.LevelInfo,
+	}
+	for in, want := range tests {
+		if got := ParseLevel(in); got != want {
+			t.Errorf("ParseLevel(%q): expected %v, got %v", in, want, got)
+		}
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "json", "warn")
+	logger.Info("dropped")
+	logger.Warn("kept", "route", "/api/v1/time")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected only the warn record, got %q", buf.String())
+	}
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("expected a JSON record, got %q", lines[0])
+	}
+	if rec["msg"] != "kept" || rec["level"] != "WARN" || rec["route"] != "/api/v1/time" {
+		t.Errorf("unexpected record %v", rec)
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, "text", "debug").Debug("hello", "status", 200)
+	if out := buf.String(); !strings.Contains(out, "level=DEBUG") || !strings.Contains(out, "status=200") {
+		t.Errorf("expected a key=value debug record, got %q", out)
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	var buf bytes.Buffer
+	logger := New(&buf, "json", "info").With("component", "test")
+	logger.InfoContext(ctx, "traced")
+	logger.Info("untraced")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.Contains(lines[0], `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`) {
+		t.Errorf("expected trace_id in %s", lines[0])
+	}
+	if strings.Contains(lines[1], "trace_id") {
+		t.Errorf("expected no trace_id in %s", lines[1])
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var buf bytes.Buffer
+	Setup(&buf, "text", "error")
+	slog.Info("dropped")
+	SetLevel("debug")
+	slog.Debug("kept")
+	SetLevel("info")
+
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, "kept") {
+		t.Errorf("expected only the record logged after SetLevel, got %q", out)
+	}
+}
@@ -0,0 +1,261 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat stamps rotated files. It sorts chronologically and avoids
+// characters that are awkward in file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateOptions controls when a RotatingFile rotates and what it keeps.
+type RotateOptions struct {
+	// MaxSize rotates the file before a write would take it past this many
+	// bytes (0 = no size limit).
+	MaxSize int64
+	// MaxAge rotates the file once it has been open this long (0 = never).
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept (0 = keep all).
+	MaxBackups int
+	// Compress gzips rotated files.
+	Compress bool
+}
+
+// RotatingFile is a log file that rotates itself by size and age. Rotated
+// files are renamed to path.<timestamp>, optionally gzipped, and pruned to
+// the newest MaxBackups. Reopen supports external tools such as logrotate that
+// move the file away and signal the server.
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+	now  func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	millMu  sync.Mutex
+	milling sync.WaitGroup
+}
+
+// OpenRotatingFile opens path for appending, creating it if needed.
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, opts: opts, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+// Write appends p, rotating first when p would exceed MaxSize or the file
+// is older than MaxAge.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) due(next int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size+next > f.opts.MaxSize {
+		return true
+	}
+	return f.opts.MaxAge > 0 && f.now().Sub(f.opened) >= f.opts.MaxAge
+}
+
+// Rotate moves the current file aside and starts a new one.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+func (f *RotatingFile) rotate() error {
+	// Another process writing the same path (a Prefork sibling) may already
+	// have rotated it; then the path is a fresh file to reopen, not rename.
+	current, statErr := f.file.Stat()
+	onDisk, err := os.Stat(f.path)
+	renamed := statErr == nil && err == nil && os.SameFile(current, onDisk)
+	if renamed {
+		backup := f.path + "." + f.now().Format(backupTimeFormat)
+		if err := os.Rename(f.path, backup); err != nil {
+			return fmt.Errorf("rotating log file: %w", err)
+		}
+	}
+	if err := f.reopen(); err != nil {
+		return err
+	}
+	// Only the process that renamed the file tidies the backups.
+	if renamed {
+		f.mill()
+	}
+	return nil
+}
+
+// Reopen closes the file and opens path again, picking up a file that was
+// moved away by an external rotation.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.reopen()
+}
+
+func (f *RotatingFile) reopen() error {
+	old := f.file
+	if err := f.open(); err != nil {
+		return fmt.Errorf("reopening log file: %w", err)
+	}
+	return old.Close()
+}
+
+// Close waits for pending compression and closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.milling.Wait()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// mill compresses and prunes backups in the background so a rotation does
+// not stall the writer. Runs are serialized.
+func (f *RotatingFile) mill() {
+	if !f.opts.Compress && f.opts.MaxBackups <= 0 {
+		return
+	}
+	f.milling.Add(1)
+	go func() {
+		defer f.milling.Done()
+		f.millMu.Lock()
+		defer f.millMu.Unlock()
+		// Errors here cannot be logged without writing to this file again;
+		// the next rotation retries.
+		_ = f.compressAndPrune()
+	}()
+}
+
+func (f *RotatingFile) compressAndPrune() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+	if f.opts.Compress {
+		for i, name := range backups {
+			if strings.HasSuffix(name, ".gz") {
+				continue
+			}
+			if err := gzipFile(name); err != nil {
+				return err
+			}
+			backups[i] = name + ".gz"
+		}
+	}
+	if f.opts.MaxBackups > 0 && len(backups) > f.opts.MaxBackups {
+		for _, name := range backups[:len(backups)-f.opts.MaxBackups] {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// backups lists rotated files, oldest first.
+func (f *RotatingFile) backups() ([]string, error) {
+	dir := filepath.Dir(f.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(f.path) + "."
+	var backups []string
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ".gz")); err == nil {
+			backups = append(backups, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i], ".gz") < strings.TrimSuffix(backups[j], ".gz")
+	})
+	return backups, nil
+}
+
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
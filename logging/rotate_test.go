@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock lets tests advance time between writes.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func openTestFile(t *testing.T, opts RotateOptions) (*RotatingFile, *fakeClock, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "server.log")
+	f, err := OpenRotatingFile(path, opts)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	clock := &fakeClock{t: time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)}
+	f.now = clock.now
+	f.opened = clock.t
+	t.Cleanup(func() { f.Close() })
+	return f, clock, path
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", name, err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("invalid gzip %s: %v", name, err)
+		}
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func write(t *testing.T, f *RotatingFile, s string) {
+	t.Helper()
+	if _, err := f.Write([]byte(s)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+}
+
+func TestRotateBySizeWithRetention(t *testing.T) {
+	f, clock, path := openTestFile(t, RotateOptions{MaxSize: 10, MaxBackups: 2, Compress: true})
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		write(t, f, line)
+		clock.t = clock.t.Add(time.Second)
+	}
+	f.Close()
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatalf("failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups kept, got %v", backups)
+	}
+	for _, name := range backups {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Errorf("expected %s to be compressed", name)
+		}
+	}
+	if got := readFile(t, backups[0]); got != "second\n" {
+		t.Errorf("expected oldest kept backup to hold %q, got %q", "second\n", got)
+	}
+	if got := readFile(t, backups[1]); got != "third\n" {
+		t.Errorf("expected newest backup to hold %q, got %q", "third\n", got)
+	}
+	if got := readFile(t, path); got != "fourth\n" {
+		t.Errorf("expected current file to hold %q, got %q", "fourth\n", got)
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	f, clock, path := openTestFile(t, RotateOptions{MaxAge: time.Hour})
+
+	write(t, f, "old\n")
+	clock.t = clock.t.Add(30 * time.Minute)
+	write(t, f, "still old\n")
+	clock.t = clock.t.Add(30 * time.Minute)
+	write(t, f, "new\n")
+
+	backup := path + "." + clock.t.Format(backupTimeFormat)
+	if got := readFile(t, backup); got != "old\nstill old\n" {
+		t.Errorf("expected the first hour in %s, got %q", backup, got)
+	}
+	if got := readFile(t, path); got != "new\n" {
+		t.Errorf("expected current file to hold %q, got %q", "new\n", got)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	f, _, path := openTestFile(t, RotateOptions{})
+
+	write(t, f, "before\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	write(t, f, "moved\n")
+	if err := f.Reopen(); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	write(t, f, "after\n")
+
+	if got := readFile(t, path+".1"); got != "before\nmoved\n" {
+		t.Errorf("expected writes before reopen in the moved file, got %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("expected writes after reopen in a new file, got %q", got)
+	}
+}
+
+func TestRotateAfterSibling(t *testing.T) {
+	f, _, path := openTestFile(t, RotateOptions{MaxSize: 10})
+
+	write(t, f, "mine\n")
+	// A sibling process rotates first and starts a new file.
+	if err := os.Rename(path, path+".sibling"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("theirs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	write(t, f, "rotated\n")
+
+	if got := readFile(t, path); got != "theirs\nrotated\n" {
+		t.Errorf("expected to append to the sibling's file, got %q", got)
+	}
+	if backups, _ := f.backups(); len(backups) != 0 {
+		t.Errorf("expected no second backup, got %v", backups)
+	}
+}
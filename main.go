@@ -1,12 +1,24 @@
 package main
 
 import (
+	"context"
 	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
 	"gotimedate/config"
+	"gotimedate/logging"
+	"gotimedate/metrics"
 	"gotimedate/router"
+	"gotimedate/tracing"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2"
 )
 
 //go:embed static/index.html
@@ -17,25 +29,178 @@ var defaultHTML []byte
 // @description API for time operations and WebSockets
 // @host localhost:8080
 // @BasePath /api/v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT or API key as "Bearer <token>", required when AUTH_ENABLED is set
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key, required when AUTH_ENABLED is set
 func main() {
-	cfg := config.LoadConfig(defaultHTML)
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logging.Fatal("Error loading config", "error", err)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.WriteYAML(os.Stdout); err != nil {
+			logging.Fatal("Error printing config", "error", err)
+		}
+		return
+	}
+	if err := cfg.WriteStaticFiles(defaultHTML); err != nil {
+		logging.Fatal("Error writing static files", "error", err)
+	}
 
-	logFile, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// A Prefork child logs to stdout, which the master copies to its own log
+	// output, so that only one process writes and rotates the log file.
+	logCfg := cfg
+	if fiber.IsChild() {
+		logCfg = &config.Config{LogOutput: "stdout"}
+	}
+	logOutput, logFile, err := openLogOutput(logCfg, os.Stdout)
 	if err != nil {
-		log.Fatalf("Error opening log file: %v", err)
+		logging.Fatal("Error opening log file", "error", err)
+	}
+	if logFile != nil {
+		defer logFile.Close()
+		go reopenOnHangup(logFile)
 	}
-	defer logFile.Close()
 
-	// Configure Fiber logger to output to both file and stdout
-	log.SetOutput(logFile)
+	logging.Setup(logOutput, cfg.LogFormat, cfg.LogLevel)
 
-	app := router.SetupRouter(cfg)
+	// In Prefork mode this process only supervises the children that serve.
+	if cfg.Prefork && !fiber.IsChild() {
+		if err := runPreforkMaster(cfg, logOutput); err != nil {
+			logging.Fatal("Prefork child failed", "error", err)
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingSampleRatio)
+	if err != nil {
+		logging.Fatal("Error setting up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// A Prefork child serves its metrics to the master and its siblings.
+	if dir := os.Getenv(metrics.DirEnv); dir != "" {
+		ln, err := metrics.ServeWorker(dir)
+		if err != nil {
+			logging.Fatal("Error serving worker metrics", "error", err)
+		}
+		defer ln.Close()
+	}
+
+	srv := router.NewServer(cfg)
+
+	reloader := config.NewReloader(cfg, func() (*config.Config, error) {
+		return config.Load(os.Args[1:])
+	})
+	reloader.OnReload(srv.Reload)
+	reloader.OnReload(func(c *config.Config) { logging.SetLevel(c.LogLevel) })
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go reloader.Watch(watchCtx, configWatchInterval)
+	go reloadOnHangup(reloader)
+
+	// Prefork children share the public port but cannot share this one, so
+	// the master serves /metrics on it, and the admin endpoints are served on
+	// the public port instead.
+	var admin *fiber.App
+	if cfg.MetricsPort != "" && !cfg.Prefork {
+		admin = router.SetupAdmin(cfg)
+		srv.MountAdmin(admin, reloader)
+		adminAddr := cfg.Host + ":" + cfg.MetricsPort
+		slog.Info("Admin listener starting", "addr", adminAddr)
+		go func() {
+			if err := admin.Listen(adminAddr); err != nil {
+				slog.Error("Admin listener failed", "error", err)
+			}
+		}()
+	} else {
+		srv.MountAdmin(srv.App, reloader)
+	}
 
 	addr := cfg.Host + ":" + cfg.Port
-	log.Infof("Server starting on %s", addr)
-	log.Infof("Logging to: %s", cfg.LogFile)
+	slog.Info("Server starting", "addr", addr, "pid", os.Getpid(), "log_output", cfg.LogOutput, "log_file", cfg.LogFile)
+
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- srv.App.Listen(addr) }()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-listenErr:
+		logging.Fatal("Server failed", "error", err)
+	case sig := <-stop:
+		slog.Info("Shutting down", "signal", sig.String(), "timeout", shutdownTimeout(cfg))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout(cfg))
+	defer cancel()
+	if admin != nil {
+		admin.ShutdownWithContext(ctx)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("Shutdown incomplete", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// configWatchInterval is how often the config file is checked for changes.
+const configWatchInterval = 2 * time.Second
+
+func shutdownTimeout(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.ShutdownTimeout) * time.Second
+}
+
+// openLogOutput returns the writer selected by LOG_OUTPUT and, unless logs go
+// to stdout only, the rotating log file behind it.
+func openLogOutput(cfg *config.Config, stdout io.Writer) (io.Writer, *logging.RotatingFile, error) {
+	if cfg.LogOutput == "stdout" {
+		return stdout, nil, nil
+	}
+	if cfg.LogOutput != "file" && cfg.LogOutput != "both" {
+		return nil, nil, fmt.Errorf("unknown LOG_OUTPUT: %s", cfg.LogOutput)
+	}
+	logFile, err := logging.OpenRotatingFile(cfg.LogFile, logging.RotateOptions{
+		MaxSize:    int64(cfg.LogMaxSize) << 20,
+		MaxAge:     cfg.LogMaxAge,
+		MaxBackups: cfg.LogMaxBackups,
+		Compress:   cfg.LogCompress,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.LogOutput == "file" {
+		return logFile, logFile, nil
+	}
+	return io.MultiWriter(stdout, logFile), logFile, nil
+}
+
+// reopenOnHangup reopens the log file on SIGHUP, after an external tool such
+// as logrotate has moved it away.
+func reopenOnHangup(logFile *logging.RotatingFile) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := logFile.Reopen(); err != nil {
+			slog.Error("Reopening log file failed", "error", err)
+			continue
+		}
+		slog.Info("Log file reopened")
+	}
+}
 
-	if err := app.Listen(addr); err != nil {
-		log.Fatal(err)
+// reloadOnHangup reloads the config on SIGHUP.
+func reloadOnHangup(reloader *config.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		reloader.Reload("sighup")
 	}
 }
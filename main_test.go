@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"gotimedate/config"
 	"gotimedate/router"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -57,3 +60,63 @@ func TestMainRouter(t *testing.T) {
 		}
 	})
 }
+
+func TestOpenLogOutput(t *testing.T) {
+	for _, output := range []string{"stdout", "file", "both", "syslog"} {
+		t.Run(output, func(t *testing.T) {
+			cfg := &config.Config{LogOutput: output, LogFile: filepath.Join(t.TempDir(), "server.log")}
+			var stdout bytes.Buffer
+			w, logFile, err := openLogOutput(cfg, &stdout)
+			if output == "syslog" {
+				if err == nil {
+					t.Error("expected an error for an unknown output")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if logFile != nil {
+				defer logFile.Close()
+			}
+			if (logFile == nil) != (output == "stdout") {
+				t.Errorf("expected a log file only when writing to one, got %v", logFile)
+			}
+			if output == "file" && w != io.Writer(logFile) {
+				t.Error("expected file output to write only to the log file")
+			}
+			if _, err := w.Write([]byte("line\n")); err != nil {
+				t.Errorf("write failed: %v", err)
+			}
+			wantStdout := ""
+			if output != "file" {
+				wantStdout = "line\n"
+			}
+			if stdout.String() != wantStdout {
+				t.Errorf("expected %q on stdout, got %q", wantStdout, stdout.String())
+			}
+			if logFile != nil {
+				if data, _ := os.ReadFile(cfg.LogFile); string(data) != "line\n" {
+					t.Errorf("expected the line in the log file, got %q", data)
+				}
+			}
+		})
+	}
+}
+
+// writes records each Write separately.
+type writes []string
+
+func (w *writes) Write(p []byte) (int, error) {
+	*w = append(*w, string(p))
+	return len(p), nil
+}
+
+func TestCopyLines(t *testing.T) {
+	var w writes
+	copyLines(&w, strings.NewReader("first\nsecond\nunterminated"))
+	want := []string{"first\n", "second\n", "unterminated"}
+	if strings.Join(w, "|") != strings.Join(want, "|") {
+		t.Errorf("expected one write per line %q, got %q", want, w)
+	}
+}
@@ -0,0 +1,146 @@
+// Package metrics exposes the server's Prometheus metrics.
+package metrics
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gotimedate/handlers"
+	"gotimedate/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gotimedate"
+
+// Registry holds every metric served on /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"method", "route", "status"})
+
+	errorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "errors_total",
+		Help:      "Errors returned by handlers, by type.",
+	}, []string{"type"})
+)
+
+// webSocket is the handler whose counters are reported, if any.
+var webSocket atomic.Pointer[handlers.WSHandler]
+
+func init() {
+	Registry.MustRegister(
+		httpRequests,
+		httpDuration,
+		errorsTotal,
+		collector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// ObserveRequest records one HTTP request.
+func ObserveRequest(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// ObserveError counts an error response by its HTTP status, such as
+// "not_found" or "internal_server_error".
+func ObserveError(status int) {
+	errorsTotal.WithLabelValues(ErrorType(status)).Inc()
+}
+
+// ErrorType names a status code in snake case.
+func ErrorType(status int) string {
+	text := utils.StatusMessage(status)
+	if text == "" {
+		return "status_" + strconv.Itoa(status)
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// TrackWebSocket reports h's hub and connection counters.
+func TrackWebSocket(h *handlers.WSHandler) {
+	webSocket.Store(h)
+}
+
+// Handler serves the registry in the Prometheus exposition format. In a
+// Prefork child it serves every child's registry, as WorkersHandler does.
+func Handler() fiber.Handler {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return WorkersHandler(dir)
+	}
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
+
+var (
+	wsConnectionsDesc = desc("ws_connections", "Open WebSocket connections.")
+	hubClientsDesc    = desc("hub_clients", "WebSocket and SSE clients attached to the hub.")
+	hubStreamsDesc    = desc("hub_streams", "Distinct streams rendered by the hub.")
+	wsSentDesc        = desc("ws_messages_sent_total", "Messages queued to WebSocket and SSE clients.")
+	wsEvictedDesc     = desc("ws_evicted_total", "Clients dropped as slow consumers.")
+	wsRejectedDesc    = desc("ws_rejected_total", "WebSocket upgrades refused by a connection cap.", "reason")
+	wsClosedDesc      = desc("ws_closed_total", "WebSocket connections closed by a limit.", "reason")
+	tzHitsDesc        = desc("tz_cache_hits_total", "Timezone lookups served from the cache.")
+	tzMissesDesc      = desc("tz_cache_misses_total", "Timezone lookups that loaded tzdata.")
+)
+
+func desc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(namespace+"_"+name, help, labels, nil)
+}
+
+// collector reads counters the handlers and services already keep, so they
+// are not counted twice.
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		wsConnectionsDesc, hubClientsDesc, hubStreamsDesc, wsSentDesc, wsEvictedDesc,
+		wsRejectedDesc, wsClosedDesc, tzHitsDesc, tzMissesDesc,
+	} {
+		ch <- d
+	}
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	hits, misses := services.LocationCacheStats()
+	ch <- prometheus.MustNewConstMetric(tzHitsDesc, prometheus.CounterValue, float64(hits))
+	ch <- prometheus.MustNewConstMetric(tzMissesDesc, prometheus.CounterValue, float64(misses))
+
+	h := webSocket.Load()
+	if h == nil {
+		return
+	}
+	s := h.CurrentStats()
+	ch <- prometheus.MustNewConstMetric(wsConnectionsDesc, prometheus.GaugeValue, float64(s.Connections.Active))
+	ch <- prometheus.MustNewConstMetric(hubClientsDesc, prometheus.GaugeValue, float64(s.Hub.Clients))
+	ch <- prometheus.MustNewConstMetric(hubStreamsDesc, prometheus.GaugeValue, float64(s.Hub.Streams))
+	ch <- prometheus.MustNewConstMetric(wsSentDesc, prometheus.CounterValue, float64(s.Hub.Sent))
+	ch <- prometheus.MustNewConstMetric(wsEvictedDesc, prometheus.CounterValue, float64(s.Hub.Evicted))
+	ch <- prometheus.MustNewConstMetric(wsRejectedDesc, prometheus.CounterValue, float64(s.Connections.RejectedTotal), "global")
+	ch <- prometheus.MustNewConstMetric(wsRejectedDesc, prometheus.CounterValue, float64(s.Connections.RejectedPerIP), "per_ip")
+	ch <- prometheus.MustNewConstMetric(wsClosedDesc, prometheus.CounterValue, float64(s.Connections.RateLimited), "rate_limited")
+	ch <- prometheus.MustNewConstMetric(wsClosedDesc, prometheus.CounterValue, float64(s.Connections.IdleClosed), "idle")
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"gotimedate/config"
+	"gotimedate/handlers"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestErrorType(t *testing.T) {
+	tests := map[int]string{
+		fiber.StatusBadRequest:          "bad_request",
+		fiber.StatusNotFound:            "not_found",
+		fiber.StatusTooManyRequests:     "too_many_requests",
+		fiber.StatusInternalServerError: "internal_server_error",
+		599:                             "status_599",
+	}
+	for status, want := range tests {
+		if got := ErrorType(status); got != want {
+			t.Errorf("ErrorType(%d): expected %s, got %s", status, want, got)
+		}
+	}
+}
+
+func TestObserveRequest(t *testing.T) {
+	// The collectors are global, so compare against their values before the
+	// test to allow -count above one.
+	requests := httpRequests.WithLabelValues("GET", "/api/v1/time", "200")
+	notFound := errorsTotal.WithLabelValues("not_found")
+	before, beforeErrors := testutil.ToFloat64(requests), testutil.ToFloat64(notFound)
+
+	ObserveRequest("GET", "/api/v1/time", 200, 3*time.Millisecond)
+	ObserveRequest("GET", "/api/v1/time", 200, 5*time.Millisecond)
+	if got := testutil.ToFloat64(requests) - before; got != 2 {
+		t.Errorf("expected 2 requests, got %v", got)
+	}
+	ObserveError(fiber.StatusNotFound)
+	if got := testutil.ToFloat64(notFound) - beforeErrors; got != 1 {
+		t.Errorf("expected 1 not_found error, got %v", got)
+	}
+}
+
+func TestHandlerExposition(t *testing.T) {
+	ws := handlers.NewWSHandler(&config.Config{WSMaxConnections: 10})
+	defer ws.Stop()
+	TrackWebSocket(ws)
+	app := fiber.New()
+	app.Get("/metrics", Handler())
+
+	resp, err := app.Test(httptestRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("expected text exposition format, got %s", resp.Header.Get("Content-Type"))
+	}
+	for _, name := range []string{
+		"gotimedate_ws_connections 0",
+		"gotimedate_hub_clients 0",
+		"gotimedate_ws_messages_sent_total",
+		`gotimedate_ws_rejected_total{reason="per_ip"}`,
+		"gotimedate_tz_cache_hits_total",
+		"gotimedate_tz_cache_misses_total",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("expected %q in exposition", name)
+		}
+	}
+}
+
+func httptestRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestWorkersHandler(t *testing.T) {
+	dir := t.TempDir()
+	ln, err := ServeWorker(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// A socket no child answers on, as a crashed child leaves behind, is left
+	// out of the scrape.
+	if err := os.WriteFile(filepath.Join(dir, "1.sock"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/metrics", WorkersHandler(dir))
+	resp, err := app.Test(httptestRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	want := `gotimedate_tz_cache_hits_total{worker="` + strconv.Itoa(os.Getpid()) + `"}`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("expected %q in exposition, got %s", want, body)
+	}
+	if strings.Contains(string(body), `worker="1"`) {
+		t.Error("expected the unanswered socket to be skipped")
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// DirEnv names the directory where Prefork children serve their registries,
+// one Unix socket per child named after its PID.
+const DirEnv = "GOTIMEDATE_METRICS_DIR"
+
+// workerTimeout bounds a scrape of one child.
+const workerTimeout = 2 * time.Second
+
+// ServeWorker serves the registry on a socket in dir for WorkersHandler to
+// scrape. Closing the listener removes the socket.
+func ServeWorker(dir string) (net.Listener, error) {
+	ln, err := net.Listen("unix", filepath.Join(dir, strconv.Itoa(os.Getpid())+".sock"))
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(ln, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	return ln, nil
+}
+
+// WorkersHandler serves the registries of every child serving in dir as one,
+// each series labelled with the PID of its child as "worker". A child that
+// cannot be scraped is logged and left out.
+func WorkersHandler(dir string) fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(workers(dir), promhttp.HandlerOpts{
+		ErrorLog:      slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		ErrorHandling: promhttp.ContinueOnError,
+	}))
+}
+
+// workers gathers every child's registry through its socket in dir.
+type workers string
+
+func (dir workers) Gather() ([]*dto.MetricFamily, error) {
+	sockets, err := filepath.Glob(filepath.Join(string(dir), "*.sock"))
+	if err != nil {
+		return nil, err
+	}
+	gatherers := make(prometheus.Gatherers, len(sockets))
+	for i, path := range sockets {
+		gatherers[i] = worker(path)
+	}
+	return gatherers.Gather()
+}
+
+// worker gathers one child's registry from the socket at its path.
+type worker string
+
+func (path worker) Gather() ([]*dto.MetricFamily, error) {
+	client := &http.Client{
+		Timeout: workerTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", string(path))
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get("http://worker/metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scraping %s: %s", path, resp.Status)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("scraping %s: %w", path, err)
+	}
+
+	name, value := "worker", strings.TrimSuffix(filepath.Base(string(path)), ".sock")
+	out := make([]*dto.MetricFamily, 0, len(families))
+	for _, f := range families {
+		for _, m := range f.Metric {
+			m.Label = append(m.Label, &dto.LabelPair{Name: &name, Value: &value})
+			sort.Slice(m.Label, func(i, j int) bool { return m.Label[i].GetName() < m.Label[j].GetName() })
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
@@ -1,10 +1,22 @@
 package middleware
 
 import (
+	"log/slog"
+	"math/rand/v2"
+	"strings"
 	"time"
 
+	"gotimedate/metrics"
+	"gotimedate/tracing"
+
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func ErrorHandler(c *fiber.Ctx, err error) error {
@@ -13,31 +25,168 @@ func ErrorHandler(c *fiber.Ctx, err error) error {
 	if e, ok := err.(*fiber.Error); ok {
 		code = e.Code
 	}
+	metrics.ObserveError(code)
 
-	log.Errorf("Error: %s | Path: %s | Method: %s | IP: %s",
-		err.Error(),
-		c.Path(),
-		c.Method(),
-		c.IP(),
-	)
-
-	return c.Status(code).JSON(fiber.Map{
+	traceID := tracing.TraceID(c.UserContext())
+	body := fiber.Map{
 		"error":   true,
 		"message": err.Error(),
 		"code":    code,
 		"path":    c.Path(),
 		"method":  c.Method(),
-	})
+	}
+	if traceID != "" {
+		body["trace_id"] = traceID
+	}
+	return c.Status(code).JSON(body)
 }
 
-func Logger(logLevel string) fiber.Handler {
+// Logger writes one structured "request" record per request through the
+// default slog logger: info for successes, warn for 4xx and error for 5xx. It
+// must be the outermost middleware. It runs the error handler itself so the
+// record has the final status and size. Successful requests on a route listed
+// in sampling are logged at that rate (0 drops them); failures are always
+// logged.
+func Logger(sampling map[string]float64) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		if logLevel != "info" && logLevel != "debug" {
-			return c.Next()
+		start := time.Now()
+		chainErr := c.Next()
+		status, route := outcome(c, chainErr)
+		if chainErr != nil {
+			if err := c.App().ErrorHandler(c, chainErr); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+			status = c.Response().StatusCode()
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		if rate, ok := sampling[route]; ok && level == slog.LevelInfo && rand.Float64() >= rate {
+			return nil
+		}
+		ctx := c.UserContext()
+		if !slog.Default().Enabled(ctx, level) {
+			return nil
+		}
+
+		requestID, _ := c.Locals("requestID").(string)
+		attrs := []slog.Attr{
+			slog.String("request_id", requestID),
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", c.IP()),
+			slog.String("user_agent", c.Get(fiber.HeaderUserAgent)),
 		}
+		// Reading a streamed body would drain it.
+		if !c.Response().IsBodyStream() {
+			attrs = append(attrs, slog.Int("bytes", len(c.Response().Body())))
+		}
+		if subject, ok := c.Locals("auth_subject").(string); ok && subject != "" {
+			attrs = append(attrs, slog.String("auth_subject", subject))
+		}
+		if tz, ok := c.Locals("timezone").(string); ok && tz != "" {
+			attrs = append(attrs, slog.String("timezone", tz))
+		}
+		if chainErr != nil {
+			attrs = append(attrs, slog.String("error", chainErr.Error()))
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+		return nil
+	}
+}
+
+// outcome returns the status and route pattern of a finished request. When
+// a handler returned err the error handler has not written the response yet,
+// so the status comes from the error. Requests that matched no route get the
+// route "unmatched", since the router's own 404 leaves the last middleware as
+// the route.
+func outcome(c *fiber.Ctx, err error) (int, string) {
+	status := c.Response().StatusCode()
+	route := c.Route().Path
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+			if e.Code == fiber.StatusNotFound && strings.HasPrefix(e.Message, "Cannot "+c.Method()+" ") {
+				route = "unmatched"
+			}
+		}
+	}
+	return status, route
+}
+
+// Metrics records the count and latency of each request by method, route
+// pattern and status. Requests that match no route are labeled "unmatched" so
+// scanners cannot create unbounded label values.
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
 		start := time.Now()
 		err := c.Next()
-		log.Infof("%s %s %v", c.Method(), c.Path(), time.Since(start))
+		status, route := outcome(c, err)
+		metrics.ObserveRequest(c.Method(), route, status, time.Since(start))
+		return err
+	}
+}
+
+// headerCarrier adapts request headers for trace-context propagation.
+type headerCarrier struct {
+	h *fasthttp.RequestHeader
+}
+
+func (hc headerCarrier) Get(key string) string {
+	return string(hc.h.Peek(key))
+}
+
+func (hc headerCarrier) Set(key, value string) {
+	hc.h.Set(key, value)
+}
+
+func (hc headerCarrier) Keys() []string {
+	var keys []string
+	hc.h.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
+
+// Tracing starts a server span for each request, continuing the trace of an
+// incoming W3C traceparent header, and makes it the request's user context so
+// handlers can start child spans. Spans are named after the route pattern.
+func Tracing() fiber.Handler {
+	tracer := tracing.Tracer("gotimedate/middleware")
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{&c.Request().Header})
+		ctx, span := tracer.Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", c.Path()),
+			))
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		status, route := outcome(c, err)
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= fiber.StatusInternalServerError {
+			desc := utils.StatusMessage(status)
+			if err != nil {
+				desc = err.Error()
+			}
+			span.SetStatus(codes.Error, desc)
+		}
 		return err
 	}
 }
@@ -51,9 +200,27 @@ func SecurityHeaders() fiber.Handler {
 	}
 }
 
+// LocalOnly answers 403 to requests that do not come from a loopback
+// address. It checks the peer address, never forwarding headers.
+func LocalOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !c.IsFromLocal() {
+			return fiber.NewError(fiber.StatusForbidden, "only available from localhost")
+		}
+		return c.Next()
+	}
+}
+
+// Core assigns each request an ID, taken from X-Request-ID when the client
+// sent one, and echoes it in the response.
 func Core(logLevel string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		c.Locals("requestID", c.Get("X-Request-ID"))
+		id := c.Get(fiber.HeaderXRequestID)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		c.Locals("requestID", id)
+		c.Set(fiber.HeaderXRequestID, id)
 		return c.Next()
 	}
 }
@@ -1,11 +1,25 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"gotimedate/logging"
+	"gotimedate/metrics"
+	"gotimedate/tracing"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestSecurityHeadersMiddleware(t *testing.T) {
@@ -38,3 +52,317 @@ func TestSecurityHeadersMiddleware(t *testing.T) {
 		t.Errorf("expected body OK, got %s", string(body))
 	}
 }
+
+func TestMetricsMiddleware(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(Metrics())
+	app.Get("/items/:id", func(c *fiber.Ctx) error {
+		if c.Params("id") == "missing" {
+			return fiber.NewError(fiber.StatusNotFound, "no such item")
+		}
+		return c.SendString("OK")
+	})
+	app.Get("/metrics", metrics.Handler())
+
+	// The collectors are global, so compare against a scrape before the test
+	// to allow -count above one.
+	series := []string{
+		`gotimedate_http_requests_total{method="GET",route="/items/:id",status="200"}`,
+		`gotimedate_http_requests_total{method="GET",route="/items/:id",status="404"}`,
+		`gotimedate_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`gotimedate_http_request_duration_seconds_count{method="GET",route="/items/:id",status="200"}`,
+		`gotimedate_errors_total{type="not_found"}`,
+	}
+	want := []float64{2, 1, 1, 2, 2}
+	before := scrape(t, app)
+
+	for _, path := range []string{"/items/1", "/items/2", "/items/missing", "/nope"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		if _, err := app.Test(req); err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+	}
+
+	after := scrape(t, app)
+	for i, s := range series {
+		if got := after[s] - before[s]; got != want[i] {
+			t.Errorf("expected %s to grow by %v, got %v", s, want[i], got)
+		}
+	}
+}
+
+// scrape reads /metrics into values by series.
+func scrape(t *testing.T, app *fiber.App) map[string]float64 {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to scrape: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	values := make(map[string]float64)
+	for _, line := range strings.Split(string(body), "\n") {
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if v, err := strconv.ParseFloat(line[i+1:], 64); err == nil {
+			values[line[:i]] = v
+		}
+	}
+	return values
+}
+
+// useMemoryTracer installs an in-memory trace pipeline for the test.
+func useMemoryTracer(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(exp, 1, true)
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return exp
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	exp := useMemoryTracer(t)
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(Tracing())
+	app.Get("/items/:id", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusNotFound, "no such item")
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	req, _ := http.NewRequest("GET", "/items/7", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	var body struct {
+		TraceID string `json:"trace_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid error body: %v", err)
+	}
+	if body.TraceID != traceID {
+		t.Errorf("expected trace_id %s in the error response, got %q", traceID, body.TraceID)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /items/:id" {
+		t.Errorf("expected span name GET /items/:id, got %s", span.Name)
+	}
+	if got := span.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("expected trace %s, got %s", traceID, got)
+	}
+	if got := span.Parent.SpanID().String(); got != parentID {
+		t.Errorf("expected parent %s, got %s", parentID, got)
+	}
+	var status int64
+	for _, kv := range span.Attributes {
+		if kv.Key == "http.response.status_code" {
+			status = kv.Value.AsInt64()
+		}
+	}
+	if status != fiber.StatusNotFound {
+		t.Errorf("expected status attribute 404, got %d", status)
+	}
+}
+
+func TestTracingNewTrace(t *testing.T) {
+	exp := useMemoryTracer(t)
+	app := fiber.New()
+	app.Use(Tracing())
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		return c.SendString(tracing.TraceID(c.UserContext()))
+	})
+
+	req, _ := http.NewRequest("GET", "/ok", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	spans := exp.GetSpans()
+	if len(spans) != 1 || string(got) != spans[0].SpanContext.TraceID().String() {
+		t.Errorf("expected the handler to see the root span's trace ID, got %q", got)
+	}
+	if spans[0].Parent.IsValid() {
+		t.Error("expected a root span without traceparent")
+	}
+}
+
+// captureLogs routes the default slog logger into a buffer for the test.
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(logging.New(&buf, "json", level))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var recs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestLoggerMiddleware(t *testing.T) {
+	buf := captureLogs(t, "info")
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(Logger(map[string]float64{"/health": 0}))
+	app.Use(Core(""))
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("OK") })
+	app.Get("/time", func(c *fiber.Ctx) error {
+		c.Locals("timezone", c.Query("timezone"))
+		if c.Query("timezone") == "Mars/Olympus" {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid timezone: Mars/Olympus")
+		}
+		return c.SendString("12:00")
+	})
+
+	for _, path := range []string{"/health", "/time?timezone=Asia/Tokyo", "/time?timezone=Mars/Olympus"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		req.Header.Set("User-Agent", "test-agent")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		if got := resp.Header.Get("X-Request-ID"); got != "req-1" {
+			t.Errorf("expected X-Request-ID echoed, got %q", got)
+		}
+	}
+
+	recs := logRecords(t, buf)
+	if len(recs) != 2 {
+		t.Fatalf("expected the sampled-out /health request to be skipped, got %d records", len(recs))
+	}
+	ok, failed := recs[0], recs[1]
+	for key, want := range map[string]interface{}{
+		"msg":        "request",
+		"level":      "INFO",
+		"request_id": "req-1",
+		"method":     "GET",
+		"path":       "/time",
+		"route":      "/time",
+		"status":     float64(200),
+		"bytes":      float64(5),
+		"user_agent": "test-agent",
+		"timezone":   "Asia/Tokyo",
+	} {
+		if ok[key] != want {
+			t.Errorf("expected %s=%v, got %v", key, want, ok[key])
+		}
+	}
+	if _, has := ok["latency"]; !has {
+		t.Error("expected a latency field")
+	}
+	if failed["level"] != "WARN" || failed["status"] != float64(400) || failed["error"] != "invalid timezone: Mars/Olympus" {
+		t.Errorf("unexpected failure record %v", failed)
+	}
+}
+
+func TestLoggerLevelAndSampling(t *testing.T) {
+	buf := captureLogs(t, "warn")
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(Logger(map[string]float64{"/health": 0}))
+	app.Get("/health", func(c *fiber.Ctx) error {
+		if c.Query("fail") != "" {
+			return errors.New("database unavailable")
+		}
+		return c.SendString("OK")
+	})
+
+	for _, path := range []string{"/health", "/health?fail=1", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		if _, err := app.Test(req); err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+	}
+
+	recs := logRecords(t, buf)
+	if len(recs) != 2 {
+		t.Fatalf("expected only the two failures at warn level, got %d records", len(recs))
+	}
+	if recs[0]["level"] != "ERROR" || recs[0]["status"] != float64(500) {
+		t.Errorf("expected a sampled route's failure to be logged as error, got %v", recs[0])
+	}
+	if recs[1]["route"] != "unmatched" || recs[1]["status"] != float64(404) {
+		t.Errorf("expected an unmatched 404, got %v", recs[1])
+	}
+}
+
+func TestCoreGeneratesRequestID(t *testing.T) {
+	app := fiber.New()
+	app.Use(Core(""))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("requestID").(string))
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if len(body) == 0 || resp.Header.Get("X-Request-ID") != string(body) {
+		t.Errorf("expected a generated request ID echoed in the header, got %q and %q", body, resp.Header.Get("X-Request-ID"))
+	}
+}
+
+func TestLocalOnly(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/admin", LocalOnly(), func(c *fiber.Ctx) error {
+		return c.SendString("OK")
+	})
+
+	// app.Test connections come from 0.0.0.0.
+	req, _ := http.NewRequest("GET", "/admin", nil)
+	req.Header.Set("X-Forwarded-For", "127.0.0.1")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for a remote peer, got %d", resp.StatusCode)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+	resp, err = http.Get("http://" + ln.Addr().String() + "/admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 from loopback, got %d", resp.StatusCode)
+	}
+}
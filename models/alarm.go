@@ -0,0 +1,35 @@
+package models
+
+// AlarmRequest creates or replaces an alarm. Time is a wall-clock time in
+// Timezone. With Date the alarm fires once; otherwise it repeats on Days
+// (mon..sun, or the shorthands weekdays and weekends), or daily when Days is
+// empty.
+type AlarmRequest struct {
+	Name       string   `json:"name,omitempty" example:"Stand-up"`
+	Message    string   `json:"message,omitempty" example:"Daily stand-up in 5 minutes"`
+	Time       string   `json:"time" example:"09:00"`
+	Timezone   string   `json:"timezone" example:"Asia/Kuala_Lumpur"`
+	Date       string   `json:"date,omitempty" example:"2024-01-05"`
+	Days       []string `json:"days,omitempty" example:"weekdays"`
+	WebhookURL string   `json:"webhook_url,omitempty" example:"https://example.com/hooks/alarm"`
+}
+
+// Alarm is a stored alarm. NextFire is empty once a one-shot alarm has fired.
+type Alarm struct {
+	ID string `json:"id" example:"7f9c2ba4-e88f-4e0b-9c3a-7d3a1c5e2b10"`
+	AlarmRequest
+	NextFire  string `json:"next_fire,omitempty" example:"2024-01-05T09:00:00+08:00"`
+	LastFired string `json:"last_fired,omitempty" example:"2024-01-04T09:00:00+08:00"`
+	CreatedAt string `json:"created_at" example:"2024-01-03T14:30:45Z"`
+}
+
+// AlarmEvent is delivered when an alarm fires, as the data of an "alarm"
+// WebSocket message and as the body of webhook requests.
+type AlarmEvent struct {
+	AlarmID      string `json:"alarm_id" example:"7f9c2ba4-e88f-4e0b-9c3a-7d3a1c5e2b10"`
+	Name         string `json:"name,omitempty" example:"Stand-up"`
+	Message      string `json:"message,omitempty" example:"Daily stand-up in 5 minutes"`
+	Timezone     string `json:"timezone" example:"Asia/Kuala_Lumpur"`
+	ScheduledFor string `json:"scheduled_for" example:"2024-01-05T09:00:00+08:00"`
+	FiredAt      string `json:"fired_at" example:"2024-01-05T01:00:00.002Z"`
+}
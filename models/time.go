@@ -2,13 +2,20 @@ package models
 
 import "time"
 
+// TimeResponse describes an instant in a timezone. The sub-second fields are
+// only populated when a finer precision is requested.
 type TimeResponse struct {
-	Timestamp  string `json:"timestamp" example:"2024-01-03T14:30:45Z"`
-	Timezone   string `json:"timezone" example:"UTC"`
-	Unix       int64  `json:"unix" example:"1704315045"`
-	UnixOffset int    `json:"unix_offset" example:"-18000"`
-	Formatted  string `json:"formatted" example:"2:30:45 PM"`
-	Date       string `json:"date" example:"Wednesday, January 3, 2024"`
+	Timestamp     string `json:"timestamp" example:"2024-01-03T14:30:45Z"`
+	TimestampNano string `json:"timestamp_nano,omitempty" example:"2024-01-03T14:30:45.123456789Z"`
+	Timezone      string `json:"timezone" example:"UTC"`
+	Unix          int64  `json:"unix" example:"1704315045"`
+	UnixMs        int64  `json:"unix_ms,omitempty" example:"1704315045123"`
+	UnixUs        int64  `json:"unix_us,omitempty" example:"1704315045123456"`
+	UnixNs        int64  `json:"unix_ns,omitempty" example:"1704315045123456789"`
+	UnixOffset    int    `json:"unix_offset" example:"-18000"`
+	Formatted     string `json:"formatted" example:"2:30:45 PM"`
+	Date          string `json:"date" example:"Wednesday, January 3, 2024"`
+	Locale        string `json:"locale,omitempty" example:"en"`
 }
 
 type TimeConvertRequest struct {
@@ -24,31 +31,169 @@ type TimeConvertResponse struct {
 	OffsetMinutes int          `json:"offset_minutes" example:"-300"`
 }
 
+type RelativeTimeResponse struct {
+	Text         string  `json:"text" example:"in 3 hours"`
+	Timestamp    string  `json:"timestamp" example:"2024-01-03T17:30:45Z"`
+	Reference    string  `json:"reference" example:"2024-01-03T14:30:45Z"`
+	Timezone     string  `json:"timezone" example:"UTC"`
+	Locale       string  `json:"locale" example:"en"`
+	Unit         string  `json:"unit" example:"hour"`
+	Value        int     `json:"value" example:"3"`
+	Direction    string  `json:"direction" example:"future"`
+	DeltaSeconds float64 `json:"delta_seconds" example:"10800"`
+}
+
+type ParseRequest struct {
+	Text      string `json:"text" example:"tomorrow at noon in Tokyo"`
+	Reference string `json:"reference,omitempty" example:"2024-01-03T14:30:45Z"`
+	Timezone  string `json:"timezone,omitempty" example:"UTC"`
+}
+
+// ParseMatch is the span of the input that was recognized, as byte offsets.
+type ParseMatch struct {
+	Text  string `json:"text" example:"tomorrow at noon in Tokyo"`
+	Start int    `json:"start" example:"0"`
+	End   int    `json:"end" example:"25"`
+}
+
+type ParseResponse struct {
+	Input      string       `json:"input" example:"tomorrow at noon in Tokyo"`
+	Resolved   TimeResponse `json:"resolved"`
+	Match      ParseMatch   `json:"match"`
+	Reference  string       `json:"reference" example:"2024-01-03T23:30:45+09:00"`
+	Confidence float64      `json:"confidence" example:"1"`
+	Ambiguous  bool         `json:"ambiguous" example:"false"`
+}
+
 type TimezoneInfo struct {
 	Name    string  `json:"name" example:"America/New_York"`
 	Offset  float64 `json:"offset" example:"-5.0"`
 	Current string  `json:"current" example:"2:30:45 PM"`
 }
 
+// WebSocketMessage is the envelope for every /ws/time frame in both
+// directions. Client frames set Action; server frames set Type. The full
+// protocol is described by websocket.schema.json in this package.
 type WebSocketMessage struct {
 	Type      string      `json:"type" example:"time_update"`
+	ID        string      `json:"id,omitempty" example:"tokyo"`
 	Action    string      `json:"action,omitempty" example:"subscribe"`
 	Timezone  string      `json:"timezone,omitempty" example:"America/New_York"`
 	Format    string      `json:"format,omitempty" example:"12hour"`
+	Precision string      `json:"precision,omitempty" example:"ms"`
+	Locale    string      `json:"locale,omitempty" example:"de"`
+	Interval  string      `json:"interval,omitempty" example:"5s"`
+	OnChange  string      `json:"on_change,omitempty" example:"minute"`
+	Target    string      `json:"target,omitempty" example:"2024-01-04T00:00:00Z"`
+	AlarmID   string      `json:"alarm_id,omitempty" example:"7f9c2ba4-e88f-4e0b-9c3a-7d3a1c5e2b10"`
+	Originate float64     `json:"originate,omitempty" example:"1704315045123.456"`
 	Data      interface{} `json:"data,omitempty"`
 	Timestamp string      `json:"timestamp,omitempty" example:"2024-01-03T14:30:45Z"`
 }
 
+// WebSocketError is the data of an "error" reply. Field names the message
+// member that was rejected, if any.
+type WebSocketError struct {
+	Code    string `json:"code" example:"invalid_timezone"`
+	Message string `json:"message" example:"invalid timezone: Mars/Olympus"`
+	Field   string `json:"field,omitempty" example:"timezone"`
+}
+
+// SubscriptionAck is the data of an "ack" reply to subscribe, echoing the
+// settings the subscription now uses.
+type SubscriptionAck struct {
+	Timezone  string `json:"timezone" example:"Asia/Tokyo"`
+	Format    string `json:"format,omitempty" example:"24hour"`
+	Precision string `json:"precision" example:"s"`
+	Locale    string `json:"locale,omitempty" example:"ja"`
+	Interval  string `json:"interval,omitempty" example:"1s"`
+	OnChange  string `json:"on_change,omitempty" example:"minute"`
+	Target    string `json:"target,omitempty" example:"2024-01-04T00:00:00Z"`
+}
+
+// CountdownResponse is the data of "countdown" and "countdown_complete"
+// messages. The components count whole seconds rounded up, so they never
+// read zero before the target is reached.
+type CountdownResponse struct {
+	Target      TimeResponse `json:"target"`
+	Remaining   string       `json:"remaining" example:"9h29m15s"`
+	RemainingMs int64        `json:"remaining_ms" example:"34155000"`
+	Days        int          `json:"days" example:"0"`
+	Hours       int          `json:"hours" example:"9"`
+	Minutes     int          `json:"minutes" example:"29"`
+	Seconds     int          `json:"seconds" example:"15"`
+}
+
+// GoingAway is the data of the "going_away" message sent to every WebSocket
+// and SSE client when the server shuts down. ReconnectAfterMs, when set, is
+// how long clients should wait before reconnecting.
+type GoingAway struct {
+	Reason           string `json:"reason" example:"server shutting down"`
+	ReconnectAfterMs int64  `json:"reconnect_after_ms,omitempty" example:"5000"`
+}
+
+// ClockSyncResponse carries the server side of an NTP-style exchange. All
+// values are Unix milliseconds with a fractional part, so clients can compute
+// offset = ((receive - originate) + (transmit - destination)) / 2 and
+// delay = (destination - originate) - (transmit - receive).
+type ClockSyncResponse struct {
+	Originate  float64 `json:"originate" example:"1704315045123.456"`
+	Receive    float64 `json:"receive" example:"1704315045125.789"`
+	Transmit   float64 `json:"transmit" example:"1704315045125.812"`
+	ReceiveNs  int64   `json:"receive_ns" example:"1704315045125789000"`
+	TransmitNs int64   `json:"transmit_ns" example:"1704315045125812000"`
+}
+
 type ErrorResponse struct {
 	Error   string `json:"error" example:"Invalid timezone"`
 	Message string `json:"message" example:"The specified timezone is not supported"`
 	Code    int    `json:"code" example:"400"`
 }
 
+// HealthResponse is the body of /health. The verbose form adds build
+// details, uptime and the result of each check; its status is "healthy",
+// "degraded" when a check warns or "unhealthy" when one fails.
 type HealthResponse struct {
-	Status    string    `json:"status" example:"healthy"`
-	Timestamp time.Time `json:"timestamp" example:"2024-01-03T14:30:45Z"`
-	Version   string    `json:"version" example:"1.0.0"`
+	Status    string                 `json:"status" example:"healthy"`
+	Timestamp time.Time              `json:"timestamp" example:"2024-01-03T14:30:45Z"`
+	Version   string                 `json:"version" example:"1.0.0"`
+	Commit    string                 `json:"commit,omitempty" example:"86bae18"`
+	BuildDate string                 `json:"build_date,omitempty" example:"2024-01-03T12:00:00Z"`
+	Uptime    string                 `json:"uptime,omitempty" example:"72h3m12s"`
+	Checks    map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of one health check: "pass", "warn" or "fail".
+type HealthCheck struct {
+	Status  string `json:"status" example:"pass"`
+	Message string `json:"message,omitempty" example:"68 zones available"`
+}
+
+// ProbeResponse is the body of /livez and /readyz. A failed readiness probe
+// lists the checks that failed.
+type ProbeResponse struct {
+	Status string                 `json:"status" example:"ok"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// ReloadResult reports one configuration reload attempt. Changed settings
+// were applied; RestartRequired ones differ on disk but only take effect after
+// a restart.
+type ReloadResult struct {
+	Time            time.Time `json:"time"`
+	Trigger         string    `json:"trigger" example:"sighup"`
+	Status          string    `json:"status" example:"applied"`
+	Error           string    `json:"error,omitempty"`
+	Changed         []string  `json:"changed,omitempty" example:"allowed_origins,log_level"`
+	RestartRequired []string  `json:"restart_required,omitempty" example:"port"`
+}
+
+// ConfigResponse is the effective configuration, with secrets redacted, and
+// the outcome of the last reload.
+type ConfigResponse struct {
+	File       string                 `json:"file,omitempty" example:"/opt/gotimedate/config.yaml"`
+	Config     map[string]interface{} `json:"config"`
+	LastReload *ReloadResult          `json:"last_reload,omitempty"`
 }
 
 type TimeFormat struct {
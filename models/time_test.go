@@ -2,6 +2,9 @@ package models
 
 import (
 	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -50,3 +53,57 @@ func TestTimeConvertRequest_JSON(t *testing.T) {
 		t.Errorf("expected from_timezone %s, got %s", req.FromTimezone, unmarshaled.FromTimezone)
 	}
 }
+
+// jsonFields returns the JSON member names of struct type v.
+func jsonFields(v interface{}) map[string]bool {
+	fields := map[string]bool{}
+	typ := reflect.TypeOf(v)
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		fields[name] = true
+	}
+	return fields
+}
+
+func TestWebSocketSchemaMatchesModels(t *testing.T) {
+	raw, err := os.ReadFile("websocket.schema.json")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		t.Fatalf("invalid schema JSON: %v", err)
+	}
+
+	objects := map[string]struct {
+		props map[string]json.RawMessage
+		model interface{}
+	}{
+		"WebSocketMessage":  {schema.Properties, WebSocketMessage{}},
+		"TimeResponse":      {schema.Defs["TimeResponse"].Properties, TimeResponse{}},
+		"ClockSyncResponse": {schema.Defs["ClockSyncResponse"].Properties, ClockSyncResponse{}},
+		"SubscriptionAck":   {schema.Defs["SubscriptionAck"].Properties, SubscriptionAck{}},
+		"WebSocketError":    {schema.Defs["WebSocketError"].Properties, WebSocketError{}},
+		"CountdownResponse": {schema.Defs["CountdownResponse"].Properties, CountdownResponse{}},
+		"AlarmEvent":        {schema.Defs["AlarmEvent"].Properties, AlarmEvent{}},
+		"GoingAway":         {schema.Defs["GoingAway"].Properties, GoingAway{}},
+	}
+	for name, obj := range objects {
+		fields := jsonFields(obj.model)
+		for field := range fields {
+			if _, ok := obj.props[field]; !ok {
+				t.Errorf("%s: field %q missing from schema", name, field)
+			}
+		}
+		for prop := range obj.props {
+			if !fields[prop] {
+				t.Errorf("%s: schema property %q not in model", name, prop)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"gotimedate/config"
+	"gotimedate/metrics"
+	"gotimedate/middleware"
+	"gotimedate/ratelimit"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// preforkChildEnv marks a process as a Prefork child, which makes Fiber's
+// Listen bind the shared port instead of forking.
+const preforkChildEnv = "FIBER_PREFORK_CHILD=1"
+
+// preforkGrace is how long past SHUTDOWN_TIMEOUT the master waits for its
+// children before killing them.
+const preforkGrace = 5 * time.Second
+
+// runPreforkMaster replaces Fiber's Prefork master. It starts one child per
+// CPU, forwards SIGHUP, SIGINT and SIGTERM to them and returns once they have
+// all exited, so each child finishes its own graceful shutdown. Fiber's master
+// kills every child as soon as the first one exits. As there, a child exiting
+// on its own stops the rest. With rate limiting enabled the master also serves
+// the children's shared store, and with METRICS_PORT set it serves their
+// metrics. The children's stdout is copied to logOutput a line at a time.
+func runPreforkMaster(cfg *config.Config, logOutput io.Writer) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	// The children's sockets live in a directory only this user can enter.
+	dir, err := os.MkdirTemp("", "gotimedate-")
+	if err != nil {
+		return fmt.Errorf("creating runtime directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := append(os.Environ(), preforkChildEnv)
+	if cfg.MetricsEnabled {
+		// WorkersHandler scrapes every socket in its directory.
+		metricsDir := filepath.Join(dir, "metrics")
+		if err := os.Mkdir(metricsDir, 0o700); err != nil {
+			return fmt.Errorf("creating metrics directory: %w", err)
+		}
+		env = append(env, metrics.DirEnv+"="+metricsDir)
+		if cfg.MetricsPort != "" {
+			app := fiber.New(fiber.Config{
+				DisableStartupMessage: true,
+				ErrorHandler:          middleware.ErrorHandler,
+			})
+			app.Get("/metrics", metrics.WorkersHandler(metricsDir))
+			addr := cfg.Host + ":" + cfg.MetricsPort
+			slog.Info("Metrics listener starting", "addr", addr)
+			go func() {
+				if err := app.Listen(addr); err != nil {
+					slog.Error("Metrics listener failed", "error", err)
+				}
+			}()
+			defer app.Shutdown()
+		}
+	}
+	if cfg.RateLimitEnabled {
+		ln, err := net.Listen("unix", filepath.Join(dir, "ratelimit.sock"))
+		if err != nil {
+			return fmt.Errorf("starting rate limit store: %w", err)
+		}
+		defer ln.Close()
+		go func() {
+			if err := ratelimit.Serve(ln, ratelimit.NewMemoryStore()); err != nil {
+				slog.Error("Rate limit store failed", "error", err)
+			}
+		}()
+		env = append(env, ratelimit.SocketEnv+"="+ln.Addr().String())
+	}
+
+	n := runtime.GOMAXPROCS(0)
+	children := make([]*exec.Cmd, 0, n)
+	exited := make(chan error, n)
+	for i := 0; i < n; i++ {
+		cmd := exec.Command(os.Args[0], os.Args[1:]...)
+		cmd.Stderr = os.Stderr
+		cmd.Env = env
+		stdout, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			signalChildren(children, syscall.SIGTERM)
+			return fmt.Errorf("starting prefork child: %w", err)
+		}
+		children = append(children, cmd)
+		go func() {
+			copyLines(logOutput, stdout)
+			exited <- cmd.Wait()
+		}()
+	}
+	pids := make([]int, len(children))
+	for i, cmd := range children {
+		pids[i] = cmd.Process.Pid
+	}
+	slog.Info("Prefork master started", "addr", cfg.Host+":"+cfg.Port, "pid", os.Getpid(), "children", pids)
+
+	var failure error
+	var deadline <-chan time.Time
+	stopping := false
+	stopAll := func() {
+		if stopping {
+			return
+		}
+		stopping = true
+		signalChildren(children, syscall.SIGTERM)
+		deadline = time.After(shutdownTimeout(cfg) + preforkGrace)
+	}
+	for running := len(children); running > 0; {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				signalChildren(children, sig)
+				continue
+			}
+			slog.Info("Shutting down prefork children", "signal", sig.String())
+			stopAll()
+		case err := <-exited:
+			running--
+			if !stopping {
+				if err == nil {
+					err = errors.New("exited unexpectedly")
+				}
+				failure = err
+				stopAll()
+			}
+		case <-deadline:
+			slog.Warn("Killing prefork children that did not stop in time")
+			for _, cmd := range children {
+				cmd.Process.Kill()
+			}
+			deadline = nil
+		}
+	}
+	return failure
+}
+
+// copyLines copies r to w one line per Write, so that lines from several
+// children are not interleaved.
+func copyLines(w io.Writer, r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			w.Write(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// signalChildren sends sig to every child still running.
+func signalChildren(children []*exec.Cmd, sig os.Signal) {
+	for _, cmd := range children {
+		if err := cmd.Process.Signal(sig); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			slog.Error("Signalling prefork child failed", "pid", cmd.Process.Pid, "error", err)
+		}
+	}
+}
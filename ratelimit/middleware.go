@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"errors"
+	"gotimedate/auth"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// What a client is keyed by.
+const (
+	// KeyIP keys every request by client IP.
+	KeyIP = "ip"
+	// KeyCredential keys authenticated requests by API key or JWT subject and
+	// anonymous ones by IP.
+	KeyCredential = "credential"
+)
+
+// storeWarnInterval is how often a failing store is logged.
+const storeWarnInterval = time.Minute
+
+type settings struct {
+	rules map[string]Rule
+	key   string
+}
+
+// Limiter applies a token bucket rule per route group.
+type Limiter struct {
+	store    Store
+	fallback *MemoryStore
+	settings atomic.Pointer[settings]
+	warned   atomic.Int64
+}
+
+// New returns a Limiter counting in store. rules are keyed by route group;
+// groups without a rule are not limited.
+func New(store Store, rules map[string]Rule, key string) *Limiter {
+	l := &Limiter{store: store, fallback: NewMemoryStore()}
+	l.SetRules(rules, key)
+	return l
+}
+
+// SetRules replaces the rules and the client key. Buckets carry over, capped
+// at the new burst.
+func (l *Limiter) SetRules(rules map[string]Rule, key string) {
+	l.settings.Store(&settings{rules: rules, key: key})
+}
+
+// Limit takes a token for the client from group's bucket and answers 429 once
+// it is empty. Every limited response carries RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers, and a 429
+// also Retry-After. Placed after an auth guard it can key by credential. A
+// nil Limiter admits everything.
+func (l *Limiter) Limit(group string) fiber.Handler {
+	if l == nil {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	return func(c *fiber.Ctx) error {
+		s := l.settings.Load()
+		rule, ok := s.rules[group]
+		if !ok {
+			return c.Next()
+		}
+		res := l.take(c, group+"|"+clientKey(c, s.key), rule, 1)
+		c.Set("RateLimit-Limit", strconv.Itoa(rule.Burst))
+		c.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		c.Set("RateLimit-Policy", strconv.Itoa(rule.Requests)+";w="+strconv.Itoa(ceilSeconds(rule.Period))+";burst="+strconv.Itoa(rule.Burst))
+		if !res.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(1, ceilSeconds(res.RetryAfter))))
+			return fiber.NewError(fiber.StatusTooManyRequests, "rate limit exceeded")
+		}
+		return c.Next()
+	}
+}
+
+// Failures limits requests that fail authentication, by client IP under
+// group's rule. Placed before an auth guard, it refuses a client whose bucket
+// is empty before its credentials are checked, and takes a token for every
+// request the guard answers with 401. Requests that authenticate cost
+// nothing, so clients sharing an IP are still counted by Limit alone. A nil
+// Limiter admits everything.
+func (l *Limiter) Failures(group string) fiber.Handler {
+	if l == nil {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	return func(c *fiber.Ctx) error {
+		rule, ok := l.settings.Load().rules[group]
+		if !ok {
+			return c.Next()
+		}
+		key := group + "|auth|ip:" + c.IP()
+		if res := l.take(c, key, rule, 0); !res.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(1, ceilSeconds(res.RetryAfter))))
+			return fiber.NewError(fiber.StatusTooManyRequests, "too many failed authentication attempts")
+		}
+		err := c.Next()
+		var fe *fiber.Error
+		if errors.As(err, &fe) && fe.Code == fiber.StatusUnauthorized {
+			l.take(c, key, rule, 1)
+		}
+		return err
+	}
+}
+
+// take counts in this process while the store fails, so that a Prefork
+// child keeps limiting on its own if the master's store is unreachable.
+func (l *Limiter) take(c *fiber.Ctx, key string, rule Rule, n int) Result {
+	res, err := l.store.Take(key, rule, n)
+	if err == nil {
+		return res
+	}
+	now, last := time.Now().UnixNano(), l.warned.Load()
+	if now-last >= int64(storeWarnInterval) && l.warned.CompareAndSwap(last, now) {
+		slog.WarnContext(c.UserContext(), "Rate limit store failed, counting in this process", "error", err)
+	}
+	res, _ = l.fallback.Take(key, rule, n)
+	return res
+}
+
+func clientKey(c *fiber.Ctx, key string) string {
+	if key == KeyCredential {
+		if p := auth.FromContext(c); p != nil {
+			return p.Method + ":" + p.Subject
+		}
+	}
+	return "ip:" + c.IP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"gotimedate/auth"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func limitApp(t *testing.T, l *Limiter, guard fiber.Handler) *fiber.App {
+	t.Helper()
+	app := fiber.New()
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/api", guard, l.Limit("api"), ok)
+	app.Get("/open", guard, l.Limit("unlisted"), ok)
+	return app
+}
+
+func get(t *testing.T, app *fiber.App, path, apiKey string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("GET", path, nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestLimit(t *testing.T) {
+	l := New(NewMemoryStore(), map[string]Rule{"api": {Requests: 1, Period: time.Minute, Burst: 2}}, KeyIP)
+	app := limitApp(t, l, func(c *fiber.Ctx) error { return c.Next() })
+
+	for i := 1; i >= 0; i-- {
+		resp := get(t, app, "/api", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 within the burst, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != strconv.Itoa(i) {
+			t.Errorf("expected RateLimit-Remaining %d, got %q", i, got)
+		}
+		if got := resp.Header.Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("expected RateLimit-Limit 2, got %q", got)
+		}
+		if got := resp.Header.Get("RateLimit-Policy"); got != "1;w=60;burst=2" {
+			t.Errorf("expected RateLimit-Policy 1;w=60;burst=2, got %q", got)
+		}
+	}
+
+	resp := get(t, app, "/api", "")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 past the burst, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+	if got := resp.Header.Get("RateLimit-Reset"); got != "120" {
+		t.Errorf("expected RateLimit-Reset 120, got %q", got)
+	}
+
+	resp = get(t, app, "/open", "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("RateLimit-Limit") != "" {
+		t.Errorf("expected a group without a rule to be unlimited, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	l.SetRules(map[string]Rule{"api": {Requests: 10, Period: time.Second, Burst: 10}}, KeyIP)
+	resp = get(t, app, "/api", "")
+	if got := resp.Header.Get("RateLimit-Limit"); got != "10" {
+		t.Errorf("expected new rules to apply, got RateLimit-Limit %q", got)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "1" {
+		t.Errorf("expected the emptied bucket to refill at the new rate, got Retry-After %q", got)
+	}
+}
+
+func TestLimitByCredential(t *testing.T) {
+	a, err := auth.New(auth.Options{APIKeys: []string{
+		"alice:" + auth.HashAPIKey("alice-key"),
+		"bob:" + auth.HashAPIKey("bob-key"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(NewMemoryStore(), map[string]Rule{"api": {Requests: 1, Period: time.Minute, Burst: 1}}, KeyCredential)
+	app := limitApp(t, l, a.Require())
+
+	if resp := get(t, app, "/api", "alice-key"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected alice's first request to pass, got %d", resp.StatusCode)
+	}
+	if resp := get(t, app, "/api", "alice-key"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected alice to be limited, got %d", resp.StatusCode)
+	}
+	if resp := get(t, app, "/api", "bob-key"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected bob, from the same IP, to have a separate bucket, got %d", resp.StatusCode)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	a, err := auth.New(auth.Options{APIKeys: []string{"alice:" + auth.HashAPIKey("alice-key")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(NewMemoryStore(), map[string]Rule{"api": {Requests: 1, Period: time.Minute, Burst: 2}}, KeyCredential)
+	app := fiber.New()
+	app.Get("/api", l.Failures("api"), a.Require(), func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	for i := 0; i < 2; i++ {
+		if resp := get(t, app, "/api", "wrong-key"); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for a bad key within the burst, got %d", resp.StatusCode)
+		}
+	}
+	resp := get(t, app, "/api", "wrong-key")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "60" {
+		t.Errorf("expected 429 with Retry-After 60 once the bad keys used the burst, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if resp := get(t, app, "/api", "alice-key"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the IP to be refused before its credentials are checked, got %d", resp.StatusCode)
+	}
+
+	l2 := New(NewMemoryStore(), map[string]Rule{"api": {Requests: 1, Period: time.Minute, Burst: 1}}, KeyCredential)
+	app = fiber.New()
+	app.Get("/api", l2.Failures("api"), a.Require(), func(c *fiber.Ctx) error { return c.SendString("ok") })
+	for i := 0; i < 3; i++ {
+		if resp := get(t, app, "/api", "alice-key"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected authenticated requests not to use the failure bucket, got %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestLimitStoreFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	l := New(NewRemoteStore("tcp", addr), map[string]Rule{"api": {Requests: 1, Period: time.Minute, Burst: 1}}, KeyIP)
+	app := limitApp(t, l, func(c *fiber.Ctx) error { return c.Next() })
+	if resp := get(t, app, "/api", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", resp.StatusCode)
+	}
+	if resp := get(t, app, "/api", ""); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the local fallback to keep limiting, got %d", resp.StatusCode)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	app := limitApp(t, l, func(c *fiber.Ctx) error { return c.Next() })
+	for i := 0; i < 3; i++ {
+		if resp := get(t, app, "/api", ""); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected a nil limiter to admit everything, got %d", resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SocketEnv names the Unix socket of the Prefork master's store in the
+// environment of the children it starts.
+const SocketEnv = "GOTIMEDATE_RATELIMIT_SOCKET"
+
+// NewStore returns a RemoteStore for the Prefork master's store when this
+// process is one of its children, so that every child counts against the same
+// buckets, and a new MemoryStore otherwise.
+func NewStore() Store {
+	if path := os.Getenv(SocketEnv); path != "" {
+		return NewRemoteStore("unix", path)
+	}
+	return NewMemoryStore()
+}
+
+// The protocol is one line per take. A request is
+//
+//	<requests> <period ns> <burst> <n> <quoted key>
+//
+// and the reply is
+//
+//	<allowed 0|1> <remaining> <reset ns> <retry after ns>
+//
+// or "ERR <message>".
+
+// Serve answers RemoteStores connecting to ln from store until ln is closed.
+func Serve(ln net.Listener, store Store) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveConn(conn, store)
+	}
+}
+
+func serveConn(conn net.Conn, store Store) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		var res Result
+		key, rule, n, err := parseRequest(strings.TrimSuffix(line, "\n"))
+		if err == nil {
+			res, err = store.Take(key, rule, n)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "ERR %s\n", strings.ReplaceAll(err.Error(), "\n", " "))
+		} else {
+			allowed := 0
+			if res.Allowed {
+				allowed = 1
+			}
+			fmt.Fprintf(w, "%d %d %d %d\n", allowed, res.Remaining, res.Reset, res.RetryAfter)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func parseRequest(line string) (string, Rule, int, error) {
+	fields := strings.SplitN(line, " ", 5)
+	if len(fields) != 5 {
+		return "", Rule{}, 0, fmt.Errorf("malformed request %q", line)
+	}
+	var rule Rule
+	var period int64
+	var n int
+	var err error
+	if rule.Requests, err = strconv.Atoi(fields[0]); err == nil {
+		if period, err = strconv.ParseInt(fields[1], 10, 64); err == nil {
+			if rule.Burst, err = strconv.Atoi(fields[2]); err == nil {
+				n, err = strconv.Atoi(fields[3])
+			}
+		}
+	}
+	if err != nil || rule.Requests < 1 || period <= 0 || rule.Burst < 1 || n < 0 {
+		return "", Rule{}, 0, fmt.Errorf("invalid rule in %q", line)
+	}
+	rule.Period = time.Duration(period)
+	key, err := strconv.Unquote(fields[4])
+	if err != nil {
+		return "", Rule{}, 0, fmt.Errorf("invalid key in %q", line)
+	}
+	return key, rule, n, nil
+}
+
+// remoteTimeout bounds a take against the master, so that a stuck master
+// slows requests down by no more than this.
+const remoteTimeout = 250 * time.Millisecond
+
+// remoteIdle is how many connections a RemoteStore keeps open between takes.
+const remoteIdle = 64
+
+// RemoteStore takes tokens from a store served by Serve.
+type RemoteStore struct {
+	network string
+	addr    string
+	idle    chan *remoteConn
+}
+
+type remoteConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// NewRemoteStore returns a store that connects to addr when it is first used.
+func NewRemoteStore(network, addr string) *RemoteStore {
+	return &RemoteStore{network: network, addr: addr, idle: make(chan *remoteConn, remoteIdle)}
+}
+
+func (s *RemoteStore) Take(key string, rule Rule, n int) (Result, error) {
+	conn, err := s.conn()
+	if err != nil {
+		return Result{}, err
+	}
+	res, err := conn.take(key, rule, n)
+	var remote remoteError
+	if err != nil && !errors.As(err, &remote) {
+		conn.Close()
+		return Result{}, err
+	}
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return res, err
+}
+
+// Close closes the idle connections.
+func (s *RemoteStore) Close() error {
+	for {
+		select {
+		case conn := <-s.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (s *RemoteStore) conn() (*remoteConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+	conn, err := net.DialTimeout(s.network, s.addr, remoteTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteConn{Conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}, nil
+}
+
+// remoteError is an error reported by the serving store, after which the
+// connection is still usable.
+type remoteError string
+
+func (e remoteError) Error() string { return "rate limit store: " + string(e) }
+
+func (c *remoteConn) take(key string, rule Rule, n int) (Result, error) {
+	if err := c.SetDeadline(time.Now().Add(remoteTimeout)); err != nil {
+		return Result{}, err
+	}
+	fmt.Fprintf(c.w, "%d %d %d %d %s\n", rule.Requests, int64(rule.Period), rule.Burst, n, strconv.Quote(key))
+	if err := c.w.Flush(); err != nil {
+		return Result{}, err
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return Result{}, err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	if msg, ok := strings.CutPrefix(line, "ERR "); ok {
+		return Result{}, remoteError(msg)
+	}
+
+	var allowed int
+	var reset, retry int64
+	var res Result
+	if _, err := fmt.Sscanf(line, "%d %d %d %d", &allowed, &res.Remaining, &reset, &retry); err != nil {
+		return Result{}, fmt.Errorf("malformed reply %q", line)
+	}
+	res.Allowed = allowed == 1
+	res.Reset, res.RetryAfter = time.Duration(reset), time.Duration(retry)
+	return res, nil
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRemoteStore(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- Serve(ln, NewMemoryStore()) }()
+
+	rule := Rule{Requests: 1, Period: time.Minute, Burst: 2}
+	a := NewRemoteStore("tcp", ln.Addr().String())
+	b := NewRemoteStore("tcp", ln.Addr().String())
+	defer a.Close()
+	defer b.Close()
+
+	// Two stores, as in two Prefork children, share the served buckets.
+	for i, s := range []*RemoteStore{a, b, a} {
+		res, err := s.Take("client \"1\"\n", rule, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i < 2; res.Allowed != want {
+			t.Errorf("take %d: expected allowed %v, got %+v", i, want, res)
+		}
+	}
+	res, err := b.Take("client \"1\"\n", rule, 1)
+	if err != nil || res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Errorf("expected a refusal with a retry time, got %+v, %v", res, err)
+	}
+
+	if res, err := a.Take("peek", rule, 0); err != nil || !res.Allowed || res.Remaining != 2 {
+		t.Errorf("expected a check to leave the bucket full, got %+v, %v", res, err)
+	}
+
+	if _, err := a.Take("x", Rule{Requests: 0, Period: time.Second, Burst: 1}, 1); err == nil {
+		t.Error("expected an invalid rule to be reported")
+	}
+	if _, err := a.Take("x", rule, 1); err != nil {
+		t.Errorf("expected the connection to survive a reported error, got %v", err)
+	}
+
+	ln.Close()
+	if err := <-served; err != nil {
+		t.Errorf("expected Serve to return nil once closed, got %v", err)
+	}
+}
+
+func TestRemoteStoreUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := NewRemoteStore("tcp", addr)
+	if _, err := s.Take("x", Rule{Requests: 1, Period: time.Second, Burst: 1}, 1); err == nil {
+		t.Error("expected an error without a server")
+	}
+}
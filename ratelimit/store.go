@@ -0,0 +1,117 @@
+// Package ratelimit limits request rates per client with token buckets kept
+// in a Store, which Prefork children share through the master process.
+package ratelimit
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rule is a token bucket that refills at Requests per Period and holds up to
+// Burst tokens.
+type Rule struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// rate is the refill rate in tokens per second.
+func (r Rule) rate() float64 {
+	return float64(r.Requests) / r.Period.Seconds()
+}
+
+// Result is the outcome of taking tokens.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the tokens are available, when not Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets by key.
+type Store interface {
+	// Take takes n tokens from the bucket for key. With n 0 it only reports
+	// whether a token is available.
+	Take(key string, rule Rule, n int) (Result, error)
+}
+
+// shards spreads buckets over separately locked maps.
+const shards = 32
+
+// sweepInterval is how often a shard drops buckets that have refilled, which
+// behave exactly like missing ones.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+type shard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// MemoryStore keeps buckets in this process.
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards [shards]shard
+	now    func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{seed: maphash.MakeSeed(), now: time.Now}
+	for i := range s.shards {
+		s.shards[i].buckets = make(map[string]*bucket)
+	}
+	return s
+}
+
+// Take takes n tokens from the bucket for key, refilling it for the time
+// since the last take. A changed rule applies from the next take on.
+func (s *MemoryStore) Take(key string, rule Rule, n int) (Result, error) {
+	rate, burst := rule.rate(), float64(rule.Burst)
+	sh := &s.shards[maphash.String(s.seed, key)%shards]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(sh.lastSweep) >= sweepInterval {
+		for k, b := range sh.buckets {
+			if !now.Before(b.full) {
+				delete(sh.buckets, k)
+			}
+		}
+		sh.lastSweep = now
+	}
+
+	b := sh.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: burst, last: now}
+		sh.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	var res Result
+	if need := math.Max(float64(n), 1); b.tokens >= need {
+		b.tokens -= float64(n)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((need - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((burst - b.tokens) / rate)
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	rule := Rule{Requests: 2, Period: time.Second, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		res, _ := s.Take("a", rule, 1)
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("expected take with %d remaining, got %+v", i, res)
+		}
+	}
+	res, _ := s.Take("a", rule, 1)
+	if res.Allowed {
+		t.Fatal("expected the empty bucket to refuse")
+	}
+	if res.RetryAfter != 500*time.Millisecond || res.Reset != 1500*time.Millisecond {
+		t.Errorf("expected retry after 500ms and reset in 1.5s, got %+v", res)
+	}
+	if res, _ := s.Take("b", rule, 1); !res.Allowed {
+		t.Error("expected another key to have its own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res, _ := s.Take("a", rule, 1); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected one token refilled after 500ms, got %+v", res)
+	}
+
+	now = now.Add(time.Hour)
+	if res, _ := s.Take("a", rule, 1); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("expected the refill to stop at the burst, got %+v", res)
+	}
+	for i := range s.shards {
+		if n := len(s.shards[i].buckets); n > 1 {
+			t.Errorf("expected full buckets to be swept, shard %d holds %d", i, n)
+		}
+	}
+
+	if res, _ := s.Take("c", rule, 0); !res.Allowed || res.Remaining != 3 {
+		t.Errorf("expected taking no tokens to only check the bucket, got %+v", res)
+	}
+
+	lower := Rule{Requests: 1, Period: time.Second, Burst: 1}
+	now = now.Add(time.Hour)
+	if res, _ := s.Take("a", lower, 1); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected a lowered burst to cap the bucket, got %+v", res)
+	}
+}
@@ -1,12 +1,20 @@
 package router
 
 import (
+	"context"
+	"errors"
+	"gotimedate/auth"
 	"gotimedate/config"
 	_ "gotimedate/docs"
 	"gotimedate/handlers"
+	"gotimedate/logging"
+	"gotimedate/metrics"
 	"gotimedate/middleware"
+	"gotimedate/models"
+	"gotimedate/ratelimit"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -16,31 +24,101 @@ import (
 	"github.com/gofiber/websocket/v2"
 )
 
+// Server is the public app together with the handlers that hold long-lived
+// connections and background work.
+type Server struct {
+	App *fiber.App
+
+	cfg    atomic.Pointer[config.Config]
+	auth   *auth.Authenticator
+	limit  *ratelimit.Limiter
+	time   *handlers.TimeHandler
+	ws     *handlers.WSHandler
+	sse    *handlers.SSEHandler
+	alarms *handlers.AlarmHandler
+}
+
 func SetupRouter(cfg *config.Config) *fiber.App {
+	return NewServer(cfg).App
+}
+
+// NewServer builds the public app with all middleware and routes.
+func NewServer(cfg *config.Config) *Server {
+	s := &Server{}
+	s.cfg.Store(cfg)
 	app := fiber.New(fiber.Config{
 		DisableStartupMessage: false,
 		Prefork:               cfg.Prefork,
 		ErrorHandler:          middleware.ErrorHandler,
 	})
 
+	// The logger is outermost so it records recovered panics and the final
+	// response of every error.
+	app.Use(middleware.Logger(cfg.LogSampling))
 	app.Use(recover.New())
+	app.Use(middleware.Tracing())
 	app.Use(middleware.SecurityHeaders())
-	app.Use(middleware.Logger(cfg.LogLevel))
+	if cfg.MetricsEnabled {
+		app.Use(middleware.Metrics())
+	}
 	app.Use(middleware.Core(cfg.LogLevel))
-	app.Use(etag.New())
+	app.Use(etag.New(etag.Config{
+		// ETags need the whole body, which an event stream never finishes.
+		Next: func(c *fiber.Ctx) bool {
+			return strings.HasPrefix(c.Path(), "/sse/")
+		},
+	}))
 
 	app.Use(cors.New(cors.Config{
 		AllowOriginsFunc: func(origin string) bool {
-			return cfg.IsOriginAllowed(origin)
+			return s.cfg.Load().IsOriginAllowed(origin)
 		},
 		AllowMethods:     strings.Join(cfg.AllowedMethods, ","),
 		AllowHeaders:     strings.Join(cfg.AllowedHeaders, ","),
 		AllowCredentials: cfg.AllowCredentials,
+		ExposeHeaders:    "RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After",
 		MaxAge:           cfg.MaxAge,
 	}))
 
+	// Without auth the nil authenticator's guards admit every request.
+	if cfg.AuthEnabled {
+		authn, err := auth.New(auth.Options{
+			APIKeys:   cfg.AuthAPIKeys,
+			JWKSFile:  cfg.AuthJWKSFile,
+			JWTSecret: cfg.AuthJWTSecret,
+			Issuer:    cfg.AuthJWTIssuer,
+			Audience:  cfg.AuthJWTAudience,
+		})
+		if err != nil {
+			logging.Fatal("Error setting up authentication", "error", err)
+		}
+		s.auth = authn
+	}
+	timeRead := s.auth.Require(auth.ScopeTimeRead)
+	alarmsRead := s.auth.Require(auth.ScopeAlarmsRead)
+	alarmsWrite := s.auth.Require(auth.ScopeAlarmsWrite)
+
+	// Limits run after the auth guards so that they can key by credential,
+	// and failed authentication is limited by IP before the guards. Without
+	// rate limiting the nil limiter admits every request.
+	if cfg.RateLimitEnabled {
+		s.limit = ratelimit.New(ratelimit.NewStore(), rateLimitRules(cfg), cfg.RateLimitKey)
+	}
+	apiLimit := s.limit.Limit("api")
+	failures := s.limit
+	if s.auth == nil {
+		failures = nil
+	}
+
 	timeHandler := handlers.NewTimeHandler(cfg.DefaultTimezone)
 	wsHandler := handlers.NewWSHandler(cfg)
+	var alarmHandler *handlers.AlarmHandler
+	if cfg.AlarmsEnabled {
+		var err error
+		if alarmHandler, err = handlers.NewAlarmHandler(cfg, wsHandler.Hub()); err != nil {
+			logging.Fatal("Error loading alarms", "error", err)
+		}
+	}
 
 	app.Use("/ws/time", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
@@ -49,24 +127,123 @@ func SetupRouter(cfg *config.Config) *fiber.App {
 		return fiber.ErrUpgradeRequired
 	})
 
-	app.Get("/ws/time", websocket.New(wsHandler.ServeHTTP, websocket.Config{
-		Origins: []string{"*"},
+	app.Get("/ws/time", failures.Failures("ws"), s.auth.RequireUpgrade(auth.ScopeTimeRead), s.limit.Limit("ws"), wsHandler.Admit, websocket.New(wsHandler.ServeHTTP, websocket.Config{
+		Origins:           []string{"*"},
+		Subprotocols:      handlers.Subprotocols(),
+		EnableCompression: cfg.WSCompression,
 	}))
 
+	sseHandler := handlers.NewSSEHandler(cfg, wsHandler.Hub())
+	app.Get("/sse/time", failures.Failures("sse"), s.auth.RequireUpgrade(auth.ScopeTimeRead), s.limit.Limit("sse"), sseHandler.Stream)
+
+	if cfg.MetricsEnabled {
+		metrics.TrackWebSocket(wsHandler)
+		if cfg.MetricsPort == "" {
+			app.Get("/metrics", s.auth.Require(auth.ScopeMetricsRead), metrics.Handler())
+		}
+	}
+
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
-	app.Get("/health", timeHandler.HealthCheck)
+	healthHandler := handlers.NewHealthHandler(cfg, wsHandler.Hub())
+	app.Get("/health", healthHandler.Health)
+	app.Get("/livez", healthHandler.Live)
+	app.Get("/readyz", healthHandler.Ready)
 
-	api := app.Group("/api/v1")
-	api.Get("/time", timeHandler.GetCurrentTime)
-	api.Get("/timezones", timeHandler.GetAvailableTimezones)
-	api.Get("/time/*", timeHandler.GetTimeByTimezone)
-	api.Post("/time/convert", timeHandler.ConvertTime)
+	api := app.Group("/api/v1", failures.Failures("api"))
+	api.Get("/time", timeRead, apiLimit, timeHandler.GetCurrentTime)
+	api.Get("/ws/stats", s.auth.Require(auth.ScopeAdmin), apiLimit, wsHandler.Stats)
+	api.Get("/timezones", timeRead, apiLimit, timeHandler.GetAvailableTimezones)
+	api.Get("/time/sync", timeRead, apiLimit, timeHandler.ClockSync)
+	api.Get("/time/relative", timeRead, apiLimit, timeHandler.GetRelativeTime)
+	api.Get("/time/*", timeRead, apiLimit, timeHandler.GetTimeByTimezone)
+	api.Post("/time/convert", timeRead, apiLimit, timeHandler.ConvertTime)
+	api.Post("/time/parse", timeRead, apiLimit, timeHandler.ParseTime)
+
+	if alarmHandler != nil {
+		api.Get("/alarms", alarmsRead, apiLimit, alarmHandler.ListAlarms)
+		api.Post("/alarms", alarmsWrite, apiLimit, alarmHandler.CreateAlarm)
+		api.Get("/alarms/:id", alarmsRead, apiLimit, alarmHandler.GetAlarm)
+		api.Put("/alarms/:id", alarmsWrite, apiLimit, alarmHandler.UpdateAlarm)
+		api.Delete("/alarms/:id", alarmsWrite, apiLimit, alarmHandler.DeleteAlarm)
+	}
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		indexFile := filepath.Join(cfg.StaticDir, "index.html")
 		return c.SendFile(indexFile)
 	})
 
+	s.App, s.time, s.ws, s.sse, s.alarms = app, timeHandler, wsHandler, sseHandler, alarmHandler
+	return s
+}
+
+// Reload applies the settings of cfg that can change at runtime: the CORS
+// origins, the default timezone, the WebSocket limits and the rate limits.
+func (s *Server) Reload(cfg *config.Config) {
+	s.cfg.Store(cfg)
+	if s.limit != nil {
+		s.limit.SetRules(rateLimitRules(cfg), cfg.RateLimitKey)
+	}
+	s.time.SetDefaultTimezone(cfg.DefaultTimezone)
+	s.sse.SetDefaultTimezone(cfg.DefaultTimezone)
+	if s.alarms != nil {
+		s.alarms.SetDefaultTimezone(cfg.DefaultTimezone)
+	}
+	s.ws.Reload(cfg)
+}
+
+func rateLimitRules(cfg *config.Config) map[string]ratelimit.Rule {
+	rules := make(map[string]ratelimit.Rule, len(cfg.RateLimits))
+	for group, limit := range cfg.RateLimits {
+		rules[group] = ratelimit.Rule{Requests: limit.Requests, Period: limit.Period, Burst: limit.Burst}
+	}
+	return rules
+}
+
+// Shutdown stops accepting connections, sends every WebSocket and SSE client
+// a going_away notice and waits for in-flight requests and close handshakes
+// until ctx ends. It then stops the alarm scheduler.
+func (s *Server) Shutdown(ctx context.Context) error {
+	appErr := make(chan error, 1)
+	go func() { appErr <- s.App.ShutdownWithContext(ctx) }()
+
+	notice := models.GoingAway{Reason: "server shutting down"}
+	if cfg := s.cfg.Load(); cfg.ShutdownReconnect > 0 {
+		notice.ReconnectAfterMs = int64(cfg.ShutdownReconnect) * 1000
+	}
+	wsErr := s.ws.Shutdown(ctx, notice)
+	err := errors.Join(<-appErr, wsErr)
+	if s.alarms != nil {
+		s.alarms.Close()
+	}
+	return err
+}
+
+// SetupAdmin returns the app served on METRICS_PORT. It carries the metrics
+// endpoint, when enabled, and none of the public middleware. MountAdmin adds
+// the config endpoints.
+func SetupAdmin(cfg *config.Config) *fiber.App {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		ErrorHandler:          middleware.ErrorHandler,
+	})
+	app.Use(recover.New())
+	if cfg.MetricsEnabled {
+		app.Get("/metrics", metrics.Handler())
+	}
 	return app
 }
+
+// MountAdmin adds the config endpoints under /admin to r, the public app or
+// the one from SetupAdmin. They answer loopback clients only and, with auth
+// enabled, only callers with the admin scope.
+func (s *Server) MountAdmin(r fiber.Router, reloader *config.Reloader) {
+	mountAdmin(r, reloader, middleware.LocalOnly(), s.auth.Require(auth.ScopeAdmin))
+}
+
+func mountAdmin(r fiber.Router, reloader *config.Reloader, guards ...fiber.Handler) {
+	h := handlers.NewConfigHandler(reloader)
+	admin := r.Group("/admin", guards...)
+	admin.Get("/config", h.Get)
+	admin.Post("/config/reload", h.Reload)
+}
@@ -1,11 +1,35 @@
 package router
 
 import (
+	"bufio"
+	"context"
+	"gotimedate/auth"
 	"gotimedate/config"
+	"gotimedate/models"
+	"io"
+	"net"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
+
+	fws "github.com/fasthttp/websocket"
 )
 
+// newServer builds a server whose hub and alarm scheduler are stopped when
+// the test ends.
+func newServer(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+	s := NewServer(cfg)
+	t.Cleanup(func() {
+		s.ws.Stop()
+		if s.alarms != nil {
+			s.alarms.Close()
+		}
+	})
+	return s
+}
+
 func TestSetupRouter(t *testing.T) {
 	cfg := &config.Config{
 		AllowedOrigins:  []string{"*"},
@@ -16,7 +40,7 @@ func TestSetupRouter(t *testing.T) {
 	}
 	cfg.CompileOrigins()
 
-	app := SetupRouter(cfg)
+	app := newServer(t, cfg).App
 	if app == nil {
 		t.Fatal("router should not be nil")
 	}
@@ -43,6 +67,26 @@ func TestSetupRouter(t *testing.T) {
 		}
 	})
 
+	t.Run("SSE Route Streams", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go app.Listener(ln)
+		defer app.Shutdown()
+
+		client := &http.Client{Timeout: 3 * time.Second}
+		resp, err := client.Get("http://" + ln.Addr().String() + "/sse/time")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, "retry:") {
+			t.Errorf("expected streamed retry line, got %q (%v)", line, err)
+		}
+	})
+
 	t.Run("Invalid Route", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/invalid-route-123", nil)
 		resp, err := app.Test(req)
@@ -54,3 +98,353 @@ func TestSetupRouter(t *testing.T) {
 		}
 	})
 }
+
+func TestMetricsRoutes(t *testing.T) {
+	get := func(t *testing.T, app interface {
+		Test(*http.Request, ...int) (*http.Response, error)
+	}, path string) int {
+		t.Helper()
+		req, _ := http.NewRequest("GET", path, nil)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	cfg := &config.Config{DefaultTimezone: "UTC", StaticDir: "static", MetricsEnabled: true}
+	if status := get(t, newServer(t, cfg).App, "/metrics"); status != http.StatusOK {
+		t.Errorf("expected /metrics on the main app, got %d", status)
+	}
+
+	cfg.MetricsPort = "9090"
+	if status := get(t, newServer(t, cfg).App, "/metrics"); status != http.StatusNotFound {
+		t.Errorf("expected /metrics off the main app with METRICS_PORT set, got %d", status)
+	}
+	if status := get(t, SetupAdmin(cfg), "/metrics"); status != http.StatusOK {
+		t.Errorf("expected /metrics on the admin app, got %d", status)
+	}
+
+	cfg = &config.Config{DefaultTimezone: "UTC", StaticDir: "static"}
+	if status := get(t, newServer(t, cfg).App, "/metrics"); status != http.StatusNotFound {
+		t.Errorf("expected no /metrics when disabled, got %d", status)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	cfg := &config.Config{DefaultTimezone: "UTC", StaticDir: "static", ShutdownReconnect: 5}
+	srv := newServer(t, cfg)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.App.Listener(ln) }()
+
+	conn, _, err := fws.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws/time", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go func() {
+		// Read until the close frame so the handshake completes.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("expected the listener to stop cleanly, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the listener to stop")
+	}
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		t.Error("expected new connections to be refused")
+	}
+}
+
+func TestServerReload(t *testing.T) {
+	cfg := &config.Config{DefaultTimezone: "UTC", StaticDir: "static", AllowedOrigins: []string{"https://old.example.com"}}
+	srv := newServer(t, cfg)
+	reloader := config.NewReloader(cfg, nil)
+	mountAdmin(srv.App, reloader)
+
+	origin := func(o string) string {
+		req, _ := http.NewRequest("GET", "/api/v1/time", nil)
+		req.Header.Set("Origin", o)
+		resp, err := srv.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Header.Get("Access-Control-Allow-Origin")
+	}
+	if got := origin("https://new.example.com"); got != "" {
+		t.Errorf("expected new origin to be refused before reload, got %q", got)
+	}
+
+	next := *cfg
+	next.AllowedOrigins = []string{"https://*.example.com"}
+	next.DefaultTimezone = "Asia/Tokyo"
+	next.CompileOrigins()
+	srv.Reload(&next)
+
+	if got := origin("https://new.example.com"); got != "https://new.example.com" {
+		t.Errorf("expected reloaded origin to be allowed, got %q", got)
+	}
+	req, _ := http.NewRequest("GET", "/api/v1/time", nil)
+	resp, err := srv.App.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "Asia/Tokyo") {
+		t.Errorf("expected the reloaded default timezone, got %s", body)
+	}
+
+	req, _ = http.NewRequest("GET", "/admin/config", nil)
+	resp, err = srv.App.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"config"`) {
+		t.Errorf("expected the admin config endpoint, got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestAuthRoutes(t *testing.T) {
+	cfg := &config.Config{
+		DefaultTimezone: "UTC",
+		StaticDir:       "static",
+		AuthEnabled:     true,
+		AuthAPIKeys: []string{
+			"reader:" + auth.HashAPIKey("read-key") + ":time:read alarms:read",
+			"clock:" + auth.HashAPIKey("time-key") + ":time:read",
+		},
+		AlarmsEnabled: true,
+	}
+	srv := newServer(t, cfg)
+
+	status := func(method, path, key string) int {
+		req, _ := http.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := srv.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	for _, path := range []string{"/health", "/livez", "/readyz"} {
+		// /readyz may answer 503 until the hub has ticked.
+		if got := status("GET", path, ""); got == http.StatusUnauthorized {
+			t.Errorf("expected %s to stay public, got %d", path, got)
+		}
+	}
+	if got := status("GET", "/api/v1/time", ""); got != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", got)
+	}
+	if got := status("GET", "/api/v1/time", "read-key"); got != http.StatusOK {
+		t.Errorf("expected 200 with time:read, got %d", got)
+	}
+	if got := status("GET", "/api/v1/alarms", "read-key"); got != http.StatusOK {
+		t.Errorf("expected 200 with alarms:read, got %d", got)
+	}
+	if got := status("DELETE", "/api/v1/alarms/x", "read-key"); got != http.StatusForbidden {
+		t.Errorf("expected 403 without alarms:write, got %d", got)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.App.Listener(ln)
+	defer srv.App.Shutdown()
+
+	url := "ws://" + ln.Addr().String() + "/ws/time"
+	if _, resp, err := fws.DefaultDialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the upgrade to be refused without a token, got %v", err)
+	}
+	dialer := fws.Dialer{Subprotocols: []string{"json", "bearer.read-key"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("expected the subprotocol token to be accepted, got %v", err)
+	}
+	if conn.Subprotocol() != "json" {
+		t.Errorf("expected the json subprotocol to be selected, got %q", conn.Subprotocol())
+	}
+	conn.Close()
+	conn, _, err = fws.DefaultDialer.Dial(url+"?access_token=read-key", nil)
+	if err != nil {
+		t.Fatalf("expected the query token to be accepted, got %v", err)
+	}
+	if msg := alarmsReply(t, conn); msg.Type != "ack" {
+		t.Errorf("expected the alarms action to be allowed with alarms:read, got %+v", msg)
+	}
+	conn.Close()
+
+	conn, _, err = fws.DefaultDialer.Dial(url+"?access_token=time-key", nil)
+	if err != nil {
+		t.Fatalf("expected the time:read key to connect, got %v", err)
+	}
+	defer conn.Close()
+	msg := alarmsReply(t, conn)
+	if data, _ := msg.Data.(map[string]interface{}); msg.Type != "error" || data["code"] != "forbidden" {
+		t.Errorf("expected the alarms action to be forbidden without alarms:read, got %+v", msg)
+	}
+}
+
+// alarmsReply sends the alarms action and returns the reply to it.
+func alarmsReply(t *testing.T, conn *fws.Conn) models.WebSocketMessage {
+	t.Helper()
+	if err := conn.WriteJSON(models.WebSocketMessage{Action: "alarms", ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		var msg models.WebSocketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		if msg.Action == "alarms" {
+			return msg
+		}
+	}
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	cfg := &config.Config{
+		DefaultTimezone:  "UTC",
+		StaticDir:        "static",
+		RateLimitEnabled: true,
+		RateLimitKey:     "ip",
+		RateLimits:       map[string]config.RateLimit{"api": {Requests: 1, Period: time.Minute, Burst: 2}},
+	}
+	srv := newServer(t, cfg)
+
+	get := func(path string) *http.Response {
+		req, _ := http.NewRequest("GET", path, nil)
+		resp, err := srv.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	for i := 0; i < 2; i++ {
+		if resp := get("/api/v1/time"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 within the burst, got %d", resp.StatusCode)
+		}
+	}
+	resp := get("/api/v1/timezones")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After across the api group, got %d", resp.StatusCode)
+	}
+	if resp := get("/health"); resp.StatusCode == http.StatusTooManyRequests {
+		t.Error("expected /health not to be limited")
+	}
+
+	next := *cfg
+	next.RateLimits = nil
+	srv.Reload(&next)
+	if resp := get("/api/v1/time"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a reload without limits to lift them, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminAppAuth(t *testing.T) {
+	cfg := &config.Config{
+		DefaultTimezone: "UTC",
+		StaticDir:       "static",
+		MetricsPort:     "9090",
+		AuthEnabled:     true,
+		AuthAPIKeys: []string{
+			"reader:" + auth.HashAPIKey("read-key") + ":time:read",
+			"ops:" + auth.HashAPIKey("admin-key") + ":admin",
+		},
+	}
+	srv := newServer(t, cfg)
+	admin := SetupAdmin(cfg)
+	srv.MountAdmin(admin, config.NewReloader(cfg, func() (*config.Config, error) { return cfg, nil }))
+	// LocalOnly needs a loopback peer, which app.Test does not provide.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go admin.Listener(ln)
+	defer admin.Shutdown()
+
+	for key, want := range map[string]int{
+		"":          http.StatusUnauthorized,
+		"read-key":  http.StatusForbidden,
+		"admin-key": http.StatusOK,
+	} {
+		req, _ := http.NewRequest("GET", "http://"+ln.Addr().String()+"/admin/config", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("key %q: expected %d from the admin app, got %d", key, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestAuthFailureLimit(t *testing.T) {
+	cfg := &config.Config{
+		DefaultTimezone:  "UTC",
+		StaticDir:        "static",
+		AuthEnabled:      true,
+		AuthAPIKeys:      []string{"reader:" + auth.HashAPIKey("read-key") + ":time:read"},
+		RateLimitEnabled: true,
+		RateLimitKey:     "credential",
+		RateLimits:       map[string]config.RateLimit{"api": {Requests: 1, Period: time.Minute, Burst: 2}},
+	}
+	srv := newServer(t, cfg)
+
+	get := func(key string) int {
+		req, _ := http.NewRequest("GET", "/api/v1/time", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := srv.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	for i := 0; i < 2; i++ {
+		if got := get("guess"); got != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for a bad key, got %d", got)
+		}
+	}
+	if got := get("guess"); got != http.StatusTooManyRequests {
+		t.Errorf("expected bad keys to be limited by IP, got %d", got)
+	}
+}
+
+func TestAlarmsDisabled(t *testing.T) {
+	srv := newServer(t, &config.Config{DefaultTimezone: "UTC", StaticDir: "static"})
+	req, _ := http.NewRequest("GET", "/api/v1/alarms", nil)
+	resp, err := srv.App.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected no alarm routes with alarms disabled, got %d", resp.StatusCode)
+	}
+	next := &config.Config{DefaultTimezone: "Asia/Tokyo"}
+	srv.Reload(next)
+}
@@ -62,7 +62,10 @@ fi
 
 echo "[3/5] Building binary natively..."
 mkdir -p build
-go build -v -o "build/$BINARY_NAME" main.go
+LDFLAGS="-X gotimedate/buildinfo.Version=$(git describe --tags --always --dirty)"
+LDFLAGS="$LDFLAGS -X gotimedate/buildinfo.Commit=$(git rev-parse --short HEAD)"
+LDFLAGS="$LDFLAGS -X gotimedate/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+go build -v -ldflags "$LDFLAGS" -o "build/$BINARY_NAME" .
 chmod +x "build/$BINARY_NAME"
 
 if ! id -u "$USER_NAME" >/dev/null 2>&1; then
@@ -108,5 +111,5 @@ echo "Access the API at: http://localhost:8080"
 echo "Web Interface: http://localhost:8080"
 echo "Documentation: http://localhost:8080/swagger/index.html"
 echo ""
-echo "Configuration: $INSTALL_DIR/config.env"
+echo "Configuration: $INSTALL_DIR/build/config.yaml (print the defaults with: $INSTALL_DIR/build/$BINARY_NAME --print-config)"
 echo "Logs: $INSTALL_DIR/server.log"
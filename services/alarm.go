@@ -0,0 +1,430 @@
+package services
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"gotimedate/models"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAlarmNotFound = errors.New("alarm not found")
+	ErrInvalidAlarm  = errors.New("invalid alarm")
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// alarmSchedule is the validated form of an AlarmRequest.
+type alarmSchedule struct {
+	loc                  *time.Location
+	hour, minute, second int
+	date                 time.Time // zero for repeating alarms
+	days                 [7]bool
+}
+
+func invalidAlarm(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidAlarm, fmt.Sprintf(format, args...))
+}
+
+func parseAlarmSchedule(req *models.AlarmRequest) (alarmSchedule, error) {
+	var s alarmSchedule
+	if req.Timezone == "" {
+		return s, invalidAlarm("timezone is required")
+	}
+	loc, err := LoadLocation(req.Timezone)
+	if err != nil {
+		return s, invalidAlarm("invalid timezone: %s", req.Timezone)
+	}
+	s.loc = loc
+
+	clock, err := time.Parse("15:04:05", req.Time)
+	if err != nil {
+		clock, err = time.Parse("15:04", req.Time)
+	}
+	if err != nil {
+		return s, invalidAlarm("invalid time: %q (expected HH:MM or HH:MM:SS)", req.Time)
+	}
+	s.hour, s.minute, s.second = clock.Clock()
+
+	if req.Date != "" {
+		if len(req.Days) > 0 {
+			return s, invalidAlarm("date and days are mutually exclusive")
+		}
+		d, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			return s, invalidAlarm("invalid date: %q (expected YYYY-MM-DD)", req.Date)
+		}
+		s.date = d
+	}
+
+	if len(req.Days) == 0 {
+		s.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, day := range req.Days {
+		switch d := strings.ToLower(day); d {
+		case "weekdays":
+			for wd := time.Monday; wd <= time.Friday; wd++ {
+				s.days[wd] = true
+			}
+		case "weekends":
+			s.days[time.Saturday], s.days[time.Sunday] = true, true
+		default:
+			wd, ok := weekdayNames[d]
+			if !ok && len(d) > 3 {
+				// Full names such as "monday".
+				wd, ok = weekdayNames[d[:3]]
+				ok = ok && d == strings.ToLower(wd.String())
+			}
+			if !ok {
+				return s, invalidAlarm("invalid day: %q", day)
+			}
+			s.days[wd] = true
+		}
+	}
+
+	if req.WebhookURL != "" {
+		u, err := url.Parse(req.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return s, invalidAlarm("invalid webhook_url: %s", req.WebhookURL)
+		}
+	}
+	return s, nil
+}
+
+// at returns the instant of the alarm's wall-clock time on the given day. A
+// time skipped by a spring-forward gap is read with the offset in force before
+// the gap, as cron and iCalendar do, so 02:30 becomes 03:30 rather than the
+// 01:30 time.Date would give. A time repeated by a fall-back resolves to its
+// first occurrence.
+func (s *alarmSchedule) at(y int, m time.Month, d int) time.Time {
+	at := time.Date(y, m, d, s.hour, s.minute, s.second, 0, s.loc)
+	if h, min, _ := at.Clock(); h != s.hour || min != s.minute {
+		_, before := at.Zone()
+		_, after := at.Add(3 * time.Hour).Zone()
+		at = at.Add(time.Duration(after-before) * time.Second)
+	}
+	return at
+}
+
+// next returns the first occurrence strictly after t. Occurrences are built
+// from the wall clock of each calendar day rather than by adding 24 hours, so
+// they stay at the same local time across DST changes.
+func (s *alarmSchedule) next(t time.Time) (time.Time, bool) {
+	if !s.date.IsZero() {
+		at := s.at(s.date.Year(), s.date.Month(), s.date.Day())
+		return at, at.After(t)
+	}
+	local := t.In(s.loc)
+	for i := 0; i <= 7; i++ {
+		y, m, d := local.Year(), local.Month(), local.Day()+i
+		// Take the weekday at noon, which no DST transition moves off the day.
+		if !s.days[time.Date(y, m, d, 12, 0, 0, 0, s.loc).Weekday()] {
+			continue
+		}
+		if at := s.at(y, m, d); at.After(t) {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+type alarmEntry struct {
+	alarm models.Alarm
+	sched alarmSchedule
+	next  time.Time
+	index int // position in the queue, -1 when not scheduled
+}
+
+// alarmQueue is a min-heap of entries ordered by their next occurrence.
+type alarmQueue []*alarmEntry
+
+func (q alarmQueue) Len() int           { return len(q) }
+func (q alarmQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+func (q alarmQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *alarmQueue) Push(x interface{}) {
+	e := x.(*alarmEntry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+func (q *alarmQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*q = old[:len(old)-1]
+	return e
+}
+
+// AlarmService stores alarms and fires them from a min-heap drained by a
+// single timer armed for the earliest occurrence. Every change is written
+// through to the store.
+type AlarmService struct {
+	mu        sync.Mutex
+	store     AlarmStore
+	alarms    map[string]*alarmEntry
+	queue     alarmQueue
+	timer     *time.Timer
+	closed    bool
+	listeners []func(models.Alarm, models.AlarmEvent)
+	now       func() time.Time
+}
+
+// NewAlarmService loads the stored alarms and schedules their next
+// occurrences. Occurrences missed while the service was down are skipped.
+func NewAlarmService(store AlarmStore) (*AlarmService, error) {
+	s := &AlarmService{store: store, alarms: make(map[string]*alarmEntry), now: time.Now}
+	stored, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, a := range stored {
+		sched, err := parseAlarmSchedule(&a.AlarmRequest)
+		if err != nil {
+			return nil, fmt.Errorf("stored alarm %s: %w", a.ID, err)
+		}
+		e := &alarmEntry{alarm: a, sched: sched, index: -1}
+		s.alarms[a.ID] = e
+		s.schedule(e, now)
+	}
+	s.arm()
+	return s, nil
+}
+
+// OnFire registers fn to be called, outside the service lock, for every
+// alarm that fires.
+func (s *AlarmService) OnFire(fn func(models.Alarm, models.AlarmEvent)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+}
+
+// Close stops the scheduler.
+func (s *AlarmService) Close() {
+	s.mu.Lock()
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.mu.Unlock()
+}
+
+// List returns all alarms ordered by creation time.
+func (s *AlarmService) List() []models.Alarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+func (s *AlarmService) Get(id string) (*models.Alarm, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.alarms[id]
+	if !ok {
+		return nil, ErrAlarmNotFound
+	}
+	a := e.alarm
+	return &a, nil
+}
+
+func (s *AlarmService) Create(req *models.AlarmRequest) (*models.Alarm, error) {
+	sched, err := parseAlarmSchedule(req)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e := &alarmEntry{
+		alarm: models.Alarm{
+			ID:           uuid.NewString(),
+			AlarmRequest: *req,
+			CreatedAt:    now.UTC().Format(time.RFC3339Nano),
+		},
+		sched: sched,
+		index: -1,
+	}
+	if !s.schedule(e, now) {
+		return nil, invalidAlarm("date %s %s is in the past", req.Date, req.Time)
+	}
+	s.alarms[e.alarm.ID] = e
+	if err := s.persist(); err != nil {
+		s.unschedule(e)
+		delete(s.alarms, e.alarm.ID)
+		return nil, err
+	}
+	s.arm()
+	a := e.alarm
+	return &a, nil
+}
+
+// Update replaces the alarm's settings, keeping its ID and creation time.
+func (s *AlarmService) Update(id string, req *models.AlarmRequest) (*models.Alarm, error) {
+	sched, err := parseAlarmSchedule(req)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.alarms[id]
+	if !ok {
+		return nil, ErrAlarmNotFound
+	}
+	prev := *e
+	s.unschedule(e)
+	e.alarm.AlarmRequest = *req
+	e.sched = sched
+	if !s.schedule(e, s.now()) {
+		s.restore(e, prev)
+		return nil, invalidAlarm("date %s %s is in the past", req.Date, req.Time)
+	}
+	if err := s.persist(); err != nil {
+		s.restore(e, prev)
+		return nil, err
+	}
+	s.arm()
+	a := e.alarm
+	return &a, nil
+}
+
+func (s *AlarmService) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.alarms[id]
+	if !ok {
+		return ErrAlarmNotFound
+	}
+	s.unschedule(e)
+	delete(s.alarms, id)
+	if err := s.persist(); err != nil {
+		s.alarms[id] = e
+		s.schedule(e, s.now())
+		return err
+	}
+	s.arm()
+	return nil
+}
+
+// restore puts e back to its state before a failed update.
+func (s *AlarmService) restore(e *alarmEntry, prev alarmEntry) {
+	s.unschedule(e)
+	e.alarm, e.sched = prev.alarm, prev.sched
+	s.schedule(e, s.now())
+}
+
+// schedule queues e for its next occurrence after now and reports whether
+// there is one. Must be called with s.mu held.
+func (s *AlarmService) schedule(e *alarmEntry, now time.Time) bool {
+	next, ok := e.sched.next(now)
+	if !ok {
+		e.alarm.NextFire = ""
+		return false
+	}
+	e.next = next
+	e.alarm.NextFire = next.Format(time.RFC3339)
+	heap.Push(&s.queue, e)
+	return true
+}
+
+// unschedule must be called with s.mu held.
+func (s *AlarmService) unschedule(e *alarmEntry) {
+	if e.index >= 0 {
+		heap.Remove(&s.queue, e.index)
+	}
+}
+
+// arm resets the timer to the earliest queued occurrence. Must be called with
+// s.mu held.
+func (s *AlarmService) arm() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.closed || len(s.queue) == 0 {
+		return
+	}
+	s.timer = time.AfterFunc(s.queue[0].next.Sub(s.now()), s.fire)
+}
+
+// fire pops every due alarm, reschedules repeating ones and notifies the
+// listeners. A timer that fires early because the wall clock moved simply
+// re-arms.
+func (s *AlarmService) fire() {
+	s.mu.Lock()
+	now := s.now()
+	var fired []models.Alarm
+	var events []models.AlarmEvent
+	for len(s.queue) > 0 && !s.queue[0].next.After(now) {
+		e := heap.Pop(&s.queue).(*alarmEntry)
+		scheduled := e.next
+		e.alarm.LastFired = scheduled.Format(time.RFC3339)
+		// Reschedule after now, not after the occurrence, so a long stall
+		// fires a repeating alarm once instead of replaying every miss.
+		after := scheduled
+		if now.After(after) {
+			after = now
+		}
+		s.schedule(e, after)
+		fired = append(fired, e.alarm)
+		events = append(events, models.AlarmEvent{
+			AlarmID:      e.alarm.ID,
+			Name:         e.alarm.Name,
+			Message:      e.alarm.Message,
+			Timezone:     e.alarm.Timezone,
+			ScheduledFor: scheduled.Format(time.RFC3339),
+			FiredAt:      now.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	if len(fired) > 0 {
+		if err := s.persist(); err != nil {
+			slog.Error("Failed to persist alarms", "error", err)
+		}
+	}
+	s.arm()
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	for i := range fired {
+		for _, fn := range listeners {
+			fn(fired[i], events[i])
+		}
+	}
+}
+
+// snapshot must be called with s.mu held.
+func (s *AlarmService) snapshot() []models.Alarm {
+	result := make([]models.Alarm, 0, len(s.alarms))
+	for _, e := range s.alarms {
+		result = append(result, e.alarm)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ci, _ := time.Parse(time.RFC3339Nano, result[i].CreatedAt)
+		cj, _ := time.Parse(time.RFC3339Nano, result[j].CreatedAt)
+		if !ci.Equal(cj) {
+			return ci.Before(cj)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// persist must be called with s.mu held.
+func (s *AlarmService) persist() error {
+	return s.store.Save(s.snapshot())
+}
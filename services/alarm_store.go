@@ -0,0 +1,73 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"gotimedate/models"
+	"os"
+	"path/filepath"
+)
+
+// AlarmStore persists the full set of alarms.
+type AlarmStore interface {
+	Load() ([]models.Alarm, error)
+	Save([]models.Alarm) error
+}
+
+// FileAlarmStore keeps alarms in a JSON file. An empty path keeps them in
+// memory only.
+type FileAlarmStore struct {
+	path string
+}
+
+func NewFileAlarmStore(path string) *FileAlarmStore {
+	return &FileAlarmStore{path: path}
+}
+
+// Load returns the stored alarms; a missing file is an empty store.
+func (s *FileAlarmStore) Load() ([]models.Alarm, error) {
+	if s.path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var alarms []models.Alarm
+	if err := json.Unmarshal(data, &alarms); err != nil {
+		return nil, err
+	}
+	return alarms, nil
+}
+
+// Save writes to a temporary file and renames it over the store, so a crash
+// mid-write never leaves a truncated file behind.
+func (s *FileAlarmStore) Save(alarms []models.Alarm) error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(alarms, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"gotimedate/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mustSchedule(t *testing.T, req models.AlarmRequest) alarmSchedule {
+	t.Helper()
+	s, err := parseAlarmSchedule(&req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestAlarmScheduleNext(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	kl, _ := time.LoadLocation("Asia/Kuala_Lumpur")
+
+	tests := []struct {
+		name string
+		req  models.AlarmRequest
+		from time.Time
+		want time.Time
+	}{
+		{
+			"weekday skips weekend",
+			models.AlarmRequest{Time: "09:00", Timezone: "Asia/Kuala_Lumpur", Days: []string{"weekdays"}},
+			time.Date(2024, 1, 5, 9, 0, 0, 0, kl), // Friday, exactly at the alarm
+			time.Date(2024, 1, 8, 9, 0, 0, 0, kl),
+		},
+		{
+			"later today",
+			models.AlarmRequest{Time: "09:00:30", Timezone: "Asia/Kuala_Lumpur"},
+			time.Date(2024, 1, 5, 8, 0, 0, 0, kl),
+			time.Date(2024, 1, 5, 9, 0, 30, 0, kl),
+		},
+		{
+			"named days",
+			models.AlarmRequest{Time: "18:00", Timezone: "Asia/Kuala_Lumpur", Days: []string{"Sunday", "wed"}},
+			time.Date(2024, 1, 4, 19, 0, 0, 0, kl), // Thursday
+			time.Date(2024, 1, 7, 18, 0, 0, 0, kl),
+		},
+		{
+			"keeps wall clock across spring forward",
+			models.AlarmRequest{Time: "09:00", Timezone: "America/New_York"},
+			time.Date(2024, 3, 9, 10, 0, 0, 0, ny),
+			time.Date(2024, 3, 10, 9, 0, 0, 0, ny),
+		},
+		{
+			"skipped time fires after the gap",
+			models.AlarmRequest{Time: "02:30", Timezone: "America/New_York"},
+			time.Date(2024, 3, 10, 0, 0, 0, 0, ny),
+			time.Date(2024, 3, 10, 3, 30, 0, 0, ny),
+		},
+		{
+			"one-shot date",
+			models.AlarmRequest{Time: "12:00", Timezone: "Asia/Kuala_Lumpur", Date: "2024-02-01"},
+			time.Date(2024, 1, 5, 0, 0, 0, 0, kl),
+			time.Date(2024, 2, 1, 12, 0, 0, 0, kl),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mustSchedule(t, tt.req)
+			got, ok := s.next(tt.from)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("expected %s, got %s (ok=%v)", tt.want, got, ok)
+			}
+		})
+	}
+}
+
+func TestAlarmScheduleFallBackFiresOnce(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	s := mustSchedule(t, models.AlarmRequest{Time: "01:30", Timezone: "America/New_York"})
+
+	first, _ := s.next(time.Date(2024, 11, 3, 0, 0, 0, 0, ny))
+	if _, offset := first.Zone(); offset != -4*3600 {
+		t.Errorf("expected first occurrence in EDT, got offset %d", offset)
+	}
+	second, _ := s.next(first)
+	want := time.Date(2024, 11, 4, 1, 30, 0, 0, ny)
+	if !second.Equal(want) {
+		t.Errorf("expected next day %s, got %s", want, second)
+	}
+}
+
+func TestAlarmScheduleOneShotPast(t *testing.T) {
+	s := mustSchedule(t, models.AlarmRequest{Time: "12:00", Timezone: "UTC", Date: "2024-01-01"})
+	if _, ok := s.next(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)); ok {
+		t.Error("expected no occurrence after the date")
+	}
+}
+
+func TestAlarmValidation(t *testing.T) {
+	tests := []models.AlarmRequest{
+		{Time: "09:00"},
+		{Time: "09:00", Timezone: "Mars/Olympus"},
+		{Time: "9am", Timezone: "UTC"},
+		{Time: "25:00", Timezone: "UTC"},
+		{Time: "09:00", Timezone: "UTC", Days: []string{"someday"}},
+		{Time: "09:00", Timezone: "UTC", Days: []string{"monxyz"}},
+		{Time: "09:00", Timezone: "UTC", Date: "05/01/2024"},
+		{Time: "09:00", Timezone: "UTC", Date: "2024-01-05", Days: []string{"mon"}},
+		{Time: "09:00", Timezone: "UTC", WebhookURL: "ftp://example.com"},
+	}
+	for _, req := range tests {
+		if _, err := parseAlarmSchedule(&req); !errors.Is(err, ErrInvalidAlarm) {
+			t.Errorf("expected ErrInvalidAlarm for %+v, got %v", req, err)
+		}
+	}
+}
+
+func TestAlarmServiceCRUD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.json")
+	svc, err := NewAlarmService(NewFileAlarmStore(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer svc.Close()
+
+	a, err := svc.Create(&models.AlarmRequest{Name: "standup", Time: "09:00", Timezone: "Asia/Kuala_Lumpur", Days: []string{"weekdays"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.ID == "" || a.NextFire == "" {
+		t.Errorf("expected ID and next fire, got %+v", a)
+	}
+	if _, err := svc.Create(&models.AlarmRequest{Time: "09:00", Timezone: "UTC", Date: "2001-01-01"}); !errors.Is(err, ErrInvalidAlarm) {
+		t.Errorf("expected past date to be rejected, got %v", err)
+	}
+
+	updated, err := svc.Update(a.ID, &models.AlarmRequest{Name: "lunch", Time: "12:30", Timezone: "UTC"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Name != "lunch" || updated.CreatedAt != a.CreatedAt {
+		t.Errorf("expected update to keep creation time, got %+v", updated)
+	}
+
+	// A new service on the same file sees the persisted alarm.
+	reloaded, err := NewAlarmService(NewFileAlarmStore(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reloaded.Close()
+	got, err := reloaded.Get(a.ID)
+	if err != nil || got.Name != "lunch" {
+		t.Errorf("expected persisted alarm, got %+v, %v", got, err)
+	}
+
+	if err := svc.Delete(a.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Get(a.ID); !errors.Is(err, ErrAlarmNotFound) {
+		t.Errorf("expected ErrAlarmNotFound, got %v", err)
+	}
+	if err := svc.Delete(a.ID); !errors.Is(err, ErrAlarmNotFound) {
+		t.Errorf("expected ErrAlarmNotFound, got %v", err)
+	}
+	if alarms, _ := NewFileAlarmStore(path).Load(); len(alarms) != 0 {
+		t.Errorf("expected empty store, got %d alarms", len(alarms))
+	}
+}
+
+func TestAlarmServiceFires(t *testing.T) {
+	svc, err := NewAlarmService(NewFileAlarmStore(""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer svc.Close()
+	events := make(chan models.AlarmEvent, 1)
+	svc.OnFire(func(_ models.Alarm, ev models.AlarmEvent) { events <- ev })
+
+	at := time.Now().Add(time.Second).Truncate(time.Second).Add(time.Second)
+	a, err := svc.Create(&models.AlarmRequest{Name: "ping", Time: at.UTC().Format("15:04:05"), Timezone: "UTC"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.AlarmID != a.ID || ev.ScheduledFor != at.UTC().Format(time.RFC3339) {
+			t.Errorf("unexpected event %+v", ev)
+		}
+		if time.Now().Before(at) {
+			t.Error("expected alarm not to fire early")
+		}
+	case <-time.After(4 * time.Second):
+		t.Fatal("expected alarm to fire")
+	}
+
+	got, _ := svc.Get(a.ID)
+	if got.LastFired != at.UTC().Format(time.RFC3339) {
+		t.Errorf("expected last fired %s, got %s", at.UTC().Format(time.RFC3339), got.LastFired)
+	}
+	next, _ := time.Parse(time.RFC3339, got.NextFire)
+	if !next.Equal(at.Add(24 * time.Hour)) {
+		t.Errorf("expected next fire a day later, got %s", got.NextFire)
+	}
+}
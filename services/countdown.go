@@ -0,0 +1,30 @@
+package services
+
+import (
+	"gotimedate/models"
+	"time"
+)
+
+// Countdown describes the time left from now until target, with the target
+// rendered in timezone. Once target is reached every remaining field is zero.
+func (s *TimeService) Countdown(target, now time.Time, timezone string, opts ...TimeOptions) (*models.CountdownResponse, error) {
+	resp, err := s.TimeAt(target, timezone, opts...)
+	if err != nil {
+		return nil, err
+	}
+	remaining := target.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	// Round up so the display never shows 0s while time is still left.
+	secs := int64((remaining + time.Second - 1) / time.Second)
+	return &models.CountdownResponse{
+		Target:      *resp,
+		Remaining:   (time.Duration(secs) * time.Second).String(),
+		RemainingMs: remaining.Milliseconds(),
+		Days:        int(secs / 86400),
+		Hours:       int(secs % 86400 / 3600),
+		Minutes:     int(secs % 3600 / 60),
+		Seconds:     int(secs % 60),
+	}, nil
+}
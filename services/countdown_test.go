@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountdown(t *testing.T) {
+	service := NewTimeService()
+	target := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		remaining  string
+		ms         int64
+		d, h, m, s int
+	}{
+		{"days", target.Add(-(26*time.Hour + 3*time.Minute + 4*time.Second)), "26h3m4s", 93784000, 1, 2, 3, 4},
+		{"rounds up", target.Add(-1500 * time.Millisecond), "2s", 1500, 0, 0, 0, 2},
+		{"at target", target, "0s", 0, 0, 0, 0, 0},
+		{"past target", target.Add(time.Minute), "0s", 0, 0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := service.Countdown(target, tt.now, "Asia/Tokyo")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Remaining != tt.remaining || resp.RemainingMs != tt.ms {
+				t.Errorf("expected %s (%dms), got %s (%dms)", tt.remaining, tt.ms, resp.Remaining, resp.RemainingMs)
+			}
+			if resp.Days != tt.d || resp.Hours != tt.h || resp.Minutes != tt.m || resp.Seconds != tt.s {
+				t.Errorf("expected %dd %dh %dm %ds, got %dd %dh %dm %ds", tt.d, tt.h, tt.m, tt.s, resp.Days, resp.Hours, resp.Minutes, resp.Seconds)
+			}
+			if resp.Target.Timestamp != "2024-01-05T09:00:00+09:00" {
+				t.Errorf("expected target in Tokyo time, got %s", resp.Target.Timestamp)
+			}
+		})
+	}
+
+	if _, err := service.Countdown(target, target, "Mars/Olympus"); err == nil {
+		t.Error("expected error for invalid timezone")
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// locations caches loaded zones by name. time.LoadLocation reads and parses
+// tzdata on every call, which is wasteful on per-request and per-tick paths.
+// Only successful loads are cached so bad input cannot grow the cache.
+var (
+	locations      sync.Map
+	locationHits   atomic.Uint64
+	locationMisses atomic.Uint64
+)
+
+// LoadLocation is time.LoadLocation backed by a process-wide cache.
+func LoadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		locationHits.Add(1)
+		return loc.(*time.Location), nil
+	}
+	locationMisses.Add(1)
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// LocationCacheStats returns the cache hit and miss counts.
+func LocationCacheStats() (hits, misses uint64) {
+	return locationHits.Load(), locationMisses.Load()
+}
+
+// CheckTZData loads every zone the API lists straight from tzdata, bypassing
+// the cache, and returns how many there are.
+func CheckTZData() (int, error) {
+	for _, name := range availableZones {
+		if _, err := time.LoadLocation(name); err != nil {
+			return 0, err
+		}
+	}
+	return len(availableZones), nil
+}
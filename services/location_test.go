@@ -0,0 +1,39 @@
+package services
+
+import "testing"
+
+func TestLoadLocationCache(t *testing.T) {
+	// Start uncached so that the test also passes with -count above one.
+	locations.Delete("Pacific/Chatham")
+	hits, misses := LocationCacheStats()
+
+	first, err := LoadLocation("Pacific/Chatham")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := LoadLocation("Pacific/Chatham")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second {
+		t.Error("expected the cached location to be reused")
+	}
+	if _, err := LoadLocation("Not/AZone"); err == nil {
+		t.Error("expected an error for an unknown zone")
+	}
+
+	h, m := LocationCacheStats()
+	if h-hits != 1 || m-misses != 2 {
+		t.Errorf("expected 1 hit and 2 misses, got %d and %d", h-hits, m-misses)
+	}
+}
+
+func TestCheckTZData(t *testing.T) {
+	n, err := CheckTZData()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != len(availableZones) {
+		t.Errorf("expected %d zones, got %d", len(availableZones), n)
+	}
+}
@@ -0,0 +1,638 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gotimedate/models"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrNoDateFound is returned by ParseNatural when the text contains no
+// recognizable date or time expression.
+var ErrNoDateFound = errors.New("no date or time expression found")
+
+type token struct {
+	text       string
+	start, end int
+}
+
+// tokenize splits text into lowercase words, keeping byte offsets into the
+// original string so matches can be reported as spans.
+func tokenize(text string) []token {
+	var toks []token
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := strings.TrimRight(text[start:end], ".!?")
+		if word != "" {
+			toks = append(toks, token{text: strings.ToLower(word), start: start, end: start + len(word)})
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsSpace(r) || r == ',' || r == ';' {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	flush(len(text))
+	return toks
+}
+
+var (
+	clockPattern   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm|a\.m|p\.m)?$`)
+	isoDatePattern = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+)
+
+var numberWords = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+var unitWords = map[string]string{
+	"s": "second", "sec": "second", "secs": "second", "second": "second", "seconds": "second",
+	"min": "minute", "mins": "minute", "minute": "minute", "minutes": "minute",
+	"h": "hour", "hr": "hour", "hrs": "hour", "hour": "hour", "hours": "hour",
+	"d": "day", "day": "day", "days": "day",
+	"w": "week", "wk": "week", "wks": "week", "week": "week", "weeks": "week",
+	"mo": "month", "month": "month", "months": "month",
+	"y": "year", "yr": "year", "yrs": "year", "year": "year", "years": "year",
+}
+
+// maxRelative bounds the count of each unit in a relative offset, keeping
+// clock offsets within a time.Duration and dates within 10000 years.
+var maxRelative = map[string]int{
+	"second": 250 * 365 * 24 * 60 * 60,
+	"minute": 250 * 365 * 24 * 60,
+	"hour":   250 * 365 * 24,
+	"day":    10000 * 366,
+	"week":   10000 * 53,
+	"month":  10000 * 12,
+	"year":   10000,
+}
+
+var weekdayWords = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var dayPartWords = map[string]int{
+	"morning": 9, "afternoon": 15, "evening": 18, "night": 21,
+}
+
+// zoneWords maps lowercase city names and IANA names from availableZones to
+// their IANA name, so "in Tokyo" and "in asia/tokyo" both resolve.
+var zoneWords = func() map[string]string {
+	m := map[string]string{"gmt": "UTC"}
+	for _, zone := range availableZones {
+		m[strings.ToLower(zone)] = zone
+		city := zone[strings.LastIndex(zone, "/")+1:]
+		m[strings.ToLower(strings.ReplaceAll(city, "_", " "))] = zone
+	}
+	return m
+}()
+
+// expression collects the components recognized in a phrase before they are
+// resolved against a reference instant.
+type expression struct {
+	start, end int
+
+	relN    int
+	relUnit string
+
+	hasDay    bool
+	dayOffset int
+
+	hasWeekday  bool
+	weekday     time.Weekday
+	weekdayMode string
+
+	periodEdge  string
+	periodUnit  string
+	periodShift int
+
+	hasDate         bool
+	year, month, dy int
+
+	hasClock     bool
+	impliedClock bool
+	hour, minute int
+
+	zone      string
+	ambiguous bool
+	temporal  bool
+}
+
+type componentParser func(e *expression, toks []token, i int) int
+
+var componentParsers = []componentParser{
+	(*expression).parseRelative,
+	(*expression).parseShift,
+	(*expression).parseDayWord,
+	(*expression).parseWeekday,
+	(*expression).parsePeriod,
+	(*expression).parseISODate,
+	(*expression).parseClock,
+	(*expression).parseZone,
+}
+
+func parseFrom(toks []token, i int) (expression, bool) {
+	e := expression{start: i, end: i}
+	j := i
+	for j < len(toks) {
+		n := 0
+		for _, p := range componentParsers {
+			if n = p(&e, toks, j); n > 0 {
+				break
+			}
+		}
+		if n == 0 {
+			break
+		}
+		j += n
+		e.end = j
+	}
+	return e, e.temporal
+}
+
+func word(toks []token, i int) string {
+	if i < 0 || i >= len(toks) {
+		return ""
+	}
+	return toks[i].text
+}
+
+func parseCount(s string) (int, bool) {
+	if n, ok := numberWords[s]; ok {
+		return n, true
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n >= 0
+}
+
+// parseRelative handles "in 2 weeks", "3 days ago" and "5 minutes from now".
+func (e *expression) parseRelative(toks []token, i int) int {
+	if e.relUnit != "" {
+		return 0
+	}
+	if word(toks, i) == "in" {
+		n, ok := parseCount(word(toks, i+1))
+		unit, isUnit := unitWords[word(toks, i+2)]
+		if ok && isUnit && n <= maxRelative[unit] {
+			e.relN, e.relUnit, e.temporal = n, unit, true
+			return 3
+		}
+		return 0
+	}
+	n, ok := parseCount(word(toks, i))
+	unit, isUnit := unitWords[word(toks, i+1)]
+	if !ok || !isUnit || n > maxRelative[unit] {
+		return 0
+	}
+	switch word(toks, i+2) {
+	case "ago":
+		e.relN, e.relUnit, e.temporal = -n, unit, true
+		return 3
+	case "later":
+		e.relN, e.relUnit, e.temporal = n, unit, true
+		return 3
+	case "from":
+		if word(toks, i+3) == "now" {
+			e.relN, e.relUnit, e.temporal = n, unit, true
+			return 4
+		}
+	}
+	return 0
+}
+
+// parseShift handles "next week", "last month" and "this year".
+func (e *expression) parseShift(toks []token, i int) int {
+	if e.relUnit != "" {
+		return 0
+	}
+	shift, ok := map[string]int{"next": 1, "last": -1, "this": 0}[word(toks, i)]
+	unit := unitWords[word(toks, i+1)]
+	if !ok || (unit != "week" && unit != "month" && unit != "year") {
+		return 0
+	}
+	e.relN, e.relUnit, e.temporal = shift, unit, true
+	return 2
+}
+
+// parseDayWord handles today, tomorrow, yesterday, tonight and
+// "the day after tomorrow".
+func (e *expression) parseDayWord(toks []token, i int) int {
+	if e.hasDay || e.hasWeekday || e.hasDate {
+		return 0
+	}
+	j := i
+	if word(toks, j) == "the" {
+		j++
+	}
+	if word(toks, j) == "day" && (word(toks, j+1) == "after" || word(toks, j+1) == "before") {
+		offset := map[string]int{"tomorrow": 2, "yesterday": -2}[word(toks, j+2)]
+		if (offset > 0) != (word(toks, j+1) == "after") || offset == 0 {
+			return 0
+		}
+		e.hasDay, e.dayOffset, e.temporal = true, offset, true
+		return j + 3 - i
+	}
+	if j != i {
+		return 0
+	}
+	switch word(toks, i) {
+	case "today":
+		e.hasDay, e.dayOffset = true, 0
+	case "tomorrow":
+		e.hasDay, e.dayOffset = true, 1
+	case "yesterday":
+		e.hasDay, e.dayOffset = true, -1
+	case "tonight":
+		e.hasDay, e.dayOffset = true, 0
+		if !e.hasClock {
+			e.hasClock, e.impliedClock, e.hour, e.minute = true, true, 20, 0
+		}
+	default:
+		return 0
+	}
+	e.temporal = true
+	return 1
+}
+
+// parseWeekday handles "friday", "on friday", "next friday" and
+// "last monday".
+func (e *expression) parseWeekday(toks []token, i int) int {
+	if e.hasWeekday || e.hasDay || e.hasDate {
+		return 0
+	}
+	j := i
+	if word(toks, j) == "on" {
+		j++
+	}
+	mode := ""
+	switch word(toks, j) {
+	case "this", "next", "last", "coming":
+		mode = word(toks, j)
+		j++
+	}
+	wd, ok := weekdayWords[word(toks, j)]
+	if !ok {
+		return 0
+	}
+	if mode == "coming" {
+		mode = "next"
+	}
+	e.hasWeekday, e.weekday, e.weekdayMode, e.temporal = true, wd, mode, true
+	return j + 1 - i
+}
+
+// parsePeriod handles "end of month", "start of next week" and
+// "beginning of the year".
+func (e *expression) parsePeriod(toks []token, i int) int {
+	if e.periodUnit != "" {
+		return 0
+	}
+	edge := word(toks, i)
+	if edge == "beginning" {
+		edge = "start"
+	}
+	if (edge != "start" && edge != "end") || word(toks, i+1) != "of" {
+		return 0
+	}
+	j := i + 2
+	shift := 0
+	switch word(toks, j) {
+	case "the", "this":
+		j++
+	case "next":
+		shift = 1
+		j++
+	case "last":
+		shift = -1
+		j++
+	}
+	unit := unitWords[word(toks, j)]
+	if unit != "day" && unit != "week" && unit != "month" && unit != "year" {
+		return 0
+	}
+	e.periodEdge, e.periodUnit, e.periodShift, e.temporal = edge, unit, shift, true
+	return j + 1 - i
+}
+
+func (e *expression) parseISODate(toks []token, i int) int {
+	if e.hasDate || e.hasDay || e.hasWeekday {
+		return 0
+	}
+	j := i
+	if word(toks, j) == "on" {
+		j++
+	}
+	m := isoDatePattern.FindStringSubmatch(word(toks, j))
+	if m == nil {
+		return 0
+	}
+	y, _ := strconv.Atoi(m[1])
+	mo, _ := strconv.Atoi(m[2])
+	d, _ := strconv.Atoi(m[3])
+	// Day 0 of the next month is the last day of this one.
+	if mo < 1 || mo > 12 || d < 1 || d > time.Date(y, time.Month(mo)+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+		return 0
+	}
+	e.hasDate, e.year, e.month, e.dy, e.temporal = true, y, mo, d, true
+	return j + 1 - i
+}
+
+// parseClock handles "3pm", "at 3:30 pm", "15:00", "noon", "midnight" and
+// parts of the day such as "morning".
+func (e *expression) parseClock(toks []token, i int) int {
+	if e.hasClock && !e.impliedClock {
+		return 0
+	}
+	j := i
+	hasAt := false
+	if word(toks, j) == "at" {
+		hasAt = true
+		j++
+	}
+	w := word(toks, j)
+	switch w {
+	case "noon", "midday":
+		e.setClock(12, 0)
+		return j + 1 - i
+	case "midnight":
+		e.setClock(0, 0)
+		e.ambiguous = true
+		return j + 1 - i
+	}
+	if h, ok := dayPartWords[w]; ok && !hasAt && !e.hasClock {
+		e.setClock(h, 0)
+		e.impliedClock = true
+		return j + 1 - i
+	}
+	m := clockPattern.FindStringSubmatch(w)
+	if m == nil {
+		return 0
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	meridiem := m[3]
+	consumed := j + 1 - i
+	if meridiem == "" {
+		switch next := word(toks, j+1); next {
+		case "am", "pm", "a.m", "p.m":
+			meridiem = next
+			consumed++
+		}
+	}
+	if meridiem == "" && m[2] == "" && !hasAt {
+		return 0
+	}
+	if minute > 59 || hour > 23 || (meridiem != "" && (hour < 1 || hour > 12)) {
+		return 0
+	}
+	switch strings.TrimSuffix(meridiem, ".m") {
+	case "am", "a":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm", "p":
+		if hour != 12 {
+			hour += 12
+		}
+	default:
+		if m[2] == "" && hour <= 12 {
+			e.ambiguous = true
+		}
+	}
+	e.setClock(hour, minute)
+	return consumed
+}
+
+func (e *expression) setClock(hour, minute int) {
+	e.hasClock, e.impliedClock, e.hour, e.minute, e.temporal = true, false, hour, minute, true
+}
+
+// parseZone handles "in Tokyo", "in New York" and "in Asia/Kuala_Lumpur".
+func (e *expression) parseZone(toks []token, i int) int {
+	if e.zone != "" || word(toks, i) != "in" {
+		return 0
+	}
+	for n := 3; n >= 1; n-- {
+		if i+1+n > len(toks) {
+			continue
+		}
+		parts := make([]string, n)
+		for k := range parts {
+			parts[k] = toks[i+1+k].text
+		}
+		if zone, ok := zoneWords[strings.Join(parts, " ")]; ok {
+			e.zone = zone
+			return n + 1
+		}
+	}
+	return 0
+}
+
+// resolve applies the recognized components to ref in loc.
+func (e *expression) resolve(ref time.Time, loc *time.Location) time.Time {
+	base := ref.In(loc)
+	t := base
+	switch e.relUnit {
+	case "second":
+		t = t.Add(time.Duration(e.relN) * time.Second)
+	case "minute":
+		t = t.Add(time.Duration(e.relN) * time.Minute)
+	case "hour":
+		t = t.Add(time.Duration(e.relN) * time.Hour)
+	case "day":
+		t = t.AddDate(0, 0, e.relN)
+	case "week":
+		t = t.AddDate(0, 0, 7*e.relN)
+	case "month":
+		t = t.AddDate(0, e.relN, 0)
+	case "year":
+		t = t.AddDate(e.relN, 0, 0)
+	}
+
+	y, m, d := t.Date()
+	hour, minute, sec := t.Clock()
+	nsec := t.Nanosecond()
+	dateSet := false
+
+	if e.hasDate {
+		y, m, d = e.year, time.Month(e.month), e.dy
+		dateSet = true
+	}
+	if e.hasDay {
+		d += e.dayOffset
+		dateSet = true
+	}
+	if e.hasWeekday {
+		d += e.weekdayDelta(t.Weekday())
+		dateSet = true
+	}
+	if e.periodUnit != "" {
+		y, m, d = periodBoundary(y, m, d, e.periodEdge, e.periodUnit, e.periodShift)
+		dateSet = true
+		hour, minute, sec, nsec = 0, 0, 0, 0
+		if e.periodEdge == "end" {
+			hour, minute, sec = 23, 59, 59
+		}
+	} else if dateSet {
+		hour, minute, sec, nsec = 0, 0, 0, 0
+	}
+	if e.hasClock {
+		hour, minute, sec, nsec = e.hour, e.minute, 0, 0
+	}
+
+	result := time.Date(y, m, d, hour, minute, sec, nsec, loc)
+	if e.hasClock && !dateSet && e.relUnit == "" && result.Before(base) {
+		result = time.Date(y, m, d+1, hour, minute, sec, nsec, loc)
+	}
+	return result
+}
+
+// weekdayDelta returns the day offset from today to the requested weekday and
+// flags the phrases people commonly disagree on.
+func (e *expression) weekdayDelta(today time.Weekday) int {
+	ahead := (int(e.weekday) - int(today) + 7) % 7
+	// Days remaining in a Monday-based week, including today.
+	left := 6 - (int(today)+6)%7
+	switch e.weekdayMode {
+	case "last":
+		if ahead == 0 {
+			return -7
+		}
+		return ahead - 7
+	case "next":
+		if ahead == 0 {
+			ahead = 7
+		}
+		if ahead <= left {
+			// "next Friday" on a Monday may mean this week's or the following.
+			e.ambiguous = true
+		}
+		return ahead
+	default:
+		if ahead == 0 && e.weekdayMode == "" {
+			e.ambiguous = true
+		}
+		return ahead
+	}
+}
+
+func periodBoundary(y int, m time.Month, d int, edge, unit string, shift int) (int, time.Month, int) {
+	switch unit {
+	case "day":
+		d += shift
+		return y, m, d
+	case "week":
+		t := time.Date(y, m, d+7*shift, 0, 0, 0, 0, time.UTC)
+		monday := t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		if edge == "end" {
+			monday = monday.AddDate(0, 0, 6)
+		}
+		return monday.Date()
+	case "month":
+		m += time.Month(shift)
+		if edge == "end" {
+			return time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Date()
+		}
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC).Date()
+	default:
+		y += shift
+		if edge == "end" {
+			return y, time.December, 31
+		}
+		return y, time.January, 1
+	}
+}
+
+// ParseNatural resolves a natural-language date expression such as
+// "next Friday 3pm" or "tomorrow at noon in Tokyo" relative to reference (or
+// the current time when empty) in timezone. A timezone named in the text
+// overrides the timezone argument.
+func (s *TimeService) ParseNatural(req *models.ParseRequest) (*models.ParseResponse, error) {
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", timezone)
+	}
+	ref := time.Now()
+	if req.Reference != "" {
+		var err error
+		if ref, err = time.Parse(time.RFC3339, req.Reference); err != nil {
+			return nil, fmt.Errorf("invalid reference format: %s", req.Reference)
+		}
+	}
+
+	toks := tokenize(req.Text)
+	var best expression
+	found := false
+	for i := range toks {
+		if e, ok := parseFrom(toks, i); ok && (!found || e.end-e.start > best.end-best.start) {
+			best, found = e, true
+		}
+	}
+	if !found {
+		return nil, ErrNoDateFound
+	}
+
+	if best.zone != "" {
+		timezone = best.zone
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", timezone)
+	}
+	resolved := best.resolve(ref, loc)
+
+	start, end := toks[best.start].start, toks[best.end-1].end
+	covered, total := 0, 0
+	for i, tok := range toks {
+		total += len(tok.text)
+		if i >= best.start && i < best.end {
+			covered += len(tok.text)
+		}
+	}
+	confidence := 0.5 + 0.5*float64(covered)/float64(total)
+	if best.ambiguous {
+		confidence -= 0.25
+	}
+
+	return &models.ParseResponse{
+		Input:    req.Text,
+		Resolved: s.newTimeResponse(resolved, timezone, resolveOptions(nil)),
+		Match: models.ParseMatch{
+			Text:  req.Text[start:end],
+			Start: start,
+			End:   end,
+		},
+		Reference:  ref.In(loc).Format(time.RFC3339),
+		Confidence: math.Round(confidence*100) / 100,
+		Ambiguous:  best.ambiguous,
+	}, nil
+}
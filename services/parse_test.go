@@ -0,0 +1,82 @@
+package services
+
+import (
+	"errors"
+	"gotimedate/models"
+	"testing"
+)
+
+func TestTimeService_ParseNatural(t *testing.T) {
+	s := NewTimeService()
+	// Wednesday, 2026-01-07 10:00 UTC.
+	const reference = "2026-01-07T10:00:00Z"
+
+	tests := []struct {
+		text      string
+		timezone  string
+		want      string
+		wantTZ    string
+		match     string
+		ambiguous bool
+	}{
+		{"next Friday 3pm", "UTC", "2026-01-09T15:00:00Z", "UTC", "next Friday 3pm", true},
+		{"tomorrow at noon in Tokyo", "UTC", "2026-01-08T12:00:00+09:00", "Asia/Tokyo", "tomorrow at noon in Tokyo", false},
+		{"in 2 weeks", "UTC", "2026-01-21T10:00:00Z", "UTC", "in 2 weeks", false},
+		{"end of month", "UTC", "2026-01-31T23:59:59Z", "UTC", "end of month", false},
+		{"3 days ago", "UTC", "2026-01-04T10:00:00Z", "UTC", "3 days ago", false},
+		{"remind me at 9:30 am please", "UTC", "2026-01-08T09:30:00Z", "UTC", "at 9:30 am", false},
+		{"tonight", "Asia/Kuala_Lumpur", "2026-01-07T20:00:00+08:00", "Asia/Kuala_Lumpur", "tonight", false},
+		{"start of next week", "UTC", "2026-01-12T00:00:00Z", "UTC", "start of next week", false},
+		{"last monday", "UTC", "2026-01-05T00:00:00Z", "UTC", "last monday", false},
+		{"wednesday", "UTC", "2026-01-07T00:00:00Z", "UTC", "wednesday", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			resp, err := s.ParseNatural(&models.ParseRequest{Text: tt.text, Reference: reference, Timezone: tt.timezone})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if resp.Resolved.Timestamp != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, resp.Resolved.Timestamp)
+			}
+			if resp.Resolved.Timezone != tt.wantTZ {
+				t.Errorf("expected timezone %s, got %s", tt.wantTZ, resp.Resolved.Timezone)
+			}
+			if resp.Match.Text != tt.match || tt.text[resp.Match.Start:resp.Match.End] != tt.match {
+				t.Errorf("expected match %q, got %+v", tt.match, resp.Match)
+			}
+			if resp.Ambiguous != tt.ambiguous {
+				t.Errorf("expected ambiguous %v, got %v", tt.ambiguous, resp.Ambiguous)
+			}
+		})
+	}
+
+	t.Run("No expression", func(t *testing.T) {
+		_, err := s.ParseNatural(&models.ParseRequest{Text: "hello there", Reference: reference})
+		if !errors.Is(err, ErrNoDateFound) {
+			t.Errorf("expected ErrNoDateFound, got %v", err)
+		}
+	})
+
+	for _, text := range []string{"in 999999999 years", "99999999999 seconds ago", "on 2026-02-31", "2025-02-29"} {
+		t.Run("Out of range "+text, func(t *testing.T) {
+			_, err := s.ParseNatural(&models.ParseRequest{Text: text, Reference: reference})
+			if !errors.Is(err, ErrNoDateFound) {
+				t.Errorf("expected ErrNoDateFound, got %v", err)
+			}
+		})
+	}
+
+	t.Run("Leap day", func(t *testing.T) {
+		resp, err := s.ParseNatural(&models.ParseRequest{Text: "2028-02-29", Reference: reference})
+		if err != nil || resp.Resolved.Timestamp != "2028-02-29T00:00:00Z" {
+			t.Errorf("expected 2028-02-29, got %+v (%v)", resp, err)
+		}
+	})
+
+	t.Run("Invalid timezone", func(t *testing.T) {
+		if _, err := s.ParseNatural(&models.ParseRequest{Text: "tomorrow", Timezone: "Mars/Olympus"}); err == nil {
+			t.Error("expected error for invalid timezone, got nil")
+		}
+	})
+}
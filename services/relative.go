@@ -0,0 +1,201 @@
+package services
+
+import (
+	"fmt"
+	"gotimedate/locale"
+	"gotimedate/models"
+	"math"
+	"time"
+)
+
+// Relative time units ordered from smallest to largest. Months and years use
+// average Gregorian lengths.
+var relativeUnits = []struct {
+	name string
+	size time.Duration
+}{
+	{"second", time.Second},
+	{"minute", time.Minute},
+	{"hour", time.Hour},
+	{"day", 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"month", time.Duration(30.436875 * float64(24*time.Hour))},
+	{"year", time.Duration(365.2425 * float64(24*time.Hour))},
+}
+
+// Supported values for RelativeOptions.Rounding.
+const (
+	RoundingRound = "round"
+	RoundingFloor = "floor"
+	RoundingCeil  = "ceil"
+)
+
+// RelativeOptions controls how Humanize describes a time difference.
+// Granularity is the smallest unit used; anything shorter reads as "now" (or
+// "today" for day granularity and above). Calendar enables "yesterday at 5 PM"
+// style phrases for instants on the neighbouring calendar day.
+type RelativeOptions struct {
+	Granularity string
+	Rounding    string
+	Calendar    bool
+}
+
+// RelativeTime is the structured result of Humanize.
+type RelativeTime struct {
+	Text      string
+	Unit      string
+	Value     int
+	Direction string
+}
+
+func (o RelativeOptions) validate() (RelativeOptions, error) {
+	if o.Granularity == "" {
+		o.Granularity = "second"
+	}
+	if unitIndex(o.Granularity) < 0 {
+		return o, fmt.Errorf("invalid granularity: %s", o.Granularity)
+	}
+	switch o.Rounding {
+	case "":
+		o.Rounding = RoundingRound
+	case RoundingRound, RoundingFloor, RoundingCeil:
+	default:
+		return o, fmt.Errorf("invalid rounding: %s (expected round, floor or ceil)", o.Rounding)
+	}
+	return o, nil
+}
+
+func unitIndex(name string) int {
+	for i, u := range relativeUnits {
+		if u.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Humanize describes t relative to ref in the given locale. Both instants
+// should already be in the timezone used for calendar comparisons.
+func (s *TimeService) Humanize(t, ref time.Time, l *locale.Locale, opts RelativeOptions) (RelativeTime, error) {
+	opts, err := opts.validate()
+	if err != nil {
+		return RelativeTime{}, err
+	}
+	delta := t.Sub(ref)
+	future := delta > 0
+	abs := delta
+	if abs < 0 {
+		abs = -abs
+	}
+	direction := "past"
+	if future {
+		direction = "future"
+	}
+
+	minUnit := unitIndex(opts.Granularity)
+	unit := -1
+	for i := len(relativeUnits) - 1; i >= minUnit; i-- {
+		if abs >= relativeUnits[i].size {
+			unit = i
+			break
+		}
+	}
+
+	dayDiff := calendarDayDiff(t, ref)
+	dayUnit := unitIndex("day")
+	if opts.Calendar && minUnit <= dayUnit && (dayDiff == 1 || dayDiff == -1) && abs >= 6*time.Hour && unit <= dayUnit {
+		word := l.Relative.Yesterday
+		if dayDiff == 1 {
+			word = l.Relative.Tomorrow
+		}
+		text := word
+		if minUnit < dayUnit {
+			text = l.At(word, l.FormatClock(t))
+		}
+		return RelativeTime{Text: text, Unit: "day", Value: 1, Direction: direction}, nil
+	}
+
+	if unit < 0 {
+		switch {
+		case minUnit < dayUnit:
+			return RelativeTime{Text: l.Relative.Now, Unit: opts.Granularity, Direction: "now"}, nil
+		case dayDiff == 0:
+			return RelativeTime{Text: l.Relative.Today, Unit: opts.Granularity, Direction: "now"}, nil
+		}
+		unit = minUnit
+	}
+
+	ratio := float64(abs) / float64(relativeUnits[unit].size)
+	var value int
+	switch opts.Rounding {
+	case RoundingFloor:
+		value = int(math.Floor(ratio))
+	case RoundingCeil:
+		value = int(math.Ceil(ratio))
+	default:
+		value = int(math.Round(ratio))
+	}
+	if value < 1 {
+		value = 1
+	}
+	name := relativeUnits[unit].name
+	return RelativeTime{
+		Text:      l.RelativeUnit(name, future, value),
+		Unit:      name,
+		Value:     value,
+		Direction: direction,
+	}, nil
+}
+
+// calendarDayDiff returns how many calendar days t is after ref, comparing
+// local dates in t's location.
+func calendarDayDiff(t, ref time.Time) int {
+	ref = ref.In(t.Location())
+	ty, tm, td := t.Date()
+	ry, rm, rd := ref.Date()
+	a := time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC)
+	b := time.Date(ry, rm, rd, 0, 0, 0, 0, time.UTC)
+	return int(a.Sub(b).Hours() / 24)
+}
+
+// GetRelativeTime humanizes an RFC 3339 timestamp relative to reference (or
+// the current time when empty) in the given timezone and locale.
+func (s *TimeService) GetRelativeTime(timestamp, reference, timezone, localeTag string, opts RelativeOptions) (*models.RelativeTimeResponse, error) {
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", timezone)
+	}
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp format: %s", timestamp)
+	}
+	ref := time.Now()
+	if reference != "" {
+		if ref, err = time.Parse(time.RFC3339, reference); err != nil {
+			return nil, fmt.Errorf("invalid reference format: %s", reference)
+		}
+	}
+	if localeTag == "" {
+		localeTag = locale.Default
+	}
+	l, err := lookupLocale(localeTag)
+	if err != nil {
+		return nil, err
+	}
+	t, ref = t.In(loc), ref.In(loc)
+	rel, err := s.Humanize(t, ref, l, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RelativeTimeResponse{
+		Text:         rel.Text,
+		Timestamp:    t.Format(time.RFC3339),
+		Reference:    ref.Format(time.RFC3339),
+		Timezone:     timezone,
+		Locale:       l.Tag,
+		Unit:         rel.Unit,
+		Value:        rel.Value,
+		Direction:    rel.Direction,
+		DeltaSeconds: t.Sub(ref).Seconds(),
+	}, nil
+}
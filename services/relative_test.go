@@ -0,0 +1,69 @@
+package services
+
+import (
+	"gotimedate/locale"
+	"testing"
+	"time"
+)
+
+func TestTimeService_Humanize(t *testing.T) {
+	s := NewTimeService()
+	en, _ := locale.Get("en")
+	ref := time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		opts RelativeOptions
+		want string
+	}{
+		{"future hours", ref.Add(3 * time.Hour), RelativeOptions{}, "in 3 hours"},
+		{"past days", ref.Add(-50 * time.Hour), RelativeOptions{}, "2 days ago"},
+		{"now", ref.Add(20 * time.Second), RelativeOptions{Granularity: "minute"}, "now"},
+		{"rounding floor", ref.Add(-100 * time.Minute), RelativeOptions{Rounding: RoundingFloor}, "1 hour ago"},
+		{"rounding ceil", ref.Add(-61 * time.Minute), RelativeOptions{Rounding: RoundingCeil}, "2 hours ago"},
+		{"yesterday at", time.Date(2026, 1, 3, 17, 0, 0, 0, time.UTC), RelativeOptions{Calendar: true}, "yesterday at 5 PM"},
+		{"tomorrow day granularity", time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), RelativeOptions{Calendar: true, Granularity: "day"}, "tomorrow"},
+		{"today", ref.Add(2 * time.Hour), RelativeOptions{Granularity: "day"}, "today"},
+		{"calendar disabled", time.Date(2026, 1, 3, 17, 0, 0, 0, time.UTC), RelativeOptions{}, "19 hours ago"},
+		{"weeks", ref.Add(15 * 24 * time.Hour), RelativeOptions{}, "in 2 weeks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Humanize(tt.t, ref, en, tt.opts)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got.Text != tt.want {
+				t.Errorf("got %q, want %q", got.Text, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.Humanize(ref, ref, en, RelativeOptions{Granularity: "fortnight"}); err == nil {
+		t.Error("expected error for invalid granularity, got nil")
+	}
+}
+
+func TestTimeService_GetRelativeTime(t *testing.T) {
+	s := NewTimeService()
+
+	t.Run("Localized", func(t *testing.T) {
+		resp, err := s.GetRelativeTime("2026-01-04T15:00:00Z", "2026-01-04T12:00:00Z", "Asia/Kuala_Lumpur", "de", RelativeOptions{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if resp.Text != "in 3 Stunden" {
+			t.Errorf("expected %q, got %q", "in 3 Stunden", resp.Text)
+		}
+		if resp.Unit != "hour" || resp.Value != 3 || resp.Direction != "future" {
+			t.Errorf("unexpected structured result: %+v", resp)
+		}
+	})
+
+	t.Run("Unsupported locale", func(t *testing.T) {
+		if _, err := s.GetRelativeTime("2026-01-04T15:00:00Z", "", "UTC", "xx", RelativeOptions{}); err == nil {
+			t.Error("expected error for unsupported locale, got nil")
+		}
+	})
+}
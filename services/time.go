@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"gotimedate/locale"
 	"gotimedate/models"
 	"time"
 )
@@ -12,85 +13,174 @@ func NewTimeService() *TimeService {
 	return &TimeService{}
 }
 
-func (s *TimeService) GetCurrentTime(timezone string) (*models.TimeResponse, error) {
-	loc, err := time.LoadLocation(timezone)
+// Supported values for TimeOptions.Precision.
+const (
+	PrecisionSecond      = "s"
+	PrecisionMillisecond = "ms"
+	PrecisionMicrosecond = "us"
+	PrecisionNanosecond  = "ns"
+)
+
+var precisionUnits = map[string]time.Duration{
+	PrecisionSecond:      time.Second,
+	PrecisionMillisecond: time.Millisecond,
+	PrecisionMicrosecond: time.Microsecond,
+	PrecisionNanosecond:  time.Nanosecond,
+}
+
+// TimeOptions controls how a TimeResponse is rendered. The zero value keeps
+// the original output: English, 12-hour Formatted and whole-second precision.
+// With a Locale and no Format, the locale's preferred hour cycle is used.
+type TimeOptions struct {
+	Format    string
+	Precision string
+	Locale    string
+}
+
+// ParsePrecision validates a precision query value, defaulting to seconds.
+func ParsePrecision(precision string) (string, error) {
+	if precision == "" {
+		return PrecisionSecond, nil
+	}
+	if _, ok := precisionUnits[precision]; !ok {
+		return "", fmt.Errorf("invalid precision: %s (expected s, ms, us or ns)", precision)
+	}
+	return precision, nil
+}
+
+func resolveOptions(opts []TimeOptions) TimeOptions {
+	var o TimeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Format == "" && o.Locale == "" {
+		o.Format = "12hour"
+	}
+	if o.Precision == "" {
+		o.Precision = PrecisionSecond
+	}
+	return o
+}
+
+func (s *TimeService) GetCurrentTime(timezone string, opts ...TimeOptions) (*models.TimeResponse, error) {
+	return s.TimeAt(time.Now(), timezone, opts...)
+}
+
+// TimeAt renders the instant t in timezone. It lets callers that fan the same
+// payload out to many clients render an aligned tick exactly once.
+func (s *TimeService) TimeAt(t time.Time, timezone string, opts ...TimeOptions) (*models.TimeResponse, error) {
+	loc, err := LoadLocation(timezone)
 	if err != nil {
 		return nil, fmt.Errorf("invalid timezone: %s", timezone)
 	}
-	now := time.Now().In(loc)
-	_, offset := now.Zone()
-	return &models.TimeResponse{
-		Timestamp:  now.Format(time.RFC3339),
-		Timezone:   timezone,
-		Unix:       now.Unix(),
-		UnixOffset: offset,
-		Formatted:  s.FormatTime(now, "12hour"),
-		Date:       s.FormatDate(now),
-	}, nil
+	o := resolveOptions(opts)
+	if _, err := lookupLocale(o.Locale); err != nil {
+		return nil, err
+	}
+	resp := s.newTimeResponse(t.In(loc), timezone, o)
+	return &resp, nil
 }
 
-func (s *TimeService) ConvertTime(req *models.TimeConvertRequest) (*models.TimeConvertResponse, error) {
+func (s *TimeService) ConvertTime(req *models.TimeConvertRequest, opts ...TimeOptions) (*models.TimeConvertResponse, error) {
 	fromTime, err := time.Parse(time.RFC3339, req.Timestamp)
 	if err != nil {
 		return nil, fmt.Errorf("invalid timestamp format: %s", req.Timestamp)
 	}
-	fromLoc, err := time.LoadLocation(req.FromTimezone)
+	fromLoc, err := LoadLocation(req.FromTimezone)
 	if err != nil {
 		return nil, fmt.Errorf("invalid from timezone: %s", req.FromTimezone)
 	}
-	toLoc, err := time.LoadLocation(req.ToTimezone)
+	toLoc, err := LoadLocation(req.ToTimezone)
 	if err != nil {
 		return nil, fmt.Errorf("invalid to timezone: %s", req.ToTimezone)
 	}
+	o := resolveOptions(opts)
+	if _, err := lookupLocale(o.Locale); err != nil {
+		return nil, err
+	}
 	fromTimeInTZ := fromTime.In(fromLoc)
 	toTimeInTZ := fromTime.In(toLoc)
 	_, fromOffset := fromTimeInTZ.Zone()
 	_, toOffset := toTimeInTZ.Zone()
 	offsetSeconds := toOffset - fromOffset
 	return &models.TimeConvertResponse{
-		Original: models.TimeResponse{
-			Timestamp:  fromTimeInTZ.Format(time.RFC3339),
-			Timezone:   req.FromTimezone,
-			Unix:       fromTimeInTZ.Unix(),
-			UnixOffset: fromOffset,
-			Formatted:  s.FormatTime(fromTimeInTZ, "12hour"),
-			Date:       s.FormatDate(fromTimeInTZ),
-		},
-		Converted: models.TimeResponse{
-			Timestamp:  toTimeInTZ.Format(time.RFC3339),
-			Timezone:   req.ToTimezone,
-			Unix:       toTimeInTZ.Unix(),
-			UnixOffset: toOffset,
-			Formatted:  s.FormatTime(toTimeInTZ, "12hour"),
-			Date:       s.FormatDate(toTimeInTZ),
-		},
+		Original:      s.newTimeResponse(fromTimeInTZ, req.FromTimezone, o),
+		Converted:     s.newTimeResponse(toTimeInTZ, req.ToTimezone, o),
 		OffsetHours:   float64(offsetSeconds) / 3600.0,
 		OffsetMinutes: offsetSeconds / 60,
 	}, nil
 }
 
-func (s *TimeService) GetAvailableTimezones() []models.TimezoneInfo {
-	zones := []string{
-		"UTC",
-		"Africa/Cairo", "Africa/Casablanca", "Africa/Johannesburg", "Africa/Lagos", "Africa/Nairobi",
-		"America/Anchorage", "America/Argentina/Buenos_Aires", "America/Bogota", "America/Caracas",
-		"America/Chicago", "America/Denver", "America/Halifax", "America/Los_Angeles",
-		"America/Mexico_City", "America/New_York", "America/Phoenix", "America/Santiago", "America/Sao_Paulo",
-		"Asia/Bangkok", "Asia/Dubai", "Asia/Hong_Kong", "Asia/Istanbul", "Asia/Jakarta",
-		"Asia/Jerusalem", "Asia/Kabul", "Asia/Karachi", "Asia/Kolkata", "Asia/Kuala_Lumpur", "Asia/Manila",
-		"Asia/Seoul", "Asia/Shanghai", "Asia/Singapore", "Asia/Taipei", "Asia/Tehran", "Asia/Tokyo",
-		"Atlantic/Azores", "Atlantic/Cape_Verde",
-		"Australia/Adelaide", "Australia/Brisbane", "Australia/Darwin", "Australia/Melbourne", "Australia/Perth", "Australia/Sydney",
-		"Europe/Amsterdam", "Europe/Athens", "Europe/Berlin", "Europe/Brussels", "Europe/Budapest",
-		"Europe/Dublin", "Europe/Lisbon", "Europe/London", "Europe/Luxembourg", "Europe/Madrid",
-		"Europe/Moscow", "Europe/Oslo", "Europe/Paris", "Europe/Prague", "Europe/Rome",
-		"Europe/Stockholm", "Europe/Vienna", "Europe/Warsaw", "Europe/Zurich",
-		"Pacific/Auckland", "Pacific/Fiji", "Pacific/Guam", "Pacific/Honolulu", "Pacific/Pago_Pago",
+func (s *TimeService) newTimeResponse(t time.Time, timezone string, o TimeOptions) models.TimeResponse {
+	_, offset := t.Zone()
+	resp := models.TimeResponse{
+		Timestamp:  t.Format(time.RFC3339),
+		Timezone:   timezone,
+		Unix:       t.Unix(),
+		UnixOffset: offset,
+		Formatted:  s.FormatTime(t, o.Format),
+		Date:       s.FormatDate(t),
+	}
+	if l, _ := lookupLocale(o.Locale); l != nil {
+		resp.Locale = l.Tag
+		resp.Date = l.FormatDate(t)
+		switch o.Format {
+		case "12hour", "24hour", "":
+			resp.Formatted = l.FormatTime(t, o.Format == "12hour" || (o.Format == "" && l.Hour12()))
+		}
+	}
+	unit, ok := precisionUnits[o.Precision]
+	if !ok || unit == time.Second {
+		return resp
+	}
+	resp.TimestampNano = t.Truncate(unit).Format(time.RFC3339Nano)
+	resp.UnixMs = t.UnixMilli()
+	if unit <= time.Microsecond {
+		resp.UnixUs = t.UnixMicro()
+	}
+	if unit == time.Nanosecond {
+		resp.UnixNs = t.UnixNano()
 	}
+	return resp
+}
+
+// ClockSync stamps the server side of an NTP-style exchange. received should
+// be captured as early as possible after the request arrives; the transmit
+// timestamp is taken just before returning.
+func (s *TimeService) ClockSync(originate float64, received time.Time) *models.ClockSyncResponse {
+	transmit := time.Now()
+	return &models.ClockSyncResponse{
+		Originate:  originate,
+		Receive:    unixMillis(received),
+		Transmit:   unixMillis(transmit),
+		ReceiveNs:  received.UnixNano(),
+		TransmitNs: transmit.UnixNano(),
+	}
+}
+
+// availableZones is the curated list of timezones exposed by the API.
+var availableZones = []string{
+	"UTC",
+	"Africa/Cairo", "Africa/Casablanca", "Africa/Johannesburg", "Africa/Lagos", "Africa/Nairobi",
+	"America/Anchorage", "America/Argentina/Buenos_Aires", "America/Bogota", "America/Caracas",
+	"America/Chicago", "America/Denver", "America/Halifax", "America/Los_Angeles",
+	"America/Mexico_City", "America/New_York", "America/Phoenix", "America/Santiago", "America/Sao_Paulo",
+	"Asia/Bangkok", "Asia/Dubai", "Asia/Hong_Kong", "Asia/Istanbul", "Asia/Jakarta",
+	"Asia/Jerusalem", "Asia/Kabul", "Asia/Karachi", "Asia/Kolkata", "Asia/Kuala_Lumpur", "Asia/Manila",
+	"Asia/Seoul", "Asia/Shanghai", "Asia/Singapore", "Asia/Taipei", "Asia/Tehran", "Asia/Tokyo",
+	"Atlantic/Azores", "Atlantic/Cape_Verde",
+	"Australia/Adelaide", "Australia/Brisbane", "Australia/Darwin", "Australia/Melbourne", "Australia/Perth", "Australia/Sydney",
+	"Europe/Amsterdam", "Europe/Athens", "Europe/Berlin", "Europe/Brussels", "Europe/Budapest",
+	"Europe/Dublin", "Europe/Lisbon", "Europe/London", "Europe/Luxembourg", "Europe/Madrid",
+	"Europe/Moscow", "Europe/Oslo", "Europe/Paris", "Europe/Prague", "Europe/Rome",
+	"Europe/Stockholm", "Europe/Vienna", "Europe/Warsaw", "Europe/Zurich",
+	"Pacific/Auckland", "Pacific/Fiji", "Pacific/Guam", "Pacific/Honolulu", "Pacific/Pago_Pago",
+}
 
+func (s *TimeService) GetAvailableTimezones() []models.TimezoneInfo {
 	var result []models.TimezoneInfo
-	for _, tz := range zones {
-		if loc, err := time.LoadLocation(tz); err == nil {
+	for _, tz := range availableZones {
+		if loc, err := LoadLocation(tz); err == nil {
 			now := time.Now().In(loc)
 			_, offset := now.Zone()
 			result = append(result, models.TimezoneInfo{
@@ -111,6 +201,16 @@ func (s *TimeService) GetTimeFormats() []models.TimeFormat {
 	}
 }
 
+// IsValidFormat reports whether format is one of GetTimeFormats.
+func (s *TimeService) IsValidFormat(format string) bool {
+	for _, f := range s.GetTimeFormats() {
+		if f.Name == format {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *TimeService) FormatTime(t time.Time, format string) string {
 	switch format {
 	case "12hour":
@@ -125,3 +225,19 @@ func (s *TimeService) FormatTime(t time.Time, format string) string {
 func (s *TimeService) FormatDate(t time.Time) string {
 	return t.Format("Monday, January 2, 2006")
 }
+
+// lookupLocale resolves an optional locale tag; an empty tag yields nil.
+func lookupLocale(tag string) (*locale.Locale, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	l, ok := locale.Get(tag)
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale: %s", tag)
+	}
+	return l, nil
+}
+
+func unixMillis(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Millisecond)
+}
@@ -51,6 +51,112 @@ func TestTimeService_ConvertTime(t *testing.T) {
 	})
 }
 
+func TestTimeService_Precision(t *testing.T) {
+	s := NewTimeService()
+	req := &models.TimeConvertRequest{
+		Timestamp:    "2026-01-04T15:00:00.123456789Z",
+		FromTimezone: "UTC",
+		ToTimezone:   "Asia/Kuala_Lumpur",
+	}
+
+	t.Run("Seconds omits sub-second fields", func(t *testing.T) {
+		resp, err := s.ConvertTime(req)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if resp.Original.UnixMs != 0 || resp.Original.TimestampNano != "" {
+			t.Errorf("expected no sub-second fields, got %+v", resp.Original)
+		}
+	})
+
+	t.Run("Milliseconds", func(t *testing.T) {
+		resp, err := s.ConvertTime(req, TimeOptions{Precision: PrecisionMillisecond})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if resp.Original.UnixMs != 1767538800123 {
+			t.Errorf("expected unix_ms 1767538800123, got %d", resp.Original.UnixMs)
+		}
+		if resp.Original.UnixUs != 0 {
+			t.Errorf("expected unix_us to be omitted, got %d", resp.Original.UnixUs)
+		}
+		if want := "2026-01-04T23:00:00.123+08:00"; resp.Converted.TimestampNano != want {
+			t.Errorf("expected timestamp_nano %s, got %s", want, resp.Converted.TimestampNano)
+		}
+	})
+
+	t.Run("Nanoseconds", func(t *testing.T) {
+		resp, err := s.ConvertTime(req, TimeOptions{Precision: PrecisionNanosecond})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if resp.Original.UnixNs != 1767538800123456789 {
+			t.Errorf("expected unix_ns 1767538800123456789, got %d", resp.Original.UnixNs)
+		}
+		if resp.Original.UnixUs != 1767538800123456 {
+			t.Errorf("expected unix_us 1767538800123456, got %d", resp.Original.UnixUs)
+		}
+		if want := "2026-01-04T15:00:00.123456789Z"; resp.Original.TimestampNano != want {
+			t.Errorf("expected timestamp_nano %s, got %s", want, resp.Original.TimestampNano)
+		}
+	})
+
+	t.Run("Invalid precision", func(t *testing.T) {
+		if _, err := ParsePrecision("minutes"); err == nil {
+			t.Error("expected error for invalid precision, got nil")
+		}
+	})
+}
+
+func TestTimeService_Locale(t *testing.T) {
+	s := NewTimeService()
+	req := &models.TimeConvertRequest{
+		Timestamp:    "2024-01-03T14:30:45Z",
+		FromTimezone: "UTC",
+		ToTimezone:   "Europe/Berlin",
+	}
+
+	resp, err := s.ConvertTime(req, TimeOptions{Locale: "de"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := "Mittwoch, 3. Januar 2024"; resp.Converted.Date != want {
+		t.Errorf("expected date %q, got %q", want, resp.Converted.Date)
+	}
+	// German prefers a 24-hour clock when no format is requested.
+	if want := "15:30:45"; resp.Converted.Formatted != want {
+		t.Errorf("expected formatted %q, got %q", want, resp.Converted.Formatted)
+	}
+
+	resp, _ = s.ConvertTime(req, TimeOptions{Locale: "de", Format: "12hour"})
+	if want := "3:30:45 PM"; resp.Converted.Formatted != want {
+		t.Errorf("expected formatted %q, got %q", want, resp.Converted.Formatted)
+	}
+
+	if _, err := s.GetCurrentTime("UTC", TimeOptions{Locale: "tlh"}); err == nil {
+		t.Error("expected error for unsupported locale, got nil")
+	}
+}
+
+func TestTimeService_ClockSync(t *testing.T) {
+	s := NewTimeService()
+	received := time.Date(2026, 1, 4, 15, 0, 0, 123456789, time.UTC)
+
+	resp := s.ClockSync(1767538799999.5, received)
+	if resp.Originate != 1767538799999.5 {
+		t.Errorf("expected originate to be echoed, got %f", resp.Originate)
+	}
+	if resp.ReceiveNs != received.UnixNano() {
+		t.Errorf("expected receive_ns %d, got %d", received.UnixNano(), resp.ReceiveNs)
+	}
+	if want := 1767538800123.4568; resp.Receive-want > 0.001 || want-resp.Receive > 0.001 {
+		t.Errorf("expected receive %f, got %f", want, resp.Receive)
+	}
+	if resp.TransmitNs < resp.ReceiveNs {
+		t.Error("expected transmit to follow receive")
+	}
+}
+
 func TestTimeService_FormatTime(t *testing.T) {
 	s := NewTimeService()
 	now := time.Date(2026, 1, 4, 15, 4, 5, 0, time.UTC)
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gotimedate/models"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers set on every webhook request. The signature covers
+// "<timestamp>.<body>" so receivers can reject replayed deliveries.
+const (
+	WebhookEventHeader     = "X-GoTimeDate-Event"
+	WebhookDeliveryHeader  = "X-GoTimeDate-Delivery"
+	WebhookTimestampHeader = "X-GoTimeDate-Timestamp"
+	WebhookSignatureHeader = "X-GoTimeDate-Signature"
+)
+
+// ErrWebhookAddress is returned for webhooks that resolve to an address the
+// server may not call.
+var ErrWebhookAddress = errors.New("webhook address not allowed")
+
+// blockedPrefixes are internal ranges net/netip has no predicate for: shared
+// address space (RFC 6598) and benchmarking (RFC 2544).
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// publicAddress reports whether ip may be called by a webhook: not loopback,
+// private, link-local, multicast or unspecified.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkAddress is a net.Dialer Control hook. It sees the resolved address of
+// every connection, including those after redirects, so a hostname cannot
+// point a webhook at an internal service.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrWebhookAddress, address)
+	}
+	if !publicAddress(ap.Addr()) {
+		return fmt.Errorf("%w: %s is not a public address", ErrWebhookAddress, ap.Addr())
+	}
+	return nil
+}
+
+// WebhookSender POSTs alarm events, retrying failed deliveries with
+// exponential backoff.
+type WebhookSender struct {
+	client      *http.Client
+	secret      []byte
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewWebhookSender returns a sender that only calls public addresses unless
+// allowPrivate is set.
+func NewWebhookSender(secret string, maxAttempts int, timeout time.Duration, allowPrivate bool) *WebhookSender {
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: checkAddress}
+		transport.DialContext = dialer.DialContext
+		// A proxy would hide the destination from checkAddress.
+		transport.Proxy = nil
+	}
+	return &WebhookSender{
+		client:      &http.Client{Timeout: timeout, Transport: transport},
+		secret:      []byte(secret),
+		maxAttempts: maxAttempts,
+		backoff:     time.Second,
+	}
+}
+
+// SignWebhook returns the signature header value for a delivery.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send delivers event to url. Network errors, 429 and 5xx responses are
+// retried; other responses are final. Every attempt shares one delivery ID.
+func (w *WebhookSender) Send(ctx context.Context, url string, event models.AlarmEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	delivery := uuid.NewString()
+	backoff := w.backoff
+	var lastErr error
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		retry, err := w.deliver(ctx, url, delivery, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == w.maxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("webhook %s failed: %w", url, lastErr)
+}
+
+func (w *WebhookSender) deliver(ctx context.Context, url, delivery string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoTimeDate-Webhook/1.0")
+	req.Header.Set(WebhookEventHeader, "alarm")
+	req.Header.Set(WebhookDeliveryHeader, delivery)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if len(w.secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.secret, timestamp, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, ErrWebhookAddress), err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gotimedate/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookSignedDeliveryWithRetry(t *testing.T) {
+	var attempts atomic.Int32
+	var deliveries = make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		want := SignWebhook([]byte("s3cret"), r.Header.Get(WebhookTimestampHeader), body)
+		if r.Header.Get(WebhookSignatureHeader) != want {
+			t.Errorf("expected signature %s, got %s", want, r.Header.Get(WebhookSignatureHeader))
+		}
+		var ev models.AlarmEvent
+		if err := json.Unmarshal(body, &ev); err != nil || ev.AlarmID != "a1" {
+			t.Errorf("unexpected body %s", body)
+		}
+		deliveries <- r.Header.Get(WebhookDeliveryHeader)
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender("s3cret", 5, time.Second, true)
+	sender.backoff = time.Millisecond
+	if err := sender.Send(context.Background(), server.URL, models.AlarmEvent{AlarmID: "a1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+	first := <-deliveries
+	for i := 0; i < 2; i++ {
+		if id := <-deliveries; id != first {
+			t.Errorf("expected retries to share delivery ID %s, got %s", first, id)
+		}
+	}
+}
+
+func TestWebhookClientErrorNotRetried(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender("", 5, time.Second, true)
+	sender.backoff = time.Millisecond
+	if err := sender.Send(context.Background(), server.URL, models.AlarmEvent{}); err == nil {
+		t.Error("expected error for 400 response")
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("expected 1 attempt, got %d", n)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender("", 3, time.Second, true)
+	sender.backoff = time.Millisecond
+	if err := sender.Send(context.Background(), server.URL, models.AlarmEvent{}); err == nil {
+		t.Error("expected error after exhausting retries")
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestWebhookPrivateAddressRefused(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender("", 3, time.Second, false)
+	sender.backoff = time.Millisecond
+	err := sender.Send(context.Background(), server.URL, models.AlarmEvent{})
+	if !errors.Is(err, ErrWebhookAddress) {
+		t.Errorf("expected ErrWebhookAddress, got %v", err)
+	}
+	if n := attempts.Load(); n != 0 {
+		t.Errorf("expected no request to reach the server, got %d", n)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"224.0.0.1":            false,
+		"::1":                  false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	} {
+		if got := publicAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("%s: expected %v, got %v", addr, want, got)
+		}
+	}
+}
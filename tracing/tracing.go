@@ -0,0 +1,88 @@
+// Package tracing configures OpenTelemetry tracing for the server.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies this server in exported spans.
+const ServiceName = "gotimedate"
+
+// Tracer returns the tracer for an instrumented package, such as
+// "gotimedate/handlers".
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// Setup installs the global tracer provider and the W3C trace-context
+// propagator. exporter is one of "none" (or empty), "stdout", "otlphttp" or
+// "otlpgrpc"; the OTLP exporters read their endpoint and headers from the
+// standard OTEL_EXPORTER_OTLP_* variables. The returned function flushes and
+// stops the provider.
+func Setup(ctx context.Context, exporter string, sampleRatio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlphttp":
+		exp, err = otlptracehttp.New(ctx)
+	case "otlpgrpc":
+		exp, err = otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s exporter: %w", exporter, err)
+	}
+
+	tp := NewProvider(exp, sampleRatio, false)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider returns a provider exporting to exp. Remote parents keep their
+// sampling decision; new traces are sampled at sampleRatio (1 samples all, 0
+// none). Tests pass sync to export each span as it ends.
+func NewProvider(exp sdktrace.SpanExporter, sampleRatio float64, sync bool) *sdktrace.TracerProvider {
+	if sampleRatio > 1 {
+		sampleRatio = 1
+	}
+	export := sdktrace.WithBatcher(exp)
+	if sync {
+		export = sdktrace.WithSyncer(exp)
+	}
+	return sdktrace.NewTracerProvider(
+		export,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+	)
+}
+
+// TraceID returns the trace ID carried by ctx, or "" when it has none.
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
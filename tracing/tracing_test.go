@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{"", "none", "stdout"} {
+		shutdown, err := Setup(context.Background(), exporter, 1)
+		if err != nil {
+			t.Errorf("Setup(%q): unexpected error: %v", exporter, err)
+			continue
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("Setup(%q): shutdown failed: %v", exporter, err)
+		}
+	}
+	if _, err := Setup(context.Background(), "zipkin", 1); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := NewProvider(exp, 1, true)
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	span.End()
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if got := TraceID(ctx); got != spans[0].SpanContext.TraceID().String() {
+		t.Errorf("expected trace ID %s, got %s", spans[0].SpanContext.TraceID(), got)
+	}
+	var service string
+	for _, kv := range spans[0].Resource.Attributes() {
+		if kv.Key == "service.name" {
+			service = kv.Value.AsString()
+		}
+	}
+	if service != ServiceName {
+		t.Errorf("expected service.name %s, got %q", ServiceName, service)
+	}
+	if got := TraceID(context.Background()); got != "" {
+		t.Errorf("expected no trace ID, got %s", got)
+	}
+}
+
+func TestNewProviderRatioZero(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := NewProvider(exp, 0, true)
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "root")
+	span.End()
+	if n := len(exp.GetSpans()); n != 0 {
+		t.Fatalf("expected ratio 0 to record no root spans, got %d", n)
+	}
+
+	carrier := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	_, span = tp.Tracer("test").Start(ctx, "child")
+	span.End()
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the sampled incoming trace to be followed, got %d spans", len(spans))
+	}
+}